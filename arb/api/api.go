@@ -0,0 +1,129 @@
+// Package api is the HTTP control API of a running engine. Every request
+// must carry the local token as "Authorization: Bearer <token>".
+//
+//	GET  /status                      trading configuration in effect
+//	POST /pause, /resume              stop and restart trading
+//	POST /execute-trades?on=BOOL      override ExecuteTrades
+//	POST /min-profit-rate?rate=DEC    set the minimum profit rate
+//	GET  /state[?pair=BASE/QUOTE]     last merged books, balances and plan
+//	POST /refresh-balances            fetch the balances now
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/L3Sota/arbo/arb"
+	"github.com/L3Sota/arbo/arb/config"
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/shopspring/decimal"
+)
+
+// Token returns conf.ControlToken or, if it is empty, a new random token
+// written to conf.ControlTokenFile, readable only by the owner.
+func Token(conf *config.Config) (string, error) {
+	if conf.ControlToken != "" {
+		return conf.ControlToken, nil
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	t := hex.EncodeToString(b)
+	if err := os.WriteFile(conf.ControlTokenFile, []byte(t+"\n"), 0o600); err != nil {
+		return "", err
+	}
+	return t, nil
+}
+
+// Handler serves the control API to holders of token.
+func Handler(token string, conf *config.Config) http.Handler {
+	mux := http.NewServeMux()
+	handle := func(path, method string, h func(*http.Request) (any, error)) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != method {
+				w.Header().Set("Allow", method)
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			v, err := h(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(v); err != nil {
+				fmt.Println("api write error:", err)
+			}
+		})
+	}
+
+	status := func(*http.Request) (any, error) {
+		return arb.CurrentStatus(conf), nil
+	}
+	handle("/status", http.MethodGet, status)
+	handle("/pause", http.MethodPost, func(r *http.Request) (any, error) {
+		arb.Pause()
+		return status(r)
+	})
+	handle("/resume", http.MethodPost, func(r *http.Request) (any, error) {
+		arb.Resume()
+		return status(r)
+	})
+	handle("/execute-trades", http.MethodPost, func(r *http.Request) (any, error) {
+		on, err := strconv.ParseBool(r.URL.Query().Get("on"))
+		if err != nil {
+			return nil, fmt.Errorf("on: %w", err)
+		}
+		arb.SetExecuteTrades(on)
+		return status(r)
+	})
+	handle("/min-profit-rate", http.MethodPost, func(r *http.Request) (any, error) {
+		rate, err := decimal.NewFromString(r.URL.Query().Get("rate"))
+		if err != nil {
+			return nil, fmt.Errorf("rate: %w", err)
+		}
+		if err := arb.SetMinProfitRate(rate); err != nil {
+			return nil, err
+		}
+		return status(r)
+	})
+	handle("/state", http.MethodGet, func(r *http.Request) (any, error) {
+		ss := arb.LastStates()
+		s := r.URL.Query().Get("pair")
+		if s == "" {
+			return ss, nil
+		}
+		p, err := model.ParsePair(s)
+		if err != nil {
+			return nil, err
+		}
+		for _, st := range ss {
+			if st.Pair == p {
+				return st, nil
+			}
+		}
+		return nil, fmt.Errorf("%v not evaluated yet", p)
+	})
+	handle("/refresh-balances", http.MethodPost, func(*http.Request) (any, error) {
+		return arb.RefreshBalances()
+	})
+
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(strings.TrimSpace(r.Header.Get("Authorization")))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/L3Sota/arbo/arb"
+	"github.com/L3Sota/arbo/arb/config"
+	"github.com/shopspring/decimal"
+)
+
+const token = "secret"
+
+func do(t *testing.T, h http.Handler, method, target, auth string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	r := httptest.NewRequest(method, target, nil)
+	if auth != "" {
+		r.Header.Set("Authorization", auth)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func status(t *testing.T, w *httptest.ResponseRecorder) arb.Status {
+	t.Helper()
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("code = %d, want 200; body: %s", w.Code, w.Body)
+	}
+	var s arb.Status
+	if err := json.NewDecoder(w.Body).Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestAuth(t *testing.T) {
+	h := Handler(token, &config.Config{})
+
+	for _, tc := range []struct {
+		name string
+		auth string
+		want int
+	}{
+		{name: "missing", want: http.StatusUnauthorized},
+		{name: "wrong", auth: "Bearer guess", want: http.StatusUnauthorized},
+		{name: "not bearer", auth: token, want: http.StatusUnauthorized},
+		{name: "correct", auth: "Bearer " + token, want: http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if w := do(t, h, http.MethodGet, "/status", tc.auth); w.Code != tc.want {
+				t.Errorf("code = %d, want %d", w.Code, tc.want)
+			}
+		})
+	}
+}
+
+func TestMethods(t *testing.T) {
+	h := Handler(token, &config.Config{})
+
+	for _, tc := range []struct {
+		method, target, allow string
+	}{
+		{http.MethodPost, "/status", http.MethodGet},
+		{http.MethodGet, "/pause", http.MethodPost},
+		{http.MethodGet, "/min-profit-rate?rate=1", http.MethodPost},
+		{http.MethodPost, "/state", http.MethodGet},
+	} {
+		w := do(t, h, tc.method, tc.target, "Bearer "+token)
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%v %v: code = %d, want 405", tc.method, tc.target, w.Code)
+		}
+		if got := w.Header().Get("Allow"); got != tc.allow {
+			t.Errorf("%v %v: Allow = %q, want %q", tc.method, tc.target, got, tc.allow)
+		}
+	}
+}
+
+func TestControls(t *testing.T) {
+	h := Handler(token, &config.Config{ExecuteTrades: true})
+	auth := "Bearer " + token
+
+	if s := status(t, do(t, h, http.MethodPost, "/pause", auth)); !s.Paused {
+		t.Error("not paused after /pause")
+	}
+	if s := status(t, do(t, h, http.MethodPost, "/resume", auth)); s.Paused || !s.ExecuteTrades {
+		t.Errorf("status after /resume = %+v, want trading", s)
+	}
+
+	before := arb.CurrentStatus(&config.Config{}).MinProfitRate
+	t.Cleanup(func() {
+		if err := arb.SetMinProfitRate(before); err != nil {
+			t.Error(err)
+		}
+	})
+	s := status(t, do(t, h, http.MethodPost, "/min-profit-rate?rate=0.25", auth))
+	if !s.MinProfitRate.Equal(decimal.RequireFromString("0.25")) {
+		t.Errorf("MinProfitRate = %v, want 0.25", s.MinProfitRate)
+	}
+	for _, rate := range []string{"-1", "x", ""} {
+		if w := do(t, h, http.MethodPost, "/min-profit-rate?rate="+rate, auth); w.Code != http.StatusBadRequest {
+			t.Errorf("rate %q: code = %d, want 400", rate, w.Code)
+		}
+	}
+	if s := arb.CurrentStatus(&config.Config{}); !s.MinProfitRate.Equal(decimal.RequireFromString("0.25")) {
+		t.Errorf("MinProfitRate after bad requests = %v, want 0.25", s.MinProfitRate)
+	}
+}
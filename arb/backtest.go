@@ -0,0 +1,163 @@
+package arb
+
+import (
+	"fmt"
+
+	"github.com/L3Sota/arbo/arb/config"
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/L3Sota/arbo/arb/record"
+	"github.com/shopspring/decimal"
+)
+
+// Report summarises a backtest. Profit is in the quote currency, after
+// withdrawal fees.
+type Report struct {
+	Snapshots   int
+	Trades      int
+	Profit      decimal.Decimal
+	TradedBase  decimal.Decimal
+	SpentQuote  decimal.Decimal
+	Utilisation decimal.Decimal // mean share of the available quote spent per trade
+
+	// Opportunities not taken, by reason, and the profit they showed.
+	MissedRate   int // profit rate below the minimum
+	MissedMin    int // below a venue's minimum order size
+	MissedFunds  int // profitable only with unlimited balances
+	MissedProfit decimal.Decimal
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf("snapshots %v, trades %v, profit %v, traded %v base for %v quote, utilisation %v; missed %v (rate %v, min order %v, funds %v) worth %v",
+		r.Snapshots, r.Trades, sigfigs(r.Profit), sigfigs(r.TradedBase), sigfigs(r.SpentQuote), sigfigs(r.Utilisation),
+		r.MissedRate+r.MissedMin+r.MissedFunds, r.MissedRate, r.MissedMin, r.MissedFunds, sigfigs(r.MissedProfit))
+}
+
+// Backtester replays recorded books through arbo. Every opportunity it takes
+// fills in full, and the balances of the venues involved move accordingly;
+// funds are never moved between venues. Consecutive snapshots of the same
+// opportunity count as separate opportunities.
+type Backtester struct {
+	conf     *config.Config
+	venues   map[model.VenueID]model.Venue
+	balances map[model.VenueID]model.Balances
+	minRate  decimal.Decimal
+
+	report      Report
+	utilisation decimal.Decimal // sum over trades
+}
+
+// NewBacktester returns a backtester of venues starting from balances. Books
+// are repriced with the venues' fees. A zero minRate uses the minimum profit
+// rate the engine trades at.
+func NewBacktester(venues map[model.VenueID]model.Venue, balances map[model.VenueID]model.Balances, minRate decimal.Decimal, conf *config.Config) *Backtester {
+	if minRate.IsZero() {
+		minRate = minProfitRate()
+	}
+	if balances == nil {
+		balances = map[model.VenueID]model.Balances{}
+	}
+	for id := range venues {
+		if balances[id] == nil {
+			balances[id] = model.Balances{}
+		}
+	}
+	return &Backtester{
+		conf:     conf,
+		venues:   venues,
+		balances: balances,
+		minRate:  minRate,
+	}
+}
+
+// Step evaluates one recorded book. It has the signature record.Scan calls.
+func (t *Backtester) Step(r record.Record) error {
+	t.report.Snapshots++
+	p := r.Pair
+	a := t.reprice(p, r.Asks, true)
+	b := t.reprice(p, r.Bids, false)
+
+	_, _, totalTradeBase, _, _, _, profit, totalBuyQuote, totalSellQuote, totalBuyBase, totalSellBase := arbo(p, a, b, t.venues, t.balances, t.conf)
+	if !profit.IsPositive() {
+		_, _, _, _, _, _, potential, _, _, _, _ := arbo(p, a, b, t.venues, ignoreBalances(p, t.venues), t.conf)
+		if potential.IsPositive() {
+			t.report.MissedFunds++
+			t.report.MissedProfit = t.report.MissedProfit.Add(potential)
+		}
+		return nil
+	}
+	if profit.Div(totalTradeBase).LessThan(t.minRate) {
+		t.report.MissedRate++
+		t.report.MissedProfit = t.report.MissedProfit.Add(profit)
+		return nil
+	}
+	if !placeable(p, t.venues, totalBuyQuote, totalSellQuote, totalBuyBase, totalSellBase) {
+		t.report.MissedMin++
+		t.report.MissedProfit = t.report.MissedProfit.Add(profit)
+		return nil
+	}
+
+	available, spent := decimal.Zero, decimal.Zero
+	for _, bb := range t.balances {
+		available = available.Add(bb[p.Quote])
+	}
+	for v, q := range totalBuyQuote {
+		spent = spent.Add(q)
+		t.balances[v][p.Quote] = t.balances[v][p.Quote].Sub(q)
+		t.balances[v][p.Base] = t.balances[v][p.Base].Add(totalBuyBase[v])
+	}
+	for v, q := range totalSellQuote {
+		t.balances[v][p.Quote] = t.balances[v][p.Quote].Add(q)
+		t.balances[v][p.Base] = t.balances[v][p.Base].Sub(totalSellBase[v])
+	}
+
+	t.report.Trades++
+	t.report.Profit = t.report.Profit.Add(profit)
+	t.report.TradedBase = t.report.TradedBase.Add(totalTradeBase)
+	t.report.SpentQuote = t.report.SpentQuote.Add(spent)
+	if available.IsPositive() {
+		t.utilisation = t.utilisation.Add(spent.Div(available))
+	}
+	return nil
+}
+
+// Report returns the results so far.
+func (t *Backtester) Report() Report {
+	r := t.report
+	if r.Trades > 0 {
+		r.Utilisation = t.utilisation.Div(decimal.NewFromInt(int64(r.Trades)))
+	}
+	return r
+}
+
+// Balances returns the balances the backtest ended with.
+func (t *Backtester) Balances() map[model.VenueID]model.Balances {
+	return t.balances
+}
+
+// reprice applies the configured fees to a recorded side and merges it again.
+// Orders of venues that are not configured are dropped.
+func (t *Backtester) reprice(p model.Pair, os []model.Order, asc bool) []model.Order {
+	byVenue := map[model.VenueID][]model.Order{}
+	var ids []model.VenueID
+	for _, o := range os {
+		v, ok := t.venues[o.Venue]
+		if !ok {
+			continue
+		}
+		if asc {
+			o.EffectivePrice = o.Price.Mul(v.FeesOf(p).AskAddition(false))
+		} else {
+			o.EffectivePrice = o.Price.Mul(v.FeesOf(p).BidReduction(false))
+		}
+		if _, ok := byVenue[o.Venue]; !ok {
+			ids = append(ids, o.Venue)
+		}
+		byVenue[o.Venue] = append(byVenue[o.Venue], o)
+	}
+
+	xs := make([][]model.Order, 0, len(ids))
+	for _, id := range ids {
+		xs = append(xs, byVenue[id])
+	}
+	return merge(asc, xs...)
+}
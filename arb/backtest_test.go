@@ -0,0 +1,94 @@
+package arb
+
+import (
+	"testing"
+
+	"github.com/L3Sota/arbo/arb/config"
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/L3Sota/arbo/arb/record"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBacktesterStep(t *testing.T) {
+	t.Parallel()
+
+	p := model.XCHUSDT
+	free := model.Fees{Withdrawals: map[string][]model.Withdrawal{
+		"XCH":  {{Network: "XCH"}},
+		"USDT": {{Network: "TRC20"}},
+	}}
+	venues := map[model.VenueID]model.Venue{
+		"A": {ID: "A", Exchange: model.ExchangeTypeKu, Fees: free},
+		"B": {ID: "B", Exchange: model.ExchangeTypeGa, Fees: free},
+		"C": {ID: "C", Exchange: model.ExchangeTypeHu, Fees: free, Rules: map[model.Pair]model.Rules{p: {MinBase: dec("5")}}},
+		"D": {ID: "D", Exchange: model.ExchangeTypeCo, Fees: free},
+	}
+	balances := map[model.VenueID]model.Balances{
+		"A": {"USDT": dec("100")},
+		"B": {"XCH": dec("2")},
+		"C": {"XCH": dec("10")},
+		"D": {"USDT": dec("100")},
+	}
+	bt := NewBacktester(venues, balances, dec("0.1"), &config.Config{})
+
+	order := func(v model.VenueID, price, amount string) model.Order {
+		return model.Order{Venue: v, Price: dec(price), Amount: dec(amount)}
+	}
+	step := func(r record.Record) {
+		t.Helper()
+		r.Pair = p
+		if err := bt.Step(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// taken: buy 1 on A at 10, sell it on B at 12
+	step(record.Record{
+		Asks: []model.Order{order("A", "10", "1")},
+		Bids: []model.Order{order("B", "12", "1")},
+	})
+	want := map[model.VenueID]model.Balances{
+		"A": {"USDT": dec("90"), "XCH": dec("1")},
+		"B": {"USDT": dec("12"), "XCH": dec("1")},
+		"C": {"XCH": dec("10")},
+		"D": {"USDT": dec("100")},
+	}
+	if diff := cmp.Diff(want, bt.Balances()); diff != "" {
+		t.Errorf("balances after the trade mismatch (-want +got):\n%s", diff)
+	}
+
+	// 0.01 per base is below the minimum rate
+	step(record.Record{
+		Asks: []model.Order{order("A", "10", "5")},
+		Bids: []model.Order{order("B", "10.01", "5")},
+	})
+	// C's sell is below its minimum and two venues buy, so it is not pruned
+	step(record.Record{
+		Asks: []model.Order{order("A", "10", "0.5"), order("D", "10.5", "0.5")},
+		Bids: []model.Order{order("C", "12", "1")},
+	})
+	// C holds no quote to buy with
+	step(record.Record{
+		Asks: []model.Order{order("C", "10", "5")},
+		Bids: []model.Order{order("B", "12", "5")},
+	})
+
+	wantReport := Report{
+		Snapshots:    4,
+		Trades:       1,
+		Profit:       dec("2"),
+		TradedBase:   dec("1"),
+		SpentQuote:   dec("10"),
+		Utilisation:  dec("0.05"),
+		MissedRate:   1,
+		MissedMin:    1,
+		MissedFunds:  1,
+		MissedProfit: dec("11.76"),
+	}
+	if diff := cmp.Diff(wantReport, bt.Report()); diff != "" {
+		t.Errorf("report mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(want, bt.Balances()); diff != "" {
+		t.Errorf("balances changed by missed opportunities (-want +got):\n%s", diff)
+	}
+}
@@ -5,17 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
-	"github.com/Kucoin/kucoin-go-sdk"
 	"github.com/L3Sota/arbo/arb/config"
+	"github.com/L3Sota/arbo/arb/exchange"
+	"github.com/L3Sota/arbo/arb/journal"
 	"github.com/L3Sota/arbo/arb/model"
-	"github.com/L3Sota/arbo/c"
-	"github.com/L3Sota/arbo/g"
-	"github.com/L3Sota/arbo/h"
-	"github.com/L3Sota/arbo/k"
-	"github.com/L3Sota/arbo/m"
-	"github.com/gateio/gateapi-go/v6"
-	"github.com/huobirdcenter/huobi_golang/pkg/model/order"
+	"github.com/L3Sota/arbo/arb/pnl"
 	"github.com/shopspring/decimal"
 	"golang.org/x/sync/errgroup"
 )
@@ -25,64 +21,43 @@ type side struct {
 	I             int
 	HeadAmount    decimal.Decimal
 	HeadAllowance decimal.Decimal
-	LastPrice     [model.ExchangeTypeMax]decimal.Decimal
+	LastPrice     map[model.VenueID]decimal.Decimal
 	Move          bool
 }
 
+// level identifies a price level of an exchange's book. Venues on the same
+// exchange see the same book, so liquidity taken through one venue is no
+// longer available through another.
+type level struct {
+	Ex    model.ExchangeType
+	Price string
+}
+
+// fill is an amount arbo takes from one venue's level.
+type fill struct {
+	Amount         decimal.Decimal
+	EffectivePrice decimal.Decimal
+}
+
 const (
-	profitTemplate = "p %v"
-	buyTemplate    = "買@%v $%v, ¢%v [≦ $%v]"
-	sellTemplate   = "売@%v $%v, ¢%v [≧ $%v]"
-	miscTemplate   = "t ¢%v, (g %v - ¢%v - $%v)"
+	profitTemplate   = "p %v"
+	buyTemplate      = "買@%v $%v, ¢%v [≦ $%v]"
+	sellTemplate     = "売@%v $%v, ¢%v [≧ $%v]"
+	miscTemplate     = "t ¢%v, (g %v - ¢%v - $%v)"
+	fillTemplate     = "(%v) fill: $%v, ¢%v; fee: %v %v; state: %v"
+	realizedTemplate = "r %v (fees $%v, w $%v)"
 )
 
 var (
-	fees = [model.ExchangeTypeMax]model.Fees{
-		m.Fees,
-		k.Fees,
-		h.Fees,
-		c.Fees,
-		g.Fees,
-	}
-
-	feeRatioCapUSDT   = decimal.NewFromInt(3000)
-	minimumProfitRate = decimal.RequireFromString("0.005") // $ profit / XCH traded
-
-	big        = decimal.New(1, 10)
-	bigBalance = model.Balances{
-		XCH:  big,
-		USDT: big,
-	}
-	ignoreBalances = [model.ExchangeTypeMax]model.Balances{
-		bigBalance,
-		bigBalance,
-		bigBalance,
-		bigBalance,
-		bigBalance,
-	}
-
-	bb [model.ExchangeTypeMax]model.Balances
-
-	USDTMinSizes = [model.ExchangeTypeMax]decimal.Decimal{
-		decimal.Zero,
-		decimal.RequireFromString("0.1"),
-		decimal.NewFromInt(10),
-		decimal.Zero,
-		decimal.NewFromInt(3),
-	}
-	XCHMinSizes = [model.ExchangeTypeMax]decimal.Decimal{
-		decimal.Zero,
-		decimal.RequireFromString("0.001"),
-		decimal.Zero,
-		decimal.RequireFromString("0.05"),
-		decimal.Zero,
-	}
+	feeRatioCap       = decimal.NewFromInt(3000)           // quote
+	minimumProfitRate = decimal.RequireFromString("0.005") // quote profit / base traded
+
+	big = decimal.New(1, 10)
 )
 
 // gather price information from all exchanges
 // REST to get initial book state
 // WS to get streaming updates
-// + rate limiter to prevent throttling (start with some reasonable #)
 
 // buy orders --->| (mid-market price) |<--- sell orders
 // if the books cross...
@@ -92,141 +67,147 @@ var (
 // |<--- arb  --->|
 // ^buy here      ^ sell here
 
-// + keep track of funding info to deposit/transfer/withdraw as necessary
+// + keep track of funding info to deposit/transfer/withdraw as necessary (see rebalance.go)
 
-func GatherBooks() ([]model.Order, []model.Order) {
-	ma, mb, err := m.Book()
-	if err != nil {
-		return nil, nil
-	}
-	ka, kb, err := k.Book()
-	if err != nil {
-		return nil, nil
-	}
-	ha, hb, err := h.Book()
-	if err != nil {
-		return nil, nil
-	}
-	ca, cb, err := c.Book()
-	if err != nil {
-		return nil, nil
-	}
-	ga, gb, err := g.Book()
-	if err != nil {
-		return nil, nil
+func GatherBooks(p model.Pair) ([]model.Order, []model.Order) {
+	es := exchange.All()
+	as := make([][]model.Order, 0, len(es))
+	bs := make([][]model.Order, 0, len(es))
+	for _, e := range es {
+		a, b, err := e.Book(p)
+		if err != nil {
+			return nil, nil
+		}
+		as = append(as, a)
+		bs = append(bs, b)
 	}
 
-	a := merge(true, ma, ka, ha, ca, ga)
-	b := merge(false, mb, kb, hb, cb, gb)
+	a := merge(true, as...)
+	b := merge(false, bs...)
 
 	return a, b
 }
 
-func GatherBooksP() ([]model.Order, []model.Order, error) {
-	var ma, ka, ha, ca, ga, mb, kb, hb, cb, gb []model.Order
+func GatherBooksP(p model.Pair) ([]model.Order, []model.Order, error) {
+	es := exchange.All()
+	as := make([][]model.Order, len(es))
+	bs := make([][]model.Order, len(es))
 	eg, _ := errgroup.WithContext(context.Background())
-	eg.Go(func() error {
-		a, b, err := m.Book()
-		if err != nil {
-			return fmt.Errorf("m book: %w", err)
-		}
-		ma = a
-		mb = b
-		return nil
-	})
-	eg.Go(func() error {
-		a, b, err := k.Book()
-		if err != nil {
-			return fmt.Errorf("k book: %w", err)
-		}
-		ka = a
-		kb = b
-		return nil
-	})
-	eg.Go(func() error {
-		a, b, err := h.Book()
-		if err != nil {
-			return fmt.Errorf("h book: %w", err)
-		}
-		ha = a
-		hb = b
-		return nil
-	})
-	eg.Go(func() error {
-		a, b, err := c.Book()
-		if err != nil {
-			return fmt.Errorf("c book: %w", err)
-		}
-		ca = a
-		cb = b
-		return nil
-	})
-	eg.Go(func() error {
-		a, b, err := g.Book()
-		if err != nil {
-			return fmt.Errorf("g book: %w", err)
-		}
-		ga = a
-		gb = b
-		return nil
-	})
-	if err := eg.Wait(); err != nil {
+	for i, e := range es {
+		i, e := i, e
+		eg.Go(func() error {
+			v := string(e.Venue().ID)
+			start := time.Now()
+			a, b, err := e.Book(p)
+			bookSeconds.WithLabelValues(v).Observe(time.Since(start).Seconds())
+			if err != nil {
+				bookErrors.WithLabelValues(v).Inc()
+				return fmt.Errorf("%v book: %w", v, err)
+			}
+			as[i] = a
+			bs[i] = b
+			return nil
+		})
+	}
+	err := eg.Wait()
+	observeLimits(es)
+	if err != nil {
 		return nil, nil, err
 	}
 
-	a := merge(true, ma, ka, ha, ca, ga)
-	b := merge(false, mb, kb, hb, cb, gb)
+	a := merge(true, as...)
+	b := merge(false, bs...)
+	recordBooks(p, a, b)
+	observeBooks(p, a, b)
 
 	return a, b, nil
 }
 
-func GatherBalancesP(conf *config.Config) (m [model.ExchangeTypeMax]model.Balances, err error) {
-	eg, _ := errgroup.WithContext(context.Background())
-	// eg.Go(func() error {
-	// 	m[model.ExchangeTypeMe] = model.Balances{}
-	// 	return nil
-	// })
-	eg.Go(func() error {
-		b, err := k.Balances()
-		if err != nil {
-			return fmt.Errorf("k balances: %w", err)
-		}
-		m[model.ExchangeTypeKu] = b
-		return nil
-	})
-	eg.Go(func() error {
-		b, err := h.Balances()
-		if err != nil {
-			return fmt.Errorf("h balances: %w", err)
-		}
-		m[model.ExchangeTypeHu] = b
-		return nil
-	})
-	eg.Go(func() error {
-		b, err := c.Balances()
-		if err != nil {
-			return fmt.Errorf("c balances: %w", err)
+// Watch returns a channel that receives when the best bid or ask of p changes
+// on any streaming venue, and whether all venues stream. Venues that do not
+// stream have to be polled.
+func Watch(p model.Pair) (<-chan struct{}, bool) {
+	out := make(chan struct{}, 1)
+	all := true
+	for _, e := range exchange.All() {
+		s, ok := e.(exchange.Streamer)
+		if !ok {
+			all = false
+			continue
 		}
-		m[model.ExchangeTypeCo] = b
-		return nil
-	})
-	eg.Go(func() error {
-		b, err := g.Balances(conf)
-		if err != nil {
-			return fmt.Errorf("g balances: %w", err)
+		go func(c <-chan struct{}) {
+			for range c {
+				select {
+				case out <- struct{}{}:
+				default:
+				}
+			}
+		}(s.Watch(p))
+	}
+	return out, all
+}
+
+// staleBooks returns the venues serving p from a stream older than maxAge.
+// Venues that fell back to REST fetched their book just now.
+func staleBooks(p model.Pair, maxAge time.Duration) []model.VenueID {
+	var vs []model.VenueID
+	for _, e := range exchange.All() {
+		if s, ok := e.(exchange.Streamer); ok && s.Streaming(p) && s.Age(p) > maxAge {
+			vs = append(vs, e.Venue().ID)
 		}
-		m[model.ExchangeTypeGa] = b
-		return nil
-	})
+	}
+	return vs
+}
+
+// confirmPlaced releases the funds reserved for the orders of p once trade
+// returns: placed orders keep them deducted, the rest are refunded.
+func confirmPlaced(p model.Pair, placed map[model.VenueID]string, totalBuyQuote, totalSellBase map[model.VenueID]decimal.Decimal) {
+	funds.mu.Lock()
+	defer funds.mu.Unlock()
+
+	funds.confirm(p, placed, totalBuyQuote, totalSellBase)
+}
+
+func GatherBalancesP() (map[model.VenueID]model.Balances, error) {
+	es := exchange.All()
+	bs := make([]model.Balances, len(es))
+	eg, _ := errgroup.WithContext(context.Background())
+	for i, e := range es {
+		i, e := i, e
+		eg.Go(func() error {
+			b, err := e.Balances()
+			if errors.Is(err, errors.ErrUnsupported) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("%v balances: %w", e.Venue().ID, err)
+			}
+			bs[i] = b
+			return nil
+		})
+	}
 
 	if err := eg.Wait(); err != nil {
-		return m, err
+		return nil, err
+	}
+
+	m := make(map[model.VenueID]model.Balances, len(es))
+	for i, e := range es {
+		m[e.Venue().ID] = bs[i]
 	}
 
 	return m, nil
 }
 
-func Book(gatherBalances bool, conf *config.Config) (bool, []string, error) {
+func ignoreBalances(p model.Pair, venues map[model.VenueID]model.Venue) map[model.VenueID]model.Balances {
+	m := make(map[model.VenueID]model.Balances, len(venues))
+	for id := range venues {
+		m[id] = model.Balances{p.Base: big, p.Quote: big}
+	}
+	return m
+}
+
+func Book(p model.Pair, gatherBalances bool, conf *config.Config) (bool, []string, error) {
 	messages := make([]string, 0, 2)
 	var (
 		msg       string
@@ -234,163 +215,144 @@ func Book(gatherBalances bool, conf *config.Config) (bool, []string, error) {
 		someError error
 	)
 
+	es := exchange.All()
+	venues := exchange.Venues()
+
+	a, b, err := GatherBooksP(p)
+	if err != nil {
+		return false, nil, fmt.Errorf("books: %w", err)
+	}
+	if conf.MaxBookAge > 0 {
+		if vs := staleBooks(p, conf.MaxBookAge); len(vs) > 0 {
+			fmt.Printf("%v: skipping, stale books: %v\n", p, vs)
+			return gatherBalances, nil, nil
+		}
+	}
+
+	// other pair loops may be spending the same funds: size the orders and
+	// reserve their funds under the lock, then place and settle without it
+	funds.mu.Lock()
 	if gatherBalances {
-		balances, err := GatherBalancesP(conf)
-		if err != nil {
+		if err := funds.refresh(); err != nil {
+			funds.mu.Unlock()
 			return false, nil, fmt.Errorf("balances: %w", err)
 		}
-		bb = balances
 	}
+	bb := funds.snapshot()
 
-	for e, b := range bb {
-		if b.XCH.IsZero() && b.USDT.IsZero() {
-			fmt.Printf("warning: %v balances are zero\n", model.ExchangeType(e).String())
+	for _, e := range es {
+		id := e.Venue().ID
+		if b := bb[id]; b[p.Base].IsZero() && b[p.Quote].IsZero() {
+			fmt.Printf("warning: %v balances are zero\n", id)
 		}
 	}
 
-	fmt.Println(bb)
+	fmt.Println(p, bb)
 
-	a, b, err := GatherBooksP()
-	if err != nil {
-		return false, nil, fmt.Errorf("books: %w", err)
+	as, bs, totalTradeBase, gain, withdrawQuote, withdrawBase, profit, totalBuyQuote, totalSellQuote, totalBuyBase, totalSellBase := arbo(p, a, b, venues, bb, conf)
+
+	minRate := minProfitRate()
+	execute := trading(conf) && profit.IsPositive() && profit.Div(totalTradeBase).GreaterThanOrEqual(minRate)
+	if execute {
+		funds.spend(p, totalBuyQuote, totalSellBase)
 	}
+	funds.mu.Unlock()
+
+	observeProfit(p, profit)
+	plan := newPlan(as, bs, totalTradeBase, gain, withdrawQuote, withdrawBase, profit, totalBuyQuote, totalSellQuote, totalBuyBase, totalSellBase)
+	remember(State{Time: time.Now(), Pair: p, Asks: a, Bids: b, Balances: bb, Plan: plan})
 
-	as, bs, totalTradeXCH, gain, withdrawUSDT, withdrawXCH, profit, totalBuyUSDT, totalSellUSDT, totalBuyXCH, totalSellXCH := arbo(a, b, bb, conf)
+	var (
+		entry    *journal.Entry
+		realized string
+	)
+	if profit.IsPositive() {
+		entry = newEntry(p, as, bs, bb, plan)
+		defer writeEntry(entry)
+	}
 
-	if conf.ExecuteTrades && profit.IsPositive() {
-		var (
-			kOrder *kucoin.OrderModel
-			hOrder *order.GetOrderResponse
-			cOrder *c.OrderResp
-			gOrder *gateapi.Order
-		)
+	if trading(conf) && profit.IsPositive() {
+		var orders map[model.VenueID]*model.OrderStatus
 
-		profitRate := profit.Div(totalTradeXCH)
-		if profitRate.GreaterThanOrEqual(minimumProfitRate) {
-			kID, hID, cOrd, gOrd, err := trade(totalBuyUSDT, totalSellUSDT, totalBuyXCH, totalSellXCH, as.LastPrice, bs.LastPrice, conf)
+		profitRate := profit.Div(totalTradeBase)
+		if execute {
+			ids, err := trade(p, venues, totalBuyQuote, totalSellQuote, totalBuyBase, totalSellBase, as.LastPrice, bs.LastPrice)
+			confirmPlaced(p, ids, totalBuyQuote, totalSellBase)
+			sent(entry, ids)
 			if err != nil {
+				// cancel the legs that were placed before failing
+				orders, cancelled := abort(p, ids)
+				observeFills(orders, cancelled, totalBuyBase)
+				settled(entry, orders, cancelled)
+				entry.Status, entry.Error = journal.Failed, err.Error()
 				return false, nil, fmt.Errorf("trade: %w", err)
 			}
 
-			fmt.Printf("k: %v\nh: %v\nc: %+v\ng: %+v\n", kID, hID, cOrd, gOrd)
-			traded = true
+			for _, e := range es {
+				v := e.Venue().ID
+				if id, ok := ids[v]; ok {
+					fmt.Printf("%v: %v\n", v, id)
+					traded = true
+				}
+			}
 
-			if kID == "" && hID == "" && cOrd == nil && gOrd == nil {
-				traded = false
+			if !traded {
+				entry.Status = journal.BelowMin
 			}
 
-			if kID != "" {
-				kOrd, err := k.GetOrder(kID)
-				if err != nil {
-					fmt.Printf("k GetOrder(%v) error: %v", kID, err)
-				}
-				kOrder = kOrd
+			var cancelled []model.VenueID
+			orders, cancelled = settle(p, ids, conf.CancelAfter)
+			observeFills(orders, cancelled, totalBuyBase)
+			if len(cancelled) > 0 {
+				someError = fmt.Errorf("trade(s) not filled, cancelled on %v", cancelled)
 			}
-			if hID != "" {
-				hOrd, err := h.GetOrder(hID)
-				if err != nil {
-					fmt.Printf("h GetOrder(%v) error: %v", hID, err)
+			if traded {
+				settled(entry, orders, cancelled)
+				if r, _, ok := pnl.Realized(*entry); ok {
+					realized = fmt.Sprintf(realizedTemplate, sigfigs(r.Net), sigfigs(r.Fees), sigfigs(r.Withdrawals))
+					fmt.Println(p, realized)
 				}
-				hOrder = hOrd
 			}
-			cOrder = cOrd
-			gOrder = gOrd
+		} else {
+			entry.Status = journal.LowRate
 		}
 
 		if conf.PEnable {
 			trades := []string{}
 			switch {
-			case profitRate.LessThan(minimumProfitRate):
+			case profitRate.LessThan(minRate):
 				trades = append(trades, fmt.Sprintf("(skipped: profit rate too low: %v)", sigfigs(profitRate)))
 			case !traded:
 				trades = append(trades, "(skipped: below min order threshold)")
 			}
 			trades = append(trades, fmt.Sprintf(profitTemplate, sigfigs(profit)))
+			if realized != "" {
+				trades = append(trades, realized)
+			}
 			filled := true
-			for e, b := range totalBuyXCH {
-				if b.IsPositive() {
-					trades = append(trades, fmt.Sprintf(buyTemplate, model.ExchangeType(e).String(), sigfigs(totalBuyUSDT[e]), sigfigs(b), sigfigs(as.LastPrice[e])))
-					switch e {
-					// case int(model.ExchangeTypeMe):
-					case int(model.ExchangeTypeKu):
-						if kOrder != nil {
-							trades = append(trades, fmt.Sprintf("(%v) fill: $%v, ¢%v; fee: $%v; full?: %t", model.ExchangeTypeKu.String(), kOrder.DealFunds, kOrder.DealSize, kOrder.Fee, !kOrder.IsActive))
-						} else {
-							trades = append(trades, fmt.Sprintf("(%v) nil", model.ExchangeTypeKu.String()))
-						}
-					case int(model.ExchangeTypeHu):
-						if hOrder != nil {
-							trades = append(trades, fmt.Sprintf("(%v) fill: $%v, ¢%v; fee: $%v; state: %v", model.ExchangeTypeHu.String(), hOrder.Data.FilledCashAmount, hOrder.Data.FilledAmount, hOrder.Data.FilledFees, hOrder.Data.State))
-						} else {
-							trades = append(trades, fmt.Sprintf("(%v) nil", model.ExchangeTypeHu.String()))
-						}
-					case int(model.ExchangeTypeCo):
-						if cOrder != nil {
-							trades = append(trades, fmt.Sprintf("(%v) fill: $%v, ¢%v; fee: $%v; status: %v", model.ExchangeTypeCo.String(), cOrder.Order.DealMoney, cOrder.Order.DealAmount, cOrder.Order.DealFee, cOrder.Order.Status))
-						} else {
-							trades = append(trades, fmt.Sprintf("(%v) nil", model.ExchangeTypeCo.String()))
-
-						}
-					case int(model.ExchangeTypeGa):
-						if gOrder != nil {
-							trades = append(trades, fmt.Sprintf("(%v) fill: $%v; fee: $%v; status: %v", model.ExchangeTypeGa.String(), gOrder.FilledTotal, gOrder.Fee, gOrder.Status))
-						} else {
-							trades = append(trades, fmt.Sprintf("(%v) nil", model.ExchangeTypeGa.String()))
-						}
-					}
+			for _, e := range es {
+				v := e.Venue().ID
+				if b := totalBuyBase[v]; b.IsPositive() {
+					trades = append(trades, fmt.Sprintf(buyTemplate, v, sigfigs(totalBuyQuote[v]), sigfigs(b), sigfigs(as.LastPrice[v])))
+					trades = append(trades, fillMessage(v, orders[v]))
 				}
 			}
-			for e, s := range totalSellXCH {
-				if s.IsPositive() {
-					trades = append(trades, fmt.Sprintf(sellTemplate, model.ExchangeType(e).String(), sigfigs(totalSellUSDT[e]), sigfigs(s), sigfigs(bs.LastPrice[e])))
-					switch e {
-					// case int(model.ExchangeTypeMe):
-					case int(model.ExchangeTypeKu):
-						if kOrder != nil {
-							trades = append(trades, fmt.Sprintf("(%v) fill: $%v, ¢%v; fee: $%v; full?: %t", model.ExchangeTypeKu.String(), kOrder.DealFunds, kOrder.DealSize, kOrder.Fee, !kOrder.IsActive))
-							if kOrder.IsActive {
-								filled = false
-							}
-						} else {
-							trades = append(trades, fmt.Sprintf("(%v) nil", model.ExchangeTypeKu.String()))
-						}
-					case int(model.ExchangeTypeHu):
-						if hOrder != nil {
-							trades = append(trades, fmt.Sprintf("(%v) fill: $%v, ¢%v; fee: $%v; state: %v", model.ExchangeTypeHu.String(), hOrder.Data.FilledCashAmount, hOrder.Data.FilledAmount, hOrder.Data.FilledFees, hOrder.Data.State))
-							if hOrder.Data.State != "filled" {
-								filled = false
-							}
-						} else {
-							trades = append(trades, fmt.Sprintf("(%v) nil", model.ExchangeTypeHu.String()))
-						}
-					case int(model.ExchangeTypeCo):
-						if cOrder != nil {
-							trades = append(trades, fmt.Sprintf("(%v) fill: $%v, ¢%v; fee: $%v; status: %v", model.ExchangeTypeCo.String(), cOrder.Order.DealMoney, cOrder.Order.DealAmount, cOrder.Order.DealFee, cOrder.Order.Status))
-							if cOrder.Order.Status != "done" {
-								filled = false
-							}
-						} else {
-							trades = append(trades, fmt.Sprintf("(%v) nil", model.ExchangeTypeCo.String()))
-
-						}
-					case int(model.ExchangeTypeGa):
-						if gOrder != nil {
-							trades = append(trades, fmt.Sprintf("(%v) fill: $%v; fee: $%v; status: %v", model.ExchangeTypeGa.String(), gOrder.FilledTotal, gOrder.Fee, gOrder.Status))
-							if gOrder.Status != "closed" {
-								filled = false
-							}
-						} else {
-							trades = append(trades, fmt.Sprintf("(%v) nil", model.ExchangeTypeGa.String()))
-						}
+			for _, e := range es {
+				v := e.Venue().ID
+				if s := totalSellBase[v]; s.IsPositive() {
+					trades = append(trades, fmt.Sprintf(sellTemplate, v, sigfigs(totalSellQuote[v]), sigfigs(s), sigfigs(bs.LastPrice[v])))
+					trades = append(trades, fillMessage(v, orders[v]))
+					if orders[v] != nil && orders[v].Active {
+						filled = false
 					}
 				}
 			}
-			trades = append(trades, fmt.Sprintf(miscTemplate, sigfigs(totalTradeXCH), sigfigs(gain), sigfigs(withdrawXCH), sigfigs(withdrawUSDT)))
+			trades = append(trades, fmt.Sprintf(miscTemplate, sigfigs(totalTradeBase), sigfigs(gain), sigfigs(withdrawBase), sigfigs(withdrawQuote)))
 
 			msg = strings.Join(trades, "\n")
 			messages = append(messages, msg)
 
-			if !filled {
+			if !filled && someError == nil {
 				someError = errors.New("trade(s) not filled")
 			}
 		}
@@ -403,11 +365,11 @@ func Book(gatherBalances bool, conf *config.Config) (bool, []string, error) {
 	}
 	aDepth := make([]string, 0, as.I+increase)
 	for _, ask := range a[:as.I+increase] {
-		aDepth = append(aDepth, strings.Join([]string{ask.Ex.String(), ask.EffectivePrice.StringFixed(4), ask.Price.StringFixed(4), ask.Amount.String()}, " "))
+		aDepth = append(aDepth, strings.Join([]string{string(ask.Venue), ask.EffectivePrice.StringFixed(4), ask.Price.StringFixed(4), ask.Amount.String()}, " "))
 	}
 	bDepth := make([]string, 0, bs.I+increase)
 	for _, bid := range b[:bs.I+increase] {
-		bDepth = append(bDepth, strings.Join([]string{bid.Ex.String(), bid.EffectivePrice.StringFixed(4), bid.Price.StringFixed(4), bid.Amount.String()}, " "))
+		bDepth = append(bDepth, strings.Join([]string{string(bid.Venue), bid.EffectivePrice.StringFixed(4), bid.Price.StringFixed(4), bid.Amount.String()}, " "))
 	}
 
 	if len(aDepth) > 0 {
@@ -415,34 +377,38 @@ func Book(gatherBalances bool, conf *config.Config) (bool, []string, error) {
 	}
 
 	trades := []string{fmt.Sprintf(profitTemplate, profit)}
-	for e, b := range totalBuyXCH {
-		if b.IsPositive() {
-			trades = append(trades, fmt.Sprintf(buyTemplate, model.ExchangeType(e).String(), totalBuyUSDT[e], b, as.LastPrice[e]))
+	for _, e := range es {
+		v := e.Venue().ID
+		if b := totalBuyBase[v]; b.IsPositive() {
+			trades = append(trades, fmt.Sprintf(buyTemplate, v, totalBuyQuote[v], b, as.LastPrice[v]))
 		}
 	}
-	for e, s := range totalSellXCH {
-		if s.IsPositive() {
-			trades = append(trades, fmt.Sprintf(sellTemplate, model.ExchangeType(e).String(), totalSellUSDT[e], s, bs.LastPrice[e]))
+	for _, e := range es {
+		v := e.Venue().ID
+		if s := totalSellBase[v]; s.IsPositive() {
+			trades = append(trades, fmt.Sprintf(sellTemplate, v, totalSellQuote[v], s, bs.LastPrice[v]))
 		}
 	}
-	trades = append(trades, fmt.Sprintf(miscTemplate, totalTradeXCH, gain, withdrawXCH, withdrawUSDT))
+	trades = append(trades, fmt.Sprintf(miscTemplate, totalTradeBase, gain, withdrawBase, withdrawQuote))
 	msg = strings.Join(trades, "\n")
 	fmt.Println(msg)
 
-	as, bs, totalTradeXCH, gain, withdrawUSDT, withdrawXCH, profit, totalBuyUSDT, totalSellUSDT, totalBuyXCH, totalSellXCH = arbo(a, b, ignoreBalances, conf)
+	as, bs, totalTradeBase, gain, withdrawQuote, withdrawBase, profit, totalBuyQuote, totalSellQuote, totalBuyBase, totalSellBase = arbo(p, a, b, venues, ignoreBalances(p, venues), conf)
 
 	trades = []string{fmt.Sprintf(profitTemplate, profit)}
-	for e, b := range totalBuyXCH {
-		if b.IsPositive() {
-			trades = append(trades, fmt.Sprintf(buyTemplate, model.ExchangeType(e).String(), totalBuyUSDT[e], b, as.LastPrice[e]))
+	for _, e := range es {
+		v := e.Venue().ID
+		if b := totalBuyBase[v]; b.IsPositive() {
+			trades = append(trades, fmt.Sprintf(buyTemplate, v, totalBuyQuote[v], b, as.LastPrice[v]))
 		}
 	}
-	for e, s := range totalSellXCH {
-		if s.IsPositive() {
-			trades = append(trades, fmt.Sprintf(sellTemplate, model.ExchangeType(e).String(), totalSellUSDT[e], s, bs.LastPrice[e]))
+	for _, e := range es {
+		v := e.Venue().ID
+		if s := totalSellBase[v]; s.IsPositive() {
+			trades = append(trades, fmt.Sprintf(sellTemplate, v, totalSellQuote[v], s, bs.LastPrice[v]))
 		}
 	}
-	trades = append(trades, fmt.Sprintf(miscTemplate, totalTradeXCH, gain, withdrawXCH, withdrawUSDT))
+	trades = append(trades, fmt.Sprintf(miscTemplate, totalTradeBase, gain, withdrawBase, withdrawQuote))
 	msg2 := strings.Join(trades, "\n")
 
 	if msg2 != msg {
@@ -457,27 +423,36 @@ func Book(gatherBalances bool, conf *config.Config) (bool, []string, error) {
 	return traded, messages, someError
 }
 
-func arbo(a, b []model.Order, balances [model.ExchangeTypeMax]model.Balances, c *config.Config) (side, side, decimal.Decimal, decimal.Decimal, decimal.Decimal, decimal.Decimal, decimal.Decimal, [model.ExchangeTypeMax]decimal.Decimal, [model.ExchangeTypeMax]decimal.Decimal, [model.ExchangeTypeMax]decimal.Decimal, [model.ExchangeTypeMax]decimal.Decimal) {
-	totalTradeXCH := decimal.Zero
-	totalBuyUSDT := [model.ExchangeTypeMax]decimal.Decimal{}
-	totalSellUSDT := [model.ExchangeTypeMax]decimal.Decimal{}
-	totalBuyXCH := [model.ExchangeTypeMax]decimal.Decimal{}
-	totalSellXCH := [model.ExchangeTypeMax]decimal.Decimal{}
+func arbo(p model.Pair, a, b []model.Order, venues map[model.VenueID]model.Venue, balances map[model.VenueID]model.Balances, c *config.Config) (side, side, decimal.Decimal, decimal.Decimal, decimal.Decimal, decimal.Decimal, decimal.Decimal, map[model.VenueID]decimal.Decimal, map[model.VenueID]decimal.Decimal, map[model.VenueID]decimal.Decimal, map[model.VenueID]decimal.Decimal) {
+	totalTradeBase := decimal.Zero
+	totalBuyQuote := map[model.VenueID]decimal.Decimal{}
+	totalSellQuote := map[model.VenueID]decimal.Decimal{}
+	totalBuyBase := map[model.VenueID]decimal.Decimal{}
+	totalSellBase := map[model.VenueID]decimal.Decimal{}
 	gain := decimal.Zero
 
 	as := &side{
-		Book: a,
+		Book:      a,
+		LastPrice: map[model.VenueID]decimal.Decimal{},
 	}
 	bs := &side{
-		Book: b,
+		Book:      b,
+		LastPrice: map[model.VenueID]decimal.Decimal{},
 	}
 
 	sides := [2]*side{as, bs}
+	taken := [2]map[level]decimal.Decimal{{}, {}}
+	fills := [2]map[model.VenueID][]fill{{}, {}}
+	levelOf := func(o model.Order) level {
+		return level{Ex: venues[o.Venue].Exchange, Price: o.Price.String()}
+	}
 
 	for _, s := range sides {
 		if len(s.Book) > 0 {
 			s.HeadAmount = s.Book[0].Amount
-			s.LastPrice = [model.ExchangeTypeMax]decimal.Decimal{s.Book[0].Price, s.Book[0].Price, s.Book[0].Price, s.Book[0].Price, s.Book[0].Price}
+			for id := range venues {
+				s.LastPrice[id] = s.Book[0].Price
+			}
 		}
 	}
 
@@ -492,18 +467,18 @@ func arbo(a, b []model.Order, balances [model.ExchangeTypeMax]model.Balances, c
 		}
 
 		// deferred update to prevent running off end of array
-		for _, s := range sides {
+		for i, s := range sides {
 			if s.Move {
-				s.HeadAmount = s.Book[s.I].Amount
+				s.HeadAmount = s.Book[s.I].Amount.Sub(taken[i][levelOf(s.Book[s.I])])
 				s.Move = false
 			}
 		}
 
 		// arb
 		// consider balances (allowances)
-		buyAllowanceUSDT := balances[aa.Ex].USDT.Sub(totalBuyUSDT[aa.Ex])
-		as.HeadAllowance = buyAllowanceUSDT.Div(aa.EffectivePrice).RoundDown(3)
-		bs.HeadAllowance = balances[bb.Ex].XCH.Sub(totalSellXCH[bb.Ex]).Mul(decimal.NewFromInt(1).Sub(fees[bb.Ex].MakerTakerRatio))
+		buyAllowanceQuote := balances[aa.Venue][p.Quote].Sub(totalBuyQuote[aa.Venue])
+		as.HeadAllowance = buyAllowanceQuote.Div(aa.EffectivePrice).RoundDown(3)
+		bs.HeadAllowance = balances[bb.Venue][p.Base].Sub(totalSellBase[bb.Venue]).Mul(venues[bb.Venue].FeesOf(p).BidReduction(false))
 		tradeAmount := decimal.Min(as.HeadAmount, bs.HeadAmount, as.HeadAllowance, bs.HeadAllowance)
 
 		for _, s := range sides {
@@ -514,264 +489,255 @@ func arbo(a, b []model.Order, balances [model.ExchangeTypeMax]model.Balances, c
 		}
 
 		// trade executes internally
-		totalTradeXCH = totalTradeXCH.Add(tradeAmount)
-		gain = gain.Add(tradeAmount.Mul(bb.EffectivePrice.Sub(aa.EffectivePrice)))
-		totalBuyUSDT[aa.Ex] = totalBuyUSDT[aa.Ex].Add(aa.EffectivePrice.Mul(tradeAmount))
-		totalSellUSDT[bb.Ex] = totalSellUSDT[bb.Ex].Add(bb.EffectivePrice.Mul(tradeAmount))
-		totalBuyXCH[aa.Ex] = totalBuyXCH[aa.Ex].Add(tradeAmount)
-		totalSellXCH[bb.Ex] = totalSellXCH[bb.Ex].Add(tradeAmount)
-
-		for _, s := range sides {
-			s.LastPrice[s.Book[s.I].Ex] = s.Book[s.I].Price
+		if tradeAmount.IsPositive() {
+			totalTradeBase = totalTradeBase.Add(tradeAmount)
+			gain = gain.Add(tradeAmount.Mul(bb.EffectivePrice.Sub(aa.EffectivePrice)))
+			totalBuyQuote[aa.Venue] = totalBuyQuote[aa.Venue].Add(aa.EffectivePrice.Mul(tradeAmount))
+			totalSellQuote[bb.Venue] = totalSellQuote[bb.Venue].Add(bb.EffectivePrice.Mul(tradeAmount))
+			totalBuyBase[aa.Venue] = totalBuyBase[aa.Venue].Add(tradeAmount)
+			totalSellBase[bb.Venue] = totalSellBase[bb.Venue].Add(tradeAmount)
+			fills[0][aa.Venue] = append(fills[0][aa.Venue], fill{Amount: tradeAmount, EffectivePrice: aa.EffectivePrice})
+			fills[1][bb.Venue] = append(fills[1][bb.Venue], fill{Amount: tradeAmount, EffectivePrice: bb.EffectivePrice})
+		}
+
+		for i, s := range sides {
+			head := s.Book[s.I]
+			s.LastPrice[head.Venue] = head.Price
+			taken[i][levelOf(head)] = taken[i][levelOf(head)].Add(tradeAmount)
 			if s.Move {
 				s.I++
 			}
 		}
 	}
 
+	// pruneBuy drops e's buy along with the same base off s, the only sell,
+	// whose quote is walked again over its own fills
+	pruneBuy := func(e, s model.VenueID) {
+		bBase := totalBuyBase[e]
+		totalTradeBase = totalTradeBase.Sub(bBase)
+		totalSellBase[s] = totalSellBase[s].Sub(bBase)
+		sQuote := fillQuote(fills[1][s], totalSellBase[s])
+		gain = gain.Sub(totalSellQuote[s].Sub(sQuote).Sub(totalBuyQuote[e]))
+		totalSellQuote[s] = sQuote
+		totalBuyBase[e] = decimal.Zero
+		totalBuyQuote[e] = decimal.Zero
+	}
+	// pruneSell drops e's sell along with the same base off b, the only buy
+	pruneSell := func(e, b model.VenueID) {
+		sBase := totalSellBase[e]
+		totalTradeBase = totalTradeBase.Sub(sBase)
+		totalBuyBase[b] = totalBuyBase[b].Sub(sBase)
+		bQuote := fillQuote(fills[0][b], totalBuyBase[b])
+		gain = gain.Sub(totalSellQuote[e].Sub(totalBuyQuote[b].Sub(bQuote)))
+		totalBuyQuote[b] = bQuote
+		totalSellBase[e] = decimal.Zero
+		totalSellQuote[e] = decimal.Zero
+	}
+
 	// TODO walk back (handle > 1 count), also check for unprofitable exchanges (subtract withdrawal fees)
-	for e, bXCH := range totalBuyXCH {
-		mXCH := XCHMinSizes[e]
-		if !mXCH.IsZero() && bXCH.IsPositive() && bXCH.LessThan(mXCH) {
-			sellCount := 0
-			lastIndex := 0
-			for i, sXCH := range totalSellXCH {
-				if sXCH.IsPositive() {
-					sellCount++
-					lastIndex = i
-				}
-			}
-			if sellCount == 1 {
-				totalTradeXCH = totalTradeXCH.Sub(bXCH)
-				totalSellXCH[lastIndex] = totalSellXCH[lastIndex].Sub(bXCH)
-				totalSellUSDT[lastIndex] = totalSellUSDT[lastIndex].Sub(totalBuyUSDT[e]) // approximate
-				totalBuyXCH[e] = decimal.Zero
-				totalBuyUSDT[e] = decimal.Zero
+	for e, bBase := range totalBuyBase {
+		r := venues[e].Rules[p]
+		mBase := r.MinBase
+		if !mBase.IsZero() && bBase.IsPositive() && r.RoundSize(bBase).LessThan(mBase) {
+			if s, ok := only(totalSellBase); ok {
+				pruneBuy(e, s)
 			}
 			continue
 		}
-		bUSDT := totalBuyUSDT[e]
-		mUSDT := USDTMinSizes[e]
-		if !mUSDT.IsZero() && bUSDT.IsPositive() && bUSDT.LessThan(mUSDT) {
-			sellCount := 0
-			lastIndex := 0
-			for i, sXCH := range totalSellXCH {
-				if sXCH.IsPositive() {
-					sellCount++
-					lastIndex = i
-				}
-			}
-			if sellCount == 1 {
-				totalTradeXCH = totalTradeXCH.Sub(bXCH)
-				totalSellXCH[lastIndex] = totalSellXCH[lastIndex].Sub(bXCH)
-				totalSellUSDT[lastIndex] = totalSellUSDT[lastIndex].Sub(totalBuyUSDT[e]) // approximate
-				totalBuyXCH[e] = decimal.Zero
-				totalBuyUSDT[e] = decimal.Zero
+		bQuote := totalBuyQuote[e]
+		mQuote := r.MinQuote
+		if !mQuote.IsZero() && bQuote.IsPositive() && bQuote.LessThan(mQuote) {
+			if s, ok := only(totalSellBase); ok {
+				pruneBuy(e, s)
 			}
 			continue
 		}
 	}
 
-	for e, sXCH := range totalSellXCH {
-		mXCH := XCHMinSizes[e]
-		if !mXCH.IsZero() && sXCH.IsPositive() && sXCH.LessThan(mXCH) {
-			buyCount := 0
-			lastIndex := 0
-			for i, bXCH := range totalBuyXCH {
-				if bXCH.IsPositive() {
-					buyCount++
-					lastIndex = i
-				}
-			}
-			if buyCount == 1 {
-				totalTradeXCH = totalTradeXCH.Sub(sXCH)
-				totalBuyXCH[lastIndex] = totalBuyXCH[lastIndex].Sub(sXCH)
-				totalBuyUSDT[lastIndex] = totalBuyUSDT[lastIndex].Sub(totalBuyUSDT[e]) // approximate
-				totalSellXCH[e] = decimal.Zero
-				totalSellUSDT[e] = decimal.Zero
+	for e, sBase := range totalSellBase {
+		r := venues[e].Rules[p]
+		mBase := r.MinBase
+		if !mBase.IsZero() && sBase.IsPositive() && r.RoundSize(sBase).LessThan(mBase) {
+			if b, ok := only(totalBuyBase); ok {
+				pruneSell(e, b)
 			}
 			continue
 		}
-		sUSDT := totalSellUSDT[e]
-		mUSDT := USDTMinSizes[e]
-		if !mUSDT.IsZero() && sUSDT.IsPositive() && sUSDT.LessThan(mUSDT) {
-			buyCount := 0
-			lastIndex := 0
-			for i, bXCH := range totalBuyXCH {
-				if bXCH.IsPositive() {
-					buyCount++
-					lastIndex = i
-				}
-			}
-			if buyCount == 1 {
-				totalTradeXCH = totalTradeXCH.Sub(sXCH)
-				totalBuyXCH[lastIndex] = totalBuyXCH[lastIndex].Sub(sXCH)
-				totalBuyUSDT[lastIndex] = totalBuyUSDT[lastIndex].Sub(totalBuyUSDT[e]) // approximate
-				totalSellXCH[e] = decimal.Zero
-				totalSellUSDT[e] = decimal.Zero
+		sQuote := totalSellQuote[e]
+		mQuote := r.MinQuote
+		if !mQuote.IsZero() && sQuote.IsPositive() && sQuote.LessThan(mQuote) {
+			if b, ok := only(totalBuyBase); ok {
+				pruneSell(e, b)
 			}
 			continue
 		}
 	}
 
-	// buy XCH -> withdraw XCH
-	withdrawXCH := decimal.Zero
-	withdrawXCHAsUSDT := decimal.Zero
-	for e, b := range totalBuyUSDT {
+	// buy base -> withdraw base to a selling venue
+	routable := true
+	withdrawBase := decimal.Zero
+	withdrawBaseAsQuote := decimal.Zero
+	for e, b := range totalBuyQuote {
 		if b.IsPositive() {
 			ratio := decimal.NewFromInt(1)
-			if b.LessThan(feeRatioCapUSDT) {
-				ratio = b.Div(feeRatioCapUSDT)
+			if b.LessThan(feeRatioCap) {
+				ratio = b.Div(feeRatioCap)
+			}
+			// withdrawals are batched up to feeRatioCap
+			amount := totalBuyBase[e]
+			if price := bs.LastPrice[e]; price.IsPositive() {
+				amount = decimal.Max(amount, feeRatioCap.Div(price))
+			}
+			w, ok := route(venues, e, counterparts(e, totalSellBase), p.Base, amount)
+			if !ok {
+				fmt.Printf("%v: no %v withdrawal route from %v\n", p, p.Base, e)
+				routable = false
+				continue
 			}
-			fee := fees[e].WithdrawalFlatXCH.Mul(ratio)
-			withdrawXCH = withdrawXCH.Add(fee)
-			withdrawXCHAsUSDT = withdrawXCHAsUSDT.Add(fee.Mul(bs.LastPrice[e]))
+			fee := w.Fee.Mul(ratio)
+			withdrawBase = withdrawBase.Add(fee)
+			withdrawBaseAsQuote = withdrawBaseAsQuote.Add(fee.Mul(bs.LastPrice[e]))
 		}
 	}
-	// sell XCH -> withdraw USDT
-	withdrawUSDT := decimal.Zero
-	for e, s := range totalSellUSDT {
+	// sell base -> withdraw quote to a buying venue
+	withdrawQuote := decimal.Zero
+	for e, s := range totalSellQuote {
 		if s.IsPositive() {
 			ratio := decimal.NewFromInt(1)
-			if s.LessThan(feeRatioCapUSDT) {
-				ratio = s.Div(feeRatioCapUSDT)
+			if s.LessThan(feeRatioCap) {
+				ratio = s.Div(feeRatioCap)
 			}
-			withdrawUSDT = withdrawUSDT.Add(fees[e].WithdrawalFlatUSDT.Mul(ratio))
+			w, ok := route(venues, e, counterparts(e, totalBuyBase), p.Quote, decimal.Max(s, feeRatioCap))
+			if !ok {
+				fmt.Printf("%v: no %v withdrawal route from %v\n", p, p.Quote, e)
+				routable = false
+				continue
+			}
+			withdrawQuote = withdrawQuote.Add(w.Fee.Mul(ratio))
 		}
 	}
 
-	profit := gain.Sub(withdrawUSDT).Sub(withdrawXCHAsUSDT)
+	profit := gain.Sub(withdrawQuote).Sub(withdrawBaseAsQuote)
+	// funds that cannot be moved back are not profit
+	if !routable {
+		profit = decimal.Zero
+	}
+
+	return *as, *bs, totalTradeBase, gain, withdrawQuote, withdrawBase, profit, totalBuyQuote, totalSellQuote, totalBuyBase, totalSellBase
+}
 
-	return *as, *bs, totalTradeXCH, gain, withdrawUSDT, withdrawXCH, profit, totalBuyUSDT, totalSellUSDT, totalBuyXCH, totalSellXCH
+// only returns the venue with the only positive amount in m.
+func only(m map[model.VenueID]decimal.Decimal) (model.VenueID, bool) {
+	count := 0
+	var last model.VenueID
+	for v, amt := range m {
+		if amt.IsPositive() {
+			count++
+			last = v
+		}
+	}
+	return last, count == 1
 }
 
-func trade(totalBuyUSDT, totalSellUSDT, totalBuyXCH, totalSellXCH, askPrices, bidPrices [model.ExchangeTypeMax]decimal.Decimal, conf *config.Config) (string, string, *c.OrderResp, *gateapi.Order, error) {
-	var (
-		kOrderID string
-		hOrderID string
-		cOrder   *c.OrderResp
-		gOrder   *gateapi.Order
-	)
+// fillQuote returns the effective quote of the first base of fills.
+func fillQuote(fills []fill, base decimal.Decimal) decimal.Decimal {
+	quote := decimal.Zero
+	for _, f := range fills {
+		if !base.IsPositive() {
+			break
+		}
+		amt := decimal.Min(f.Amount, base)
+		quote = quote.Add(amt.Mul(f.EffectivePrice))
+		base = base.Sub(amt)
+	}
+	return quote
+}
 
-	for e, bXCH := range totalBuyXCH {
-		mXCH := XCHMinSizes[e]
-		if !mXCH.IsZero() && bXCH.IsPositive() && bXCH.LessThan(mXCH) {
-			return "", "", nil, nil, nil
+// placeable reports whether every order meets its venue's minimum size.
+func placeable(p model.Pair, venues map[model.VenueID]model.Venue, totalBuyQuote, totalSellQuote, totalBuyBase, totalSellBase map[model.VenueID]decimal.Decimal) bool {
+	for e, bBase := range totalBuyBase {
+		r := venues[e].Rules[p]
+		mBase := r.MinBase
+		if !mBase.IsZero() && bBase.IsPositive() && r.RoundSize(bBase).LessThan(mBase) {
+			return false
 		}
-		bUSDT := totalBuyUSDT[e]
-		mUSDT := USDTMinSizes[e]
-		if !mUSDT.IsZero() && bUSDT.IsPositive() && bUSDT.LessThan(mUSDT) {
-			return "", "", nil, nil, nil
+		bQuote := totalBuyQuote[e]
+		mQuote := r.MinQuote
+		if !mQuote.IsZero() && bQuote.IsPositive() && bQuote.LessThan(mQuote) {
+			return false
 		}
 	}
 
-	for e, sXCH := range totalSellXCH {
-		mXCH := XCHMinSizes[e]
-		if !mXCH.IsZero() && sXCH.IsPositive() && sXCH.LessThan(mXCH) {
-			return "", "", nil, nil, nil
+	for e, sBase := range totalSellBase {
+		r := venues[e].Rules[p]
+		mBase := r.MinBase
+		if !mBase.IsZero() && sBase.IsPositive() && r.RoundSize(sBase).LessThan(mBase) {
+			return false
 		}
-		sUSDT := totalSellUSDT[e]
-		mUSDT := USDTMinSizes[e]
-		if !mUSDT.IsZero() && sUSDT.IsPositive() && sUSDT.LessThan(mUSDT) {
-			return "", "", nil, nil, nil
+		sQuote := totalSellQuote[e]
+		mQuote := r.MinQuote
+		if !mQuote.IsZero() && sQuote.IsPositive() && sQuote.LessThan(mQuote) {
+			return false
 		}
 	}
 
-	eg, _ := errgroup.WithContext(context.Background())
+	return true
+}
 
-	if totalBuyXCH[model.ExchangeTypeHu].IsPositive() {
-		eg.Go(func() error {
-			oid, err := h.Buy(askPrices[model.ExchangeTypeHu], totalBuyXCH[model.ExchangeTypeHu])
-			if err != nil {
-				return fmt.Errorf("h buy: %w", err)
-			}
-			hOrderID = oid
-			return nil
-		})
-	} else if totalSellXCH[model.ExchangeTypeHu].IsPositive() {
-		eg.Go(func() error {
-			oid, err := h.Sell(bidPrices[model.ExchangeTypeHu], totalSellXCH[model.ExchangeTypeHu])
-			if err != nil {
-				return fmt.Errorf("h sell: %w", err)
-			}
-			hOrderID = oid
-			return nil
-		})
-	}
-	// if totalBuyXCH[model.ExchangeTypeMe].IsPositive() {
-	// 	eg.Go(func() error {
-	// 		// buy
-	// 		return nil
-	// 	})
-	// } else if totalSellXCH[model.ExchangeTypeMe].IsPositive() {
-	// 	eg.Go(func() error {
-	// 		// sell
-	// 		return nil
-	// 	})
-	// }
-	if totalBuyXCH[model.ExchangeTypeKu].IsPositive() {
-		eg.Go(func() error {
-			oid, err := k.Buy(askPrices[model.ExchangeTypeKu], totalBuyXCH[model.ExchangeTypeKu])
-			if err != nil {
-				return fmt.Errorf("k buy: %w", err)
-			}
-			kOrderID = oid
-			return nil
-		})
-	} else if totalSellXCH[model.ExchangeTypeKu].IsPositive() {
-		eg.Go(func() error {
-			oid, err := k.Sell(bidPrices[model.ExchangeTypeKu], totalSellXCH[model.ExchangeTypeKu])
-			if err != nil {
-				return fmt.Errorf("k sell: %w", err)
-			}
-			kOrderID = oid
-			return nil
-		})
-	}
-	if totalBuyXCH[model.ExchangeTypeCo].IsPositive() {
-		eg.Go(func() error { // TODO from here errors
-			resp, err := c.Buy(askPrices[model.ExchangeTypeCo], totalBuyXCH[model.ExchangeTypeCo])
-			if err != nil {
-				return fmt.Errorf("c buy: %w", err)
-			}
-			cOrder = resp
-			return nil
-		})
-	} else if totalSellXCH[model.ExchangeTypeCo].IsPositive() {
-		eg.Go(func() error {
-			resp, err := c.Sell(bidPrices[model.ExchangeTypeCo], totalSellXCH[model.ExchangeTypeCo])
-			if err != nil {
-				return fmt.Errorf("c sell: %w", err)
-			}
-			cOrder = resp
-			return nil
-		})
+func trade(p model.Pair, venues map[model.VenueID]model.Venue, totalBuyQuote, totalSellQuote, totalBuyBase, totalSellBase, askPrices, bidPrices map[model.VenueID]decimal.Decimal) (map[model.VenueID]string, error) {
+	if !placeable(p, venues, totalBuyQuote, totalSellQuote, totalBuyBase, totalSellBase) {
+		return nil, nil
 	}
-	if totalBuyXCH[model.ExchangeTypeGa].IsPositive() {
-		eg.Go(func() error {
-			resp, err := g.Buy(askPrices[model.ExchangeTypeGa], totalBuyXCH[model.ExchangeTypeGa], conf)
-			if err != nil {
-				return fmt.Errorf("g buy: %w", err)
-			}
-			gOrder = &resp
-			return nil
-		})
-	} else if totalSellXCH[model.ExchangeTypeGa].IsPositive() {
-		eg.Go(func() error {
-			resp, err := g.Sell(bidPrices[model.ExchangeTypeGa], totalSellXCH[model.ExchangeTypeGa], conf)
-			if err != nil {
-				return fmt.Errorf("g sell: %w", err)
-			}
-			gOrder = &resp
-			return nil
-		})
+
+	es := exchange.All()
+	oids := make([]string, len(es))
+	eg, _ := errgroup.WithContext(context.Background())
+
+	for i, e := range es {
+		i, e := i, e
+		v := e.Venue().ID
+		if totalBuyBase[v].IsPositive() {
+			eg.Go(func() error {
+				oid, err := e.Buy(p, askPrices[v], totalBuyBase[v])
+				if err != nil {
+					ordersTotal.WithLabelValues(string(v), "buy", "failed").Inc()
+					return fmt.Errorf("%v buy: %w", v, err)
+				}
+				ordersTotal.WithLabelValues(string(v), "buy", "placed").Inc()
+				oids[i] = oid
+				return nil
+			})
+		} else if totalSellBase[v].IsPositive() {
+			eg.Go(func() error {
+				oid, err := e.Sell(p, bidPrices[v], totalSellBase[v])
+				if err != nil {
+					ordersTotal.WithLabelValues(string(v), "sell", "failed").Inc()
+					return fmt.Errorf("%v sell: %w", v, err)
+				}
+				ordersTotal.WithLabelValues(string(v), "sell", "placed").Inc()
+				oids[i] = oid
+				return nil
+			})
+		}
 	}
 
-	if err := eg.Wait(); err != nil {
-		return "", "", nil, nil, err
+	err := eg.Wait()
+
+	ids := make(map[model.VenueID]string, len(es))
+	for i, e := range es {
+		if oids[i] != "" {
+			ids[e.Venue().ID] = oids[i]
+		}
 	}
 
-	return kOrderID, hOrderID, cOrder, gOrder, nil
+	return ids, err
+}
+
+func fillMessage(e model.VenueID, o *model.OrderStatus) string {
+	if o == nil {
+		return fmt.Sprintf("(%v) nil", e)
+	}
+	return fmt.Sprintf(fillTemplate, e, o.FilledFunds, o.Filled, o.Fee, o.FeeCurrency, o.State)
 }
 
 func merge(asc bool, xs ...[]model.Order) []model.Order {
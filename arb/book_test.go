@@ -5,6 +5,7 @@ import (
 
 	"github.com/L3Sota/arbo/arb/config"
 	"github.com/L3Sota/arbo/arb/model"
+	"github.com/L3Sota/arbo/c"
 	"github.com/L3Sota/arbo/g"
 	"github.com/L3Sota/arbo/h"
 	"github.com/L3Sota/arbo/k"
@@ -14,52 +15,52 @@ import (
 	"github.com/shopspring/decimal"
 )
 
+var testVenues = map[model.VenueID]model.Venue{
+	"Me": {ID: "Me", Exchange: model.ExchangeTypeMe, Fees: m.Fees},
+	"Ku": {ID: "Ku", Exchange: model.ExchangeTypeKu, Fees: k.Fees, Rules: k.Rules},
+	"Hu": {ID: "Hu", Exchange: model.ExchangeTypeHu, Fees: h.Fees, Rules: h.Rules},
+	"Co": {ID: "Co", Exchange: model.ExchangeTypeCo, Fees: c.Fees, Rules: c.Rules},
+	"Ga": {ID: "Ga", Exchange: model.ExchangeTypeGa, Fees: g.Fees, Rules: g.Rules},
+}
+
 func TestArbo(t *testing.T) {
 	t.Parallel()
 
 	type arboOut struct {
-		As            side
-		Bs            side
-		TotalTradeXCH decimal.Decimal
-		Gain          decimal.Decimal
-		WithdrawUSDT  decimal.Decimal
-		WithdrawXCH   decimal.Decimal
-		Profit        decimal.Decimal
-		TotalBuyUSDT  [model.ExchangeTypeMax]decimal.Decimal
-		TotalSellUSDT [model.ExchangeTypeMax]decimal.Decimal
-		TotalBuyXCH   [model.ExchangeTypeMax]decimal.Decimal
-		TotalSellXCH  [model.ExchangeTypeMax]decimal.Decimal
+		As             side
+		Bs             side
+		TotalTradeBase decimal.Decimal
+		Gain           decimal.Decimal
+		WithdrawQuote  decimal.Decimal
+		WithdrawBase   decimal.Decimal
+		Profit         decimal.Decimal
+		TotalBuyQuote  map[model.VenueID]decimal.Decimal
+		TotalSellQuote map[model.VenueID]decimal.Decimal
+		TotalBuyBase   map[model.VenueID]decimal.Decimal
+		TotalSellBase  map[model.VenueID]decimal.Decimal
 	}
 
-	empty := [model.ExchangeTypeMax]decimal.Decimal{
-		decimal.Zero,
-		decimal.Zero,
-		decimal.Zero,
-		decimal.Zero,
-		decimal.Zero,
+	// withdrawal fees are spread linearly up to feeRatioCap
+	amortize := func(fee, quote decimal.Decimal) decimal.Decimal {
+		return fee.Mul(quote.Div(feeRatioCap))
 	}
+
 	defaultOut := arboOut{
 		As: side{
 			I:          0,
 			HeadAmount: decimal.Zero,
-			LastPrice:  empty,
 			Move:       false,
 		},
 		Bs: side{
 			I:          0,
 			HeadAmount: decimal.Zero,
-			LastPrice:  empty,
 			Move:       false,
 		},
-		TotalTradeXCH: decimal.Zero,
-		Gain:          decimal.Zero,
-		WithdrawUSDT:  decimal.Zero,
-		WithdrawXCH:   decimal.Zero,
-		Profit:        decimal.Zero,
-		TotalBuyUSDT:  empty,
-		TotalSellUSDT: empty,
-		TotalBuyXCH:   empty,
-		TotalSellXCH:  empty,
+		TotalTradeBase: decimal.Zero,
+		Gain:           decimal.Zero,
+		WithdrawQuote:  decimal.Zero,
+		WithdrawBase:   decimal.Zero,
+		Profit:         decimal.Zero,
 	}
 
 	for name, tc := range map[string]struct {
@@ -75,7 +76,7 @@ func TestArbo(t *testing.T) {
 		"no match (effective price)": {
 			a: []model.Order{
 				{
-					Ex:             model.ExchangeTypeMe,
+					Venue:          "Me",
 					Price:          decimal.NewFromInt(28),
 					EffectivePrice: decimal.NewFromInt(30),
 					Amount:         decimal.NewFromInt(1),
@@ -83,7 +84,7 @@ func TestArbo(t *testing.T) {
 			},
 			b: []model.Order{
 				{
-					Ex:             model.ExchangeTypeKu,
+					Venue:          "Ku",
 					Price:          decimal.NewFromInt(31),
 					EffectivePrice: decimal.NewFromInt(29),
 					Amount:         decimal.NewFromInt(1),
@@ -92,16 +93,16 @@ func TestArbo(t *testing.T) {
 			result: func() arboOut {
 				out := defaultOut
 				out.As.HeadAmount = decimal.NewFromInt(1)
-				out.As.LastPrice = [model.ExchangeTypeMax]decimal.Decimal{decimal.NewFromInt(28), decimal.NewFromInt(28), decimal.NewFromInt(28), decimal.NewFromInt(28), decimal.NewFromInt(28)}
+				out.As.LastPrice = map[model.VenueID]decimal.Decimal{"Me": decimal.NewFromInt(28), "Ku": decimal.NewFromInt(28), "Hu": decimal.NewFromInt(28), "Co": decimal.NewFromInt(28), "Ga": decimal.NewFromInt(28)}
 				out.Bs.HeadAmount = decimal.NewFromInt(1)
-				out.Bs.LastPrice = [model.ExchangeTypeMax]decimal.Decimal{decimal.NewFromInt(31), decimal.NewFromInt(31), decimal.NewFromInt(31), decimal.NewFromInt(31), decimal.NewFromInt(31)}
+				out.Bs.LastPrice = map[model.VenueID]decimal.Decimal{"Me": decimal.NewFromInt(31), "Ku": decimal.NewFromInt(31), "Hu": decimal.NewFromInt(31), "Co": decimal.NewFromInt(31), "Ga": decimal.NewFromInt(31)}
 				return out
 			}(),
 		},
 		"match a < b": {
 			a: []model.Order{
 				{
-					Ex:             model.ExchangeTypeMe,
+					Venue:          "Me",
 					Price:          decimal.NewFromInt(20),
 					EffectivePrice: decimal.NewFromInt(25),
 					Amount:         decimal.NewFromInt(1),
@@ -109,7 +110,7 @@ func TestArbo(t *testing.T) {
 			},
 			b: []model.Order{
 				{
-					Ex:             model.ExchangeTypeKu,
+					Venue:          "Ku",
 					Price:          decimal.NewFromInt(28),
 					EffectivePrice: decimal.NewFromInt(27),
 					Amount:         decimal.NewFromInt(3),
@@ -119,60 +120,44 @@ func TestArbo(t *testing.T) {
 				As: side{
 					I:          1,
 					HeadAmount: decimal.Zero,
-					LastPrice:  [model.ExchangeTypeMax]decimal.Decimal{decimal.NewFromInt(20), decimal.NewFromInt(20), decimal.NewFromInt(20), decimal.NewFromInt(20), decimal.NewFromInt(20)},
+					LastPrice:  map[model.VenueID]decimal.Decimal{"Me": decimal.NewFromInt(20), "Ku": decimal.NewFromInt(20), "Hu": decimal.NewFromInt(20), "Co": decimal.NewFromInt(20), "Ga": decimal.NewFromInt(20)},
 					Move:       true,
 				},
 				Bs: side{
 					I:          0,
 					HeadAmount: decimal.NewFromInt(2), // 3 - 1
-					LastPrice:  [model.ExchangeTypeMax]decimal.Decimal{decimal.NewFromInt(28), decimal.NewFromInt(28), decimal.NewFromInt(28), decimal.NewFromInt(28), decimal.NewFromInt(28)},
+					LastPrice:  map[model.VenueID]decimal.Decimal{"Me": decimal.NewFromInt(28), "Ku": decimal.NewFromInt(28), "Hu": decimal.NewFromInt(28), "Co": decimal.NewFromInt(28), "Ga": decimal.NewFromInt(28)},
 					Move:       false,
 				},
-				TotalTradeXCH: decimal.NewFromInt(1),
-				Gain:          decimal.NewFromInt(2),
-				WithdrawUSDT:  k.Fees.WithdrawalFlatUSDT,
-				WithdrawXCH:   m.Fees.WithdrawalFlatXCH,
-				Profit:        decimal.NewFromInt(2).Sub(k.Fees.WithdrawalFlatUSDT).Sub(m.Fees.WithdrawalFlatXCH.Mul(decimal.NewFromInt(28))),
-				TotalBuyUSDT: [model.ExchangeTypeMax]decimal.Decimal{
-					decimal.NewFromInt(25),
-					decimal.Zero,
-					decimal.Zero,
-					decimal.Zero,
-					decimal.Zero,
-				},
-				TotalSellUSDT: [model.ExchangeTypeMax]decimal.Decimal{
-					decimal.Zero,
-					decimal.NewFromInt(27),
-					decimal.Zero,
-					decimal.Zero,
-					decimal.Zero,
-				},
-				TotalBuyXCH: [model.ExchangeTypeMax]decimal.Decimal{
-					decimal.NewFromInt(1),
-					decimal.Zero,
-					decimal.Zero,
-					decimal.Zero,
-					decimal.Zero,
-				},
-				TotalSellXCH: [model.ExchangeTypeMax]decimal.Decimal{
-					decimal.Zero,
-					decimal.NewFromInt(1),
-					decimal.Zero,
-					decimal.Zero,
-					decimal.Zero,
+				TotalTradeBase: decimal.NewFromInt(1),
+				Gain:           decimal.NewFromInt(2),
+				WithdrawQuote:  amortize(k.Fees.Withdrawals["USDT"][0].Fee, decimal.NewFromInt(27)),
+				WithdrawBase:   amortize(m.Fees.Withdrawals["XCH"][0].Fee, decimal.NewFromInt(25)),
+				Profit:         decimal.NewFromInt(2).Sub(amortize(k.Fees.Withdrawals["USDT"][0].Fee, decimal.NewFromInt(27))).Sub(amortize(m.Fees.Withdrawals["XCH"][0].Fee, decimal.NewFromInt(25)).Mul(decimal.NewFromInt(28))),
+				TotalBuyQuote: map[model.VenueID]decimal.Decimal{
+					"Me": decimal.NewFromInt(25),
+				},
+				TotalSellQuote: map[model.VenueID]decimal.Decimal{
+					"Ku": decimal.NewFromInt(27),
+				},
+				TotalBuyBase: map[model.VenueID]decimal.Decimal{
+					"Me": decimal.NewFromInt(1),
+				},
+				TotalSellBase: map[model.VenueID]decimal.Decimal{
+					"Ku": decimal.NewFromInt(1),
 				},
 			},
 		},
 		"match a < b, a > b": {
 			a: []model.Order{
 				{
-					Ex:             model.ExchangeTypeMe,
+					Venue:          "Me",
 					Price:          decimal.NewFromInt(20),
 					EffectivePrice: decimal.NewFromInt(25),
 					Amount:         decimal.NewFromInt(1),
 				},
 				{
-					Ex:             model.ExchangeTypeMe,
+					Venue:          "Me",
 					Price:          decimal.NewFromInt(21),
 					EffectivePrice: decimal.NewFromInt(26),
 					Amount:         decimal.NewFromInt(3),
@@ -180,7 +165,7 @@ func TestArbo(t *testing.T) {
 			},
 			b: []model.Order{
 				{
-					Ex:             model.ExchangeTypeKu,
+					Venue:          "Ku",
 					Price:          decimal.NewFromInt(28),
 					EffectivePrice: decimal.NewFromInt(27),
 					Amount:         decimal.NewFromInt(3),
@@ -190,60 +175,44 @@ func TestArbo(t *testing.T) {
 				As: side{
 					I:          1,
 					HeadAmount: decimal.NewFromInt(1),
-					LastPrice:  [model.ExchangeTypeMax]decimal.Decimal{decimal.NewFromInt(21), decimal.NewFromInt(20), decimal.NewFromInt(20), decimal.NewFromInt(20), decimal.NewFromInt(20)},
+					LastPrice:  map[model.VenueID]decimal.Decimal{"Me": decimal.NewFromInt(21), "Ku": decimal.NewFromInt(20), "Hu": decimal.NewFromInt(20), "Co": decimal.NewFromInt(20), "Ga": decimal.NewFromInt(20)},
 					Move:       false,
 				},
 				Bs: side{
 					I:          1,
 					HeadAmount: decimal.Zero,
-					LastPrice:  [model.ExchangeTypeMax]decimal.Decimal{decimal.NewFromInt(28), decimal.NewFromInt(28), decimal.NewFromInt(28), decimal.NewFromInt(28), decimal.NewFromInt(28)},
+					LastPrice:  map[model.VenueID]decimal.Decimal{"Me": decimal.NewFromInt(28), "Ku": decimal.NewFromInt(28), "Hu": decimal.NewFromInt(28), "Co": decimal.NewFromInt(28), "Ga": decimal.NewFromInt(28)},
 					Move:       true,
 				},
-				TotalTradeXCH: decimal.NewFromInt(3),
-				Gain:          decimal.NewFromInt(4), // 2 + 2
-				WithdrawUSDT:  k.Fees.WithdrawalFlatUSDT,
-				WithdrawXCH:   m.Fees.WithdrawalFlatXCH,
-				Profit:        decimal.NewFromInt(4).Sub(k.Fees.WithdrawalFlatUSDT).Sub(m.Fees.WithdrawalFlatXCH.Mul(decimal.NewFromInt(28))),
-				TotalBuyUSDT: [model.ExchangeTypeMax]decimal.Decimal{
-					decimal.NewFromInt(77), // 25 + 2*26
-					decimal.Zero,
-					decimal.Zero,
-					decimal.Zero,
-					decimal.Zero,
-				},
-				TotalSellUSDT: [model.ExchangeTypeMax]decimal.Decimal{
-					decimal.Zero,
-					decimal.NewFromInt(81), // 3*27
-					decimal.Zero,
-					decimal.Zero,
-					decimal.Zero,
-				},
-				TotalBuyXCH: [model.ExchangeTypeMax]decimal.Decimal{
-					decimal.NewFromInt(3),
-					decimal.Zero,
-					decimal.Zero,
-					decimal.Zero,
-					decimal.Zero,
-				},
-				TotalSellXCH: [model.ExchangeTypeMax]decimal.Decimal{
-					decimal.Zero,
-					decimal.NewFromInt(3),
-					decimal.Zero,
-					decimal.Zero,
-					decimal.Zero,
+				TotalTradeBase: decimal.NewFromInt(3),
+				Gain:           decimal.NewFromInt(4), // 2 + 2
+				WithdrawQuote:  amortize(k.Fees.Withdrawals["USDT"][0].Fee, decimal.NewFromInt(81)),
+				WithdrawBase:   amortize(m.Fees.Withdrawals["XCH"][0].Fee, decimal.NewFromInt(77)),
+				Profit:         decimal.NewFromInt(4).Sub(amortize(k.Fees.Withdrawals["USDT"][0].Fee, decimal.NewFromInt(81))).Sub(amortize(m.Fees.Withdrawals["XCH"][0].Fee, decimal.NewFromInt(77)).Mul(decimal.NewFromInt(28))),
+				TotalBuyQuote: map[model.VenueID]decimal.Decimal{
+					"Me": decimal.NewFromInt(77), // 25 + 2*26
+				},
+				TotalSellQuote: map[model.VenueID]decimal.Decimal{
+					"Ku": decimal.NewFromInt(81), // 3*27
+				},
+				TotalBuyBase: map[model.VenueID]decimal.Decimal{
+					"Me": decimal.NewFromInt(3),
+				},
+				TotalSellBase: map[model.VenueID]decimal.Decimal{
+					"Ku": decimal.NewFromInt(3),
 				},
 			},
 		},
 		"match a < b, a > b, a = b": {
 			a: []model.Order{
 				{
-					Ex:             model.ExchangeTypeMe,
+					Venue:          "Me",
 					Price:          decimal.NewFromInt(20),
 					EffectivePrice: decimal.NewFromInt(25),
 					Amount:         decimal.NewFromInt(1),
 				},
 				{
-					Ex:             model.ExchangeTypeMe,
+					Venue:          "Me",
 					Price:          decimal.NewFromInt(21),
 					EffectivePrice: decimal.NewFromInt(26),
 					Amount:         decimal.NewFromInt(3),
@@ -251,13 +220,13 @@ func TestArbo(t *testing.T) {
 			},
 			b: []model.Order{
 				{
-					Ex:             model.ExchangeTypeKu,
+					Venue:          "Ku",
 					Price:          decimal.NewFromInt(28),
 					EffectivePrice: decimal.NewFromInt(27),
 					Amount:         decimal.NewFromInt(3),
 				},
 				{
-					Ex:             model.ExchangeTypeHu,
+					Venue:          "Hu",
 					Price:          decimal.NewFromInt(29),
 					EffectivePrice: decimal.NewFromInt(27),
 					Amount:         decimal.NewFromInt(1),
@@ -267,66 +236,52 @@ func TestArbo(t *testing.T) {
 				As: side{
 					I:          2,
 					HeadAmount: decimal.Zero,
-					LastPrice:  [model.ExchangeTypeMax]decimal.Decimal{decimal.NewFromInt(21), decimal.NewFromInt(20), decimal.NewFromInt(20), decimal.NewFromInt(20), decimal.NewFromInt(20)},
+					LastPrice:  map[model.VenueID]decimal.Decimal{"Me": decimal.NewFromInt(21), "Ku": decimal.NewFromInt(20), "Hu": decimal.NewFromInt(20), "Co": decimal.NewFromInt(20), "Ga": decimal.NewFromInt(20)},
 					Move:       true,
 				},
 				Bs: side{
 					I:          2,
 					HeadAmount: decimal.Zero,
-					LastPrice:  [model.ExchangeTypeMax]decimal.Decimal{decimal.NewFromInt(28), decimal.NewFromInt(28), decimal.NewFromInt(29), decimal.NewFromInt(28), decimal.NewFromInt(28)},
+					LastPrice:  map[model.VenueID]decimal.Decimal{"Me": decimal.NewFromInt(28), "Ku": decimal.NewFromInt(28), "Hu": decimal.NewFromInt(29), "Co": decimal.NewFromInt(28), "Ga": decimal.NewFromInt(28)},
 					Move:       true,
 				},
-				TotalTradeXCH: decimal.NewFromInt(4),
-				Gain:          decimal.NewFromInt(5), // 2 + 2 + 1
-				WithdrawUSDT:  k.Fees.WithdrawalFlatUSDT.Add(h.Fees.WithdrawalFlatUSDT),
-				WithdrawXCH:   m.Fees.WithdrawalFlatXCH,
-				Profit:        decimal.NewFromInt(5).Sub(k.Fees.WithdrawalFlatUSDT).Sub(h.Fees.WithdrawalFlatUSDT).Sub(m.Fees.WithdrawalFlatXCH.Mul(decimal.NewFromInt(28))),
-				TotalBuyUSDT: [model.ExchangeTypeMax]decimal.Decimal{
-					decimal.NewFromInt(103), // 25 + 3*26
-					decimal.Zero,
-					decimal.Zero,
-					decimal.Zero,
-					decimal.Zero,
-				},
-				TotalSellUSDT: [model.ExchangeTypeMax]decimal.Decimal{
-					decimal.Zero,
-					decimal.NewFromInt(81), // 3*27
-					decimal.NewFromInt(27), // 27
-					decimal.Zero,
-					decimal.Zero,
-				},
-				TotalBuyXCH: [model.ExchangeTypeMax]decimal.Decimal{
-					decimal.NewFromInt(4),
-					decimal.Zero,
-					decimal.Zero,
-					decimal.Zero,
-					decimal.Zero,
-				},
-				TotalSellXCH: [model.ExchangeTypeMax]decimal.Decimal{
-					decimal.Zero,
-					decimal.NewFromInt(3),
-					decimal.NewFromInt(1),
-					decimal.Zero,
-					decimal.Zero,
+				TotalTradeBase: decimal.NewFromInt(4),
+				Gain:           decimal.NewFromInt(5), // 2 + 2 + 1
+				WithdrawQuote:  amortize(k.Fees.Withdrawals["USDT"][0].Fee, decimal.NewFromInt(81)).Add(amortize(h.Fees.Withdrawals["USDT"][0].Fee, decimal.NewFromInt(27))),
+				WithdrawBase:   amortize(m.Fees.Withdrawals["XCH"][0].Fee, decimal.NewFromInt(103)),
+				Profit:         decimal.NewFromInt(5).Sub(amortize(k.Fees.Withdrawals["USDT"][0].Fee, decimal.NewFromInt(81))).Sub(amortize(h.Fees.Withdrawals["USDT"][0].Fee, decimal.NewFromInt(27))).Sub(amortize(m.Fees.Withdrawals["XCH"][0].Fee, decimal.NewFromInt(103)).Mul(decimal.NewFromInt(28))),
+				TotalBuyQuote: map[model.VenueID]decimal.Decimal{
+					"Me": decimal.NewFromInt(103), // 25 + 3*26
+				},
+				TotalSellQuote: map[model.VenueID]decimal.Decimal{
+					"Ku": decimal.NewFromInt(81), // 3*27
+					"Hu": decimal.NewFromInt(27), // 27
+				},
+				TotalBuyBase: map[model.VenueID]decimal.Decimal{
+					"Me": decimal.NewFromInt(4),
+				},
+				TotalSellBase: map[model.VenueID]decimal.Decimal{
+					"Ku": decimal.NewFromInt(3),
+					"Hu": decimal.NewFromInt(1),
 				},
 			},
 		},
 		"match a < b, a > b, a = b, no further match": {
 			a: []model.Order{
 				{
-					Ex:             model.ExchangeTypeMe,
+					Venue:          "Me",
 					Price:          decimal.NewFromInt(20),
 					EffectivePrice: decimal.NewFromInt(25),
 					Amount:         decimal.NewFromInt(1),
 				},
 				{
-					Ex:             model.ExchangeTypeMe,
+					Venue:          "Me",
 					Price:          decimal.NewFromInt(21),
 					EffectivePrice: decimal.NewFromInt(26),
 					Amount:         decimal.NewFromInt(3),
 				},
 				{
-					Ex:             model.ExchangeTypeCo,
+					Venue:          "Co",
 					Price:          decimal.NewFromInt(22),
 					EffectivePrice: decimal.NewFromInt(27),
 					Amount:         decimal.NewFromInt(100),
@@ -334,19 +289,19 @@ func TestArbo(t *testing.T) {
 			},
 			b: []model.Order{
 				{
-					Ex:             model.ExchangeTypeKu,
+					Venue:          "Ku",
 					Price:          decimal.NewFromInt(28),
 					EffectivePrice: decimal.NewFromInt(27),
 					Amount:         decimal.NewFromInt(3),
 				},
 				{
-					Ex:             model.ExchangeTypeHu,
+					Venue:          "Hu",
 					Price:          decimal.NewFromInt(29),
 					EffectivePrice: decimal.NewFromInt(27),
 					Amount:         decimal.NewFromInt(1),
 				},
 				{
-					Ex:             model.ExchangeTypeGa,
+					Venue:          "Ga",
 					Price:          decimal.NewFromInt(30),
 					EffectivePrice: decimal.NewFromInt(27),
 					Amount:         decimal.NewFromInt(10),
@@ -356,47 +311,33 @@ func TestArbo(t *testing.T) {
 				As: side{
 					I:          2,
 					HeadAmount: decimal.Zero,
-					LastPrice:  [model.ExchangeTypeMax]decimal.Decimal{decimal.NewFromInt(21), decimal.NewFromInt(20), decimal.NewFromInt(20), decimal.NewFromInt(20), decimal.NewFromInt(20)},
+					LastPrice:  map[model.VenueID]decimal.Decimal{"Me": decimal.NewFromInt(21), "Ku": decimal.NewFromInt(20), "Hu": decimal.NewFromInt(20), "Co": decimal.NewFromInt(20), "Ga": decimal.NewFromInt(20)},
 					Move:       true,
 				},
 				Bs: side{
 					I:          2,
 					HeadAmount: decimal.Zero,
-					LastPrice:  [model.ExchangeTypeMax]decimal.Decimal{decimal.NewFromInt(28), decimal.NewFromInt(28), decimal.NewFromInt(29), decimal.NewFromInt(28), decimal.NewFromInt(28)},
+					LastPrice:  map[model.VenueID]decimal.Decimal{"Me": decimal.NewFromInt(28), "Ku": decimal.NewFromInt(28), "Hu": decimal.NewFromInt(29), "Co": decimal.NewFromInt(28), "Ga": decimal.NewFromInt(28)},
 					Move:       true,
 				},
-				TotalTradeXCH: decimal.NewFromInt(4),
-				Gain:          decimal.NewFromInt(5), // 2 + 2 + 1
-				WithdrawUSDT:  k.Fees.WithdrawalFlatUSDT.Add(h.Fees.WithdrawalFlatUSDT),
-				WithdrawXCH:   m.Fees.WithdrawalFlatXCH,
-				Profit:        decimal.NewFromInt(5).Sub(k.Fees.WithdrawalFlatUSDT).Sub(h.Fees.WithdrawalFlatUSDT).Sub(m.Fees.WithdrawalFlatXCH.Mul(decimal.NewFromInt(28))),
-				TotalBuyUSDT: [model.ExchangeTypeMax]decimal.Decimal{
-					decimal.NewFromInt(103), // 25 + 3*26
-					decimal.Zero,
-					decimal.Zero,
-					decimal.Zero,
-					decimal.Zero,
-				},
-				TotalSellUSDT: [model.ExchangeTypeMax]decimal.Decimal{
-					decimal.Zero,
-					decimal.NewFromInt(81), // 3*27
-					decimal.NewFromInt(27), // 27
-					decimal.Zero,
-					decimal.Zero,
-				},
-				TotalBuyXCH: [model.ExchangeTypeMax]decimal.Decimal{
-					decimal.NewFromInt(4),
-					decimal.Zero,
-					decimal.Zero,
-					decimal.Zero,
-					decimal.Zero,
-				},
-				TotalSellXCH: [model.ExchangeTypeMax]decimal.Decimal{
-					decimal.Zero,
-					decimal.NewFromInt(3),
-					decimal.NewFromInt(1),
-					decimal.Zero,
-					decimal.Zero,
+				TotalTradeBase: decimal.NewFromInt(4),
+				Gain:           decimal.NewFromInt(5), // 2 + 2 + 1
+				WithdrawQuote:  amortize(k.Fees.Withdrawals["USDT"][0].Fee, decimal.NewFromInt(81)).Add(amortize(h.Fees.Withdrawals["USDT"][0].Fee, decimal.NewFromInt(27))),
+				WithdrawBase:   amortize(m.Fees.Withdrawals["XCH"][0].Fee, decimal.NewFromInt(103)),
+				Profit:         decimal.NewFromInt(5).Sub(amortize(k.Fees.Withdrawals["USDT"][0].Fee, decimal.NewFromInt(81))).Sub(amortize(h.Fees.Withdrawals["USDT"][0].Fee, decimal.NewFromInt(27))).Sub(amortize(m.Fees.Withdrawals["XCH"][0].Fee, decimal.NewFromInt(103)).Mul(decimal.NewFromInt(28))),
+				TotalBuyQuote: map[model.VenueID]decimal.Decimal{
+					"Me": decimal.NewFromInt(103), // 25 + 3*26
+				},
+				TotalSellQuote: map[model.VenueID]decimal.Decimal{
+					"Ku": decimal.NewFromInt(81), // 3*27
+					"Hu": decimal.NewFromInt(27), // 27
+				},
+				TotalBuyBase: map[model.VenueID]decimal.Decimal{
+					"Me": decimal.NewFromInt(4),
+				},
+				TotalSellBase: map[model.VenueID]decimal.Decimal{
+					"Ku": decimal.NewFromInt(3),
+					"Hu": decimal.NewFromInt(1),
 				},
 			},
 		},
@@ -407,9 +348,9 @@ func TestArbo(t *testing.T) {
 
 			tc.result.As.Book = tc.a
 			tc.result.Bs.Book = tc.b
-			as, bs, totalTradeQuote, gain, withdrawUSDT, withdrawXCH, profit, totalBuyBase, totalSellBase, totalBuyXCH, totalSellXCH := arbo(tc.a, tc.b, ignoreBalances, &config.Config{})
+			as, bs, totalTradeBase, gain, withdrawQuote, withdrawBase, profit, totalBuyQuote, totalSellQuote, totalBuyBase, totalSellBase := arbo(model.XCHUSDT, tc.a, tc.b, testVenues, ignoreBalances(model.XCHUSDT, testVenues), &config.Config{})
 			if diff := cmp.Diff(tc.result, arboOut{
-				as, bs, totalTradeQuote, gain, withdrawUSDT, withdrawXCH, profit, totalBuyBase, totalSellBase, totalBuyXCH, totalSellXCH,
+				as, bs, totalTradeBase, gain, withdrawQuote, withdrawBase, profit, totalBuyQuote, totalSellQuote, totalBuyBase, totalSellBase,
 			}, cmpopts.EquateEmpty(), cmpopts.IgnoreFields(side{}, "HeadAllowance")); diff != "" {
 				t.Errorf("-want/+got: %v", diff)
 			}
@@ -419,25 +360,25 @@ func TestArbo(t *testing.T) {
 	for name, tc := range map[string]struct {
 		a        []model.Order
 		b        []model.Order
-		balances [model.ExchangeTypeMax]model.Balances
+		balances map[model.VenueID]model.Balances
 		result   arboOut
 	}{
 		"match a < b, a > b, balance constrained on a, no further match": {
 			a: []model.Order{
 				{
-					Ex:             model.ExchangeTypeMe,
+					Venue:          "Me",
 					Price:          decimal.NewFromInt(20),
 					EffectivePrice: decimal.NewFromInt(25),
 					Amount:         decimal.NewFromInt(1),
 				},
 				{
-					Ex:             model.ExchangeTypeMe,
+					Venue:          "Me",
 					Price:          decimal.NewFromInt(21),
 					EffectivePrice: decimal.NewFromInt(26),
 					Amount:         decimal.NewFromInt(3),
 				},
 				{
-					Ex:             model.ExchangeTypeCo,
+					Venue:          "Co",
 					Price:          decimal.NewFromInt(22),
 					EffectivePrice: decimal.NewFromInt(27),
 					Amount:         decimal.NewFromInt(100),
@@ -445,81 +386,67 @@ func TestArbo(t *testing.T) {
 			},
 			b: []model.Order{
 				{
-					Ex:             model.ExchangeTypeKu,
+					Venue:          "Ku",
 					Price:          decimal.NewFromInt(28),
 					EffectivePrice: decimal.NewFromInt(27),
 					Amount:         decimal.NewFromInt(3),
 				},
 				{
-					Ex:             model.ExchangeTypeGa,
+					Venue:          "Ga",
 					Price:          decimal.NewFromInt(29),
 					EffectivePrice: decimal.NewFromInt(27),
 					Amount:         decimal.NewFromInt(1),
 				},
 				{
-					Ex:             model.ExchangeTypeGa,
+					Venue:          "Ga",
 					Price:          decimal.NewFromInt(30),
 					EffectivePrice: decimal.NewFromInt(27),
 					Amount:         decimal.NewFromInt(10),
 				},
 			},
-			balances: [model.ExchangeTypeMax]model.Balances{
-				{ // Me
-					XCH:  decimal.Zero,
-					USDT: decimal.NewFromInt(90),
-				},
-				bigBalance,
-				bigBalance,
-				bigBalance,
-				bigBalance,
+			balances: map[model.VenueID]model.Balances{
+				"Me": {
+					"XCH":  decimal.Zero,
+					"USDT": decimal.NewFromInt(90),
+				},
+				"Ku": {"XCH": big, "USDT": big},
+				"Hu": {"XCH": big, "USDT": big},
+				"Co": {"XCH": big, "USDT": big},
+				"Ga": {"XCH": big, "USDT": big},
 			},
 			result: arboOut{
 				As: side{
 					I:             2,
 					HeadAmount:    decimal.NewFromFloat(0.5),
 					HeadAllowance: decimal.NewFromFloat(0.5),
-					LastPrice:     [model.ExchangeTypeMax]decimal.Decimal{decimal.NewFromInt(21), decimal.NewFromInt(20), decimal.NewFromInt(20), decimal.NewFromInt(20), decimal.NewFromInt(20)},
+					LastPrice:     map[model.VenueID]decimal.Decimal{"Me": decimal.NewFromInt(21), "Ku": decimal.NewFromInt(20), "Hu": decimal.NewFromInt(20), "Co": decimal.NewFromInt(20), "Ga": decimal.NewFromInt(20)},
 					Move:          true,
 				},
 				Bs: side{
 					I:             1,
 					HeadAmount:    decimal.NewFromFloat(0.5),
-					HeadAllowance: big.Mul(g.BidReduction),
-					LastPrice:     [model.ExchangeTypeMax]decimal.Decimal{decimal.NewFromInt(28), decimal.NewFromInt(28), decimal.NewFromInt(28), decimal.NewFromInt(28), decimal.NewFromInt(29)},
+					HeadAllowance: big.Mul(g.Fees.BidReduction(false)),
+					LastPrice:     map[model.VenueID]decimal.Decimal{"Me": decimal.NewFromInt(28), "Ku": decimal.NewFromInt(28), "Hu": decimal.NewFromInt(28), "Co": decimal.NewFromInt(28), "Ga": decimal.NewFromInt(29)},
 					Move:          false,
 				},
-				TotalTradeXCH: decimal.NewFromFloat(3.5),
-				Gain:          decimal.NewFromFloat(4.5), // 2 + 2 + 0.5
-				WithdrawUSDT:  k.Fees.WithdrawalFlatUSDT.Add(g.Fees.WithdrawalFlatUSDT),
-				WithdrawXCH:   m.Fees.WithdrawalFlatXCH,
-				Profit:        decimal.NewFromFloat(4.5).Sub(k.Fees.WithdrawalFlatUSDT).Sub(g.Fees.WithdrawalFlatUSDT).Sub(m.Fees.WithdrawalFlatXCH.Mul(decimal.NewFromInt(28))),
-				TotalBuyUSDT: [model.ExchangeTypeMax]decimal.Decimal{
-					decimal.NewFromInt(90), // balance
-					decimal.Zero,
-					decimal.Zero,
-					decimal.Zero,
-					decimal.Zero,
-				},
-				TotalSellUSDT: [model.ExchangeTypeMax]decimal.Decimal{
-					decimal.Zero,
-					decimal.NewFromInt(81), // 3*27
-					decimal.Zero,
-					decimal.Zero,
-					decimal.NewFromFloat(13.5), // 0.5*27
-				},
-				TotalBuyXCH: [model.ExchangeTypeMax]decimal.Decimal{
-					decimal.NewFromFloat(3.5),
-					decimal.Zero,
-					decimal.Zero,
-					decimal.Zero,
-					decimal.Zero,
-				},
-				TotalSellXCH: [model.ExchangeTypeMax]decimal.Decimal{
-					decimal.Zero,
-					decimal.NewFromInt(3),
-					decimal.Zero,
-					decimal.Zero,
-					decimal.NewFromFloat(0.5),
+				TotalTradeBase: decimal.NewFromFloat(3.5),
+				Gain:           decimal.NewFromFloat(4.5), // 2 + 2 + 0.5
+				WithdrawQuote:  amortize(k.Fees.Withdrawals["USDT"][0].Fee, decimal.NewFromInt(81)).Add(amortize(g.Fees.Withdrawals["USDT"][0].Fee, decimal.NewFromFloat(13.5))),
+				WithdrawBase:   amortize(m.Fees.Withdrawals["XCH"][0].Fee, decimal.NewFromInt(90)),
+				Profit:         decimal.NewFromFloat(4.5).Sub(amortize(k.Fees.Withdrawals["USDT"][0].Fee, decimal.NewFromInt(81))).Sub(amortize(g.Fees.Withdrawals["USDT"][0].Fee, decimal.NewFromFloat(13.5))).Sub(amortize(m.Fees.Withdrawals["XCH"][0].Fee, decimal.NewFromInt(90)).Mul(decimal.NewFromInt(28))),
+				TotalBuyQuote: map[model.VenueID]decimal.Decimal{
+					"Me": decimal.NewFromInt(90), // balance
+				},
+				TotalSellQuote: map[model.VenueID]decimal.Decimal{
+					"Ku": decimal.NewFromInt(81),     // 3*27
+					"Ga": decimal.NewFromFloat(13.5), // 0.5*27
+				},
+				TotalBuyBase: map[model.VenueID]decimal.Decimal{
+					"Me": decimal.NewFromFloat(3.5),
+				},
+				TotalSellBase: map[model.VenueID]decimal.Decimal{
+					"Ku": decimal.NewFromInt(3),
+					"Ga": decimal.NewFromFloat(0.5),
 				},
 			},
 		},
@@ -530,9 +457,9 @@ func TestArbo(t *testing.T) {
 
 			tc.result.As.Book = tc.a
 			tc.result.Bs.Book = tc.b
-			as, bs, totalTradeQuote, gain, withdrawUSDT, withdrawXCH, profit, totalBuyBase, totalSellBase, totalBuyXCH, totalSellXCH := arbo(tc.a, tc.b, tc.balances, &config.Config{})
+			as, bs, totalTradeBase, gain, withdrawQuote, withdrawBase, profit, totalBuyQuote, totalSellQuote, totalBuyBase, totalSellBase := arbo(model.XCHUSDT, tc.a, tc.b, testVenues, tc.balances, &config.Config{})
 			if diff := cmp.Diff(tc.result, arboOut{
-				as, bs, totalTradeQuote, gain, withdrawUSDT, withdrawXCH, profit, totalBuyBase, totalSellBase, totalBuyXCH, totalSellXCH,
+				as, bs, totalTradeBase, gain, withdrawQuote, withdrawBase, profit, totalBuyQuote, totalSellQuote, totalBuyBase, totalSellBase,
 			}, cmpopts.EquateEmpty()); diff != "" {
 				t.Errorf("-want/+got: %v", diff)
 			}
@@ -541,10 +468,110 @@ func TestArbo(t *testing.T) {
 
 }
 
+func TestArboSharedBook(t *testing.T) {
+	t.Parallel()
+
+	venues := map[model.VenueID]model.Venue{
+		"Ku":  testVenues["Ku"],
+		"Ku2": {ID: "Ku2", Exchange: model.ExchangeTypeKu, Fees: k.Fees, Rules: k.Rules},
+		"Ga":  testVenues["Ga"],
+	}
+	// both Ku accounts see the same ask
+	a := []model.Order{
+		{
+			Venue:          "Ku",
+			Price:          decimal.NewFromInt(20),
+			EffectivePrice: decimal.NewFromInt(25),
+			Amount:         decimal.NewFromInt(1),
+		},
+		{
+			Venue:          "Ku2",
+			Price:          decimal.NewFromInt(20),
+			EffectivePrice: decimal.NewFromInt(25),
+			Amount:         decimal.NewFromInt(1),
+		},
+	}
+	b := []model.Order{
+		{
+			Venue:          "Ga",
+			Price:          decimal.NewFromInt(28),
+			EffectivePrice: decimal.NewFromInt(27),
+			Amount:         decimal.NewFromInt(10),
+		},
+	}
+
+	_, _, totalTradeBase, gain, _, _, _, _, _, totalBuyBase, totalSellBase := arbo(model.XCHUSDT, a, b, venues, ignoreBalances(model.XCHUSDT, venues), &config.Config{})
+	if !totalTradeBase.Equal(decimal.NewFromInt(1)) {
+		t.Errorf("totalTradeBase: want 1, got %v", totalTradeBase)
+	}
+	if !gain.Equal(decimal.NewFromInt(2)) {
+		t.Errorf("gain: want 2, got %v", gain)
+	}
+	if diff := cmp.Diff(map[model.VenueID]decimal.Decimal{"Ku": decimal.NewFromInt(1)}, totalBuyBase); diff != "" {
+		t.Errorf("totalBuyBase -want/+got: %v", diff)
+	}
+	if diff := cmp.Diff(map[model.VenueID]decimal.Decimal{"Ga": decimal.NewFromInt(1)}, totalSellBase); diff != "" {
+		t.Errorf("totalSellBase -want/+got: %v", diff)
+	}
+}
+
+func TestArboPrunesSmallSell(t *testing.T) {
+	t.Parallel()
+
+	a := []model.Order{
+		{
+			Venue:          "Ku",
+			Price:          decimal.NewFromInt(20),
+			EffectivePrice: decimal.NewFromInt(20),
+			Amount:         decimal.NewFromInt(10),
+		},
+	}
+	// the Ga leg, 0.1*25, is below Ga's minimum of 3 quote
+	b := []model.Order{
+		{
+			Venue:          "Hu",
+			Price:          decimal.NewFromInt(28),
+			EffectivePrice: decimal.NewFromInt(28),
+			Amount:         decimal.NewFromInt(1),
+		},
+		{
+			Venue:          "Ga",
+			Price:          decimal.NewFromInt(25),
+			EffectivePrice: decimal.NewFromInt(25),
+			Amount:         decimal.NewFromFloat(0.1),
+		},
+	}
+
+	_, bs, totalTradeBase, gain, withdrawQuote, withdrawBase, profit, totalBuyQuote, totalSellQuote, totalBuyBase, totalSellBase := arbo(model.XCHUSDT, a, b, testVenues, ignoreBalances(model.XCHUSDT, testVenues), &config.Config{})
+	if !totalTradeBase.Equal(decimal.NewFromInt(1)) {
+		t.Errorf("totalTradeBase: want 1, got %v", totalTradeBase)
+	}
+	if diff := cmp.Diff(map[model.VenueID]decimal.Decimal{"Ku": decimal.NewFromInt(1)}, totalBuyBase); diff != "" {
+		t.Errorf("totalBuyBase -want/+got: %v", diff)
+	}
+	if diff := cmp.Diff(map[model.VenueID]decimal.Decimal{"Ku": decimal.NewFromInt(20)}, totalBuyQuote); diff != "" {
+		t.Errorf("totalBuyQuote -want/+got: %v", diff)
+	}
+	if diff := cmp.Diff(map[model.VenueID]decimal.Decimal{"Hu": decimal.NewFromInt(1), "Ga": decimal.Zero}, totalSellBase); diff != "" {
+		t.Errorf("totalSellBase -want/+got: %v", diff)
+	}
+	if diff := cmp.Diff(map[model.VenueID]decimal.Decimal{"Hu": decimal.NewFromInt(28), "Ga": decimal.Zero}, totalSellQuote); diff != "" {
+		t.Errorf("totalSellQuote -want/+got: %v", diff)
+	}
+	// 1*(28-20), without the pruned 0.1*(25-20)
+	if !gain.Equal(decimal.NewFromInt(8)) {
+		t.Errorf("gain: want 8, got %v", gain)
+	}
+	wantProfit := gain.Sub(withdrawQuote).Sub(withdrawBase.Mul(bs.LastPrice["Ku"]))
+	if !profit.Equal(wantProfit) || !profit.IsPositive() {
+		t.Errorf("profit: want %v, got %v", wantProfit, profit)
+	}
+}
+
 func BenchmarkGatherBooksP(b *testing.B) {
-	GatherBooksP()
+	GatherBooksP(model.XCHUSDT)
 }
 
 func BenchmarkGatherBooks(b *testing.B) {
-	GatherBooks()
+	GatherBooks(model.XCHUSDT)
 }
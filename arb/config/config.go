@@ -1,6 +1,14 @@
 package config
 
-import "github.com/kelseyhightower/envconfig"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/shopspring/decimal"
+)
 
 type Config struct {
 	PEnable bool   `split_words:"true"`
@@ -20,11 +28,110 @@ type Config struct {
 	CId  string `split_words:"true"`
 	CSec string `split_words:"true"`
 
+	MKey string `split_words:"true"`
+	MSec string `split_words:"true"`
+
 	ExecuteTrades bool `split_words:"true"`
+	// Simulate replaces every venue with a paper-trading simulator that fills
+	// orders against the venue's live book and keeps virtual balances, so
+	// ExecuteTrades risks no capital.
+	Simulate bool
+	// SimLatency is how long after placement a simulated order is matched.
+	SimLatency time.Duration `split_words:"true" default:"200ms"`
+	// SimFill is the share of the liquidity on each crossed level that a
+	// simulated order may take.
+	SimFill float64 `split_words:"true" default:"1"`
+	// SimBalances are the starting virtual balances as VENUE/CURRENCY=AMOUNT,
+	// e.g. "Ku/USDT=1000". Unlisted balances start at zero.
+	SimBalances []string `split_words:"true"`
+
+	// Venues lists the active venues as ID or ID=Exchange, e.g. "Ku,Ku2=Ku".
+	// Credentials for an ID that is not an exchange name are read from
+	// ARBO_<ID>_KEY, ARBO_<ID>_SEC and ARBO_<ID>_PASS. MEXC (Me) without
+	// ARBO_M_KEY only contributes its book; leave a venue out to opt out of it
+	// entirely, e.g. "Ku,Hu,Co,Ga".
+	Venues []string `default:"Me,Ku,Hu,Co,Ga"`
+	// Pairs are the markets to arbitrage, as BASE/QUOTE. Each pair runs in
+	// its own loop; all loops share the venue clients and balances.
+	Pairs []string `default:"XCH/USDT"`
+
+	// MaxBookAge is the oldest a streamed book may be for arbo to use it.
+	MaxBookAge time.Duration `split_words:"true" default:"15s"`
+	// Debounce is how long to wait after a book change for further changes
+	// before evaluating.
+	Debounce time.Duration `default:"50ms"`
+	// CancelAfter is how long placed orders may rest before the unfilled
+	// remainder is cancelled. Zero leaves them resting, except when another
+	// leg fails to place: the placed legs are then cancelled right away.
+	CancelAfter time.Duration `split_words:"true" default:"30s"`
+	// FeeRefresh is how often live fees are fetched after startup. Zero
+	// fetches them at startup only.
+	FeeRefresh time.Duration `split_words:"true" default:"15m"`
+
+	// Record appends every merged book to RecordDir while trading. The
+	// record command records regardless.
+	Record bool
+	// RecordDir is the directory of the book recordings.
+	RecordDir string `split_words:"true" default:"books"`
+	// RecordRotate is how often a new recording file is started. Zero keeps
+	// one file.
+	RecordRotate time.Duration `split_words:"true" default:"1h"`
+	// RecordEvery is how often the record command fetches the books.
+	RecordEvery time.Duration `split_words:"true" default:"1s"`
+
+	// Journal keeps every opportunity, the orders sent for it and their
+	// fills in JournalPath.
+	Journal bool
+	// JournalPath is the journal file, queried with the journal command.
+	JournalPath string `split_words:"true" default:"journal.db"`
+
+	// MetricsAddr is the address of the HTTP server exposing /metrics, e.g.
+	// "localhost:9100". Empty disables it.
+	MetricsAddr string `split_words:"true"`
+
+	// ControlAddr is the address of the HTTP control API. It should be a
+	// loopback address. Empty disables the API.
+	ControlAddr string `split_words:"true"`
+	// ControlToken authorizes requests to the control API. If empty, a random
+	// token is written to ControlTokenFile at startup.
+	ControlToken     string `split_words:"true"`
+	ControlTokenFile string `split_words:"true" default:".arbo-token"`
+
+	// Rebalance enables moving funds between venues when a venue runs short.
+	Rebalance bool
+	// RebalanceDryRun only prints the proposed withdrawals.
+	RebalanceDryRun bool `split_words:"true" default:"true"`
+	// RebalanceEvery is how often balances are checked against the targets.
+	RebalanceEvery time.Duration `split_words:"true" default:"10m"`
+	// RebalanceTimeout is how long a withdrawal counts as in flight if its
+	// deposit is not seen arriving first.
+	RebalanceTimeout time.Duration `split_words:"true" default:"2h"`
+	// RebalanceBelow is the fraction of its target below which a venue is
+	// topped up.
+	RebalanceBelow float64 `split_words:"true" default:"0.5"`
+	// RebalanceTargets are target shares of each currency as
+	// VENUE/CURRENCY=SHARE, e.g. "Ku/USDT=0.4". Venues without a target
+	// split what is left equally.
+	RebalanceTargets []string `split_words:"true"`
+	// WithdrawAddresses is the allowlist of deposit addresses as
+	// VENUE/CURRENCY/NETWORK=ADDRESS. Funds are only ever withdrawn to these.
+	WithdrawAddresses []string `split_words:"true"`
 
 	loaded bool
 }
 
+type Account struct {
+	Key  string
+	Sec  string
+	Pass string
+}
+
+type Venue struct {
+	ID       string
+	Exchange string
+	Account  Account
+}
+
 var c Config
 
 func Load() *Config {
@@ -35,3 +142,92 @@ func Load() *Config {
 
 	return &c
 }
+
+// Account returns the credentials for venue id.
+func (c *Config) Account(id string) Account {
+	switch id {
+	case "Ku":
+		return Account{Key: c.KKey, Sec: c.KSec, Pass: c.KPass}
+	case "Hu":
+		return Account{Key: c.HKey, Sec: c.HSec}
+	case "Co":
+		return Account{Key: c.CId, Sec: c.CSec}
+	case "Ga":
+		return Account{Key: c.GKey, Sec: c.GSec}
+	case "Me":
+		return Account{Key: c.MKey, Sec: c.MSec}
+	}
+
+	var a Account
+	envconfig.MustProcess("ARBO_"+strings.ToUpper(id), &a)
+	return a
+}
+
+// RebalanceTarget returns the target share of currency held on venue id.
+func (c *Config) RebalanceTarget(id, currency string) (float64, bool, error) {
+	for _, t := range c.RebalanceTargets {
+		k, v, found := strings.Cut(strings.TrimSpace(t), "=")
+		if !found {
+			return 0, false, fmt.Errorf("invalid rebalance target %q, want VENUE/CURRENCY=SHARE", t)
+		}
+		if k != id+"/"+currency {
+			continue
+		}
+		share, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("invalid rebalance target %q: %w", t, err)
+		}
+		return share, true, nil
+	}
+	return 0, false, nil
+}
+
+// WithdrawAddress returns the allowlisted address for deposits of currency
+// to venue id over network.
+func (c *Config) WithdrawAddress(id, currency, network string) (string, bool) {
+	for _, a := range c.WithdrawAddresses {
+		k, v, found := strings.Cut(strings.TrimSpace(a), "=")
+		if found && k == id+"/"+currency+"/"+network && v != "" {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// SimBalance returns the starting virtual balances of venue id.
+func (c *Config) SimBalance(id string) (map[string]decimal.Decimal, error) {
+	m := map[string]decimal.Decimal{}
+	for _, b := range c.SimBalances {
+		k, v, found := strings.Cut(strings.TrimSpace(b), "=")
+		if !found {
+			return nil, fmt.Errorf("invalid sim balance %q, want VENUE/CURRENCY=AMOUNT", b)
+		}
+		venue, currency, found := strings.Cut(k, "/")
+		if !found || venue != id {
+			continue
+		}
+		amt, err := decimal.NewFromString(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sim balance %q: %w", b, err)
+		}
+		m[currency] = amt
+	}
+	return m, nil
+}
+
+// VenueConfigs parses Venues.
+func (c *Config) VenueConfigs() []Venue {
+	vs := make([]Venue, 0, len(c.Venues))
+	for _, v := range c.Venues {
+		id, ex, found := strings.Cut(strings.TrimSpace(v), "=")
+		if !found {
+			ex = id
+		}
+		vs = append(vs, Venue{
+			ID:       id,
+			Exchange: ex,
+			Account:  c.Account(id),
+		})
+	}
+	return vs
+}
@@ -0,0 +1,144 @@
+package arb
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/L3Sota/arbo/arb/config"
+	"github.com/L3Sota/arbo/arb/journal"
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/shopspring/decimal"
+)
+
+// controls are the runtime overrides of the configuration made through the
+// control API.
+type controls struct {
+	mu            sync.RWMutex
+	paused        bool
+	executeTrades *bool // conf.ExecuteTrades if nil
+	minProfitRate decimal.Decimal
+	last          map[model.Pair]State
+}
+
+var ctl = controls{
+	minProfitRate: minimumProfitRate,
+	last:          map[model.Pair]State{},
+}
+
+// Status is the engine's current trading configuration.
+type Status struct {
+	Paused        bool
+	ExecuteTrades bool
+	MinProfitRate decimal.Decimal
+}
+
+// State is what the last evaluation of a pair saw and planned.
+type State struct {
+	Time     time.Time
+	Pair     model.Pair
+	Asks     []model.Order
+	Bids     []model.Order
+	Balances map[model.VenueID]model.Balances
+	Plan     journal.Plan
+}
+
+// CurrentStatus returns the trading configuration in effect.
+func CurrentStatus(conf *config.Config) Status {
+	ctl.mu.RLock()
+	defer ctl.mu.RUnlock()
+
+	s := Status{
+		Paused:        ctl.paused,
+		ExecuteTrades: conf.ExecuteTrades,
+		MinProfitRate: ctl.minProfitRate,
+	}
+	if ctl.executeTrades != nil {
+		s.ExecuteTrades = *ctl.executeTrades
+	}
+	return s
+}
+
+// Pause stops trading until Resume. Books are still evaluated.
+func Pause() {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+
+	ctl.paused = true
+}
+
+func Resume() {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+
+	ctl.paused = false
+}
+
+// SetExecuteTrades overrides conf.ExecuteTrades.
+func SetExecuteTrades(on bool) {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+
+	ctl.executeTrades = &on
+}
+
+// SetMinProfitRate sets the quote profit per base traded below which
+// opportunities are skipped.
+func SetMinProfitRate(rate decimal.Decimal) error {
+	if rate.IsNegative() {
+		return fmt.Errorf("negative minimum profit rate %v", rate)
+	}
+
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+
+	ctl.minProfitRate = rate
+	return nil
+}
+
+func minProfitRate() decimal.Decimal {
+	ctl.mu.RLock()
+	defer ctl.mu.RUnlock()
+
+	return ctl.minProfitRate
+}
+
+// trading reports whether Book may place orders.
+func trading(conf *config.Config) bool {
+	s := CurrentStatus(conf)
+	return s.ExecuteTrades && !s.Paused
+}
+
+// LastStates returns the last evaluation of each pair, ordered by pair.
+func LastStates() []State {
+	ctl.mu.RLock()
+	defer ctl.mu.RUnlock()
+
+	ss := make([]State, 0, len(ctl.last))
+	for _, s := range ctl.last {
+		ss = append(ss, s)
+	}
+	sort.Slice(ss, func(i, j int) bool { return ss[i].Pair.String() < ss[j].Pair.String() })
+	return ss
+}
+
+// remember keeps s as the last evaluation of its pair. Its books, balances
+// and plan must not be modified afterwards.
+func remember(s State) {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+
+	ctl.last[s.Pair] = s
+}
+
+// RefreshBalances fetches the balances now rather than at the next trade.
+func RefreshBalances() (map[model.VenueID]model.Balances, error) {
+	funds.mu.Lock()
+	defer funds.mu.Unlock()
+
+	if err := funds.refresh(); err != nil {
+		return nil, err
+	}
+	return funds.snapshot(), nil
+}
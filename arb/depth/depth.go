@@ -0,0 +1,189 @@
+package depth
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/shopspring/decimal"
+)
+
+// Level is one price level of a book.
+type Level struct {
+	Price  decimal.Decimal
+	Amount decimal.Decimal
+}
+
+// Book is a local L2 order book kept up to date by a venue stream. It is safe
+// for concurrent use.
+type Book struct {
+	mu      sync.RWMutex
+	asks    map[string]Level
+	bids    map[string]Level
+	ready   bool
+	updated time.Time
+
+	bestAsk, bestBid decimal.Decimal
+	watchers         []chan struct{}
+}
+
+func New() *Book {
+	return &Book{
+		asks: map[string]Level{},
+		bids: map[string]Level{},
+	}
+}
+
+// Replace sets the book to a full snapshot and marks it ready.
+func (b *Book) Replace(asks, bids []Level) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.asks = make(map[string]Level, len(asks))
+	b.bids = make(map[string]Level, len(bids))
+	apply(b.asks, asks)
+	apply(b.bids, bids)
+	b.ready = true
+	b.updated = time.Now()
+	b.notify()
+}
+
+// Apply merges incremental updates into the book. A zero amount removes the
+// level.
+func (b *Book) Apply(asks, bids []Level) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	apply(b.asks, asks)
+	apply(b.bids, bids)
+	b.updated = time.Now()
+	b.notify()
+}
+
+func apply(m map[string]Level, ls []Level) {
+	for _, l := range ls {
+		k := l.Price.String()
+		if l.Amount.IsZero() {
+			delete(m, k)
+			continue
+		}
+		m[k] = l
+	}
+}
+
+// Watch returns a channel that receives whenever the best bid or ask changes.
+// Notifications are coalesced: a slow reader sees one pending notification
+// rather than one per change.
+func (b *Book) Watch() <-chan struct{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := make(chan struct{}, 1)
+	b.watchers = append(b.watchers, c)
+	return c
+}
+
+// notify wakes the watchers if the top of the book moved. The caller must
+// hold b.mu.
+func (b *Book) notify() {
+	ask, bid := best(b.asks, true), best(b.bids, false)
+	if ask.Equal(b.bestAsk) && bid.Equal(b.bestBid) {
+		return
+	}
+	b.bestAsk, b.bestBid = ask, bid
+
+	for _, c := range b.watchers {
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// best returns the lowest (asc) or highest price in m, or zero if m is empty.
+func best(m map[string]Level, asc bool) decimal.Decimal {
+	var p decimal.Decimal
+	first := true
+	for _, l := range m {
+		if first || asc && l.Price.LessThan(p) || !asc && l.Price.GreaterThan(p) {
+			p = l.Price
+			first = false
+		}
+	}
+	return p
+}
+
+// Touch records that the stream confirmed the book is current without
+// changing it.
+func (b *Book) Touch() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.updated = time.Now()
+}
+
+// Reset marks the book as out of sync until the next Replace.
+func (b *Book) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.ready = false
+}
+
+// Ready reports whether the book holds a snapshot that has been kept in sync.
+func (b *Book) Ready() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.ready
+}
+
+// Updated returns the time of the last snapshot or update.
+func (b *Book) Updated() time.Time {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.updated
+}
+
+// Age returns how long ago the book was last confirmed in sync. A book that
+// is not in sync is infinitely old.
+func (b *Book) Age() time.Duration {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if !b.ready {
+		return math.MaxInt64
+	}
+	return time.Since(b.updated)
+}
+
+// Orders returns the book as venue orders, asks ascending and bids
+// descending, with effective prices adjusted by the given ratios.
+func (b *Book) Orders(v model.VenueID, askAddition, bidReduction decimal.Decimal) ([]model.Order, []model.Order) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return orders(v, b.asks, askAddition, true), orders(v, b.bids, bidReduction, false)
+}
+
+func orders(v model.VenueID, m map[string]Level, ratio decimal.Decimal, asc bool) []model.Order {
+	os := make([]model.Order, 0, len(m))
+	for _, l := range m {
+		os = append(os, model.Order{
+			Venue:          v,
+			Price:          l.Price,
+			EffectivePrice: l.Price.Mul(ratio),
+			Amount:         l.Amount,
+		})
+	}
+	sort.Slice(os, func(i, j int) bool {
+		if asc {
+			return os[i].Price.LessThan(os[j].Price)
+		}
+		return os[i].Price.GreaterThan(os[j].Price)
+	})
+	return os
+}
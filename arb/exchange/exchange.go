@@ -0,0 +1,126 @@
+package exchange
+
+import (
+	"sync"
+	"time"
+
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/L3Sota/arbo/arb/ratelimit"
+	"github.com/shopspring/decimal"
+)
+
+// Exchange is the surface every venue package exposes to the arb engine.
+// Methods that a venue does not support return errors.ErrUnsupported.
+// Prices are in the pair's quote currency and sizes in its base currency.
+type Exchange interface {
+	Venue() model.Venue
+	Book(p model.Pair) ([]model.Order, []model.Order, error)
+	Balances() (model.Balances, error)
+	Buy(p model.Pair, price, size decimal.Decimal) (string, error)
+	Sell(p model.Pair, price, size decimal.Decimal) (string, error)
+	GetOrder(p model.Pair, id string) (model.OrderStatus, error)
+	Cancel(p model.Pair, id string) error
+}
+
+var (
+	mu       sync.RWMutex
+	registry []Exchange
+)
+
+// Register makes exchanges available to the engine, replacing any previously
+// registered exchange with the same venue ID.
+func Register(es ...Exchange) {
+	mu.Lock()
+	defer mu.Unlock()
+
+outer:
+	for _, e := range es {
+		for i, r := range registry {
+			if r.Venue().ID == e.Venue().ID {
+				registry[i] = e
+				continue outer
+			}
+		}
+		registry = append(registry, e)
+	}
+}
+
+// All returns the registered exchanges in registration order.
+func All() []Exchange {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	es := make([]Exchange, len(registry))
+	copy(es, registry)
+
+	return es
+}
+
+// Get returns the exchange registered for venue id, if any.
+func Get(id model.VenueID) (Exchange, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, e := range registry {
+		if e.Venue().ID == id {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// Venues returns the metadata of all registered exchanges keyed by venue ID.
+func Venues() map[model.VenueID]model.Venue {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	vs := make(map[model.VenueID]model.Venue, len(registry))
+	for _, e := range registry {
+		v := e.Venue()
+		vs[v.ID] = v
+	}
+
+	return vs
+}
+
+// Streamer is implemented by exchanges that keep their books locally from a
+// stream rather than fetching them on every call.
+type Streamer interface {
+	// Watch returns a channel that receives whenever the best bid or ask of p
+	// changes.
+	Watch(p model.Pair) <-chan struct{}
+	// Age returns how long ago the book of p was last confirmed in sync.
+	Age(p model.Pair) time.Duration
+	// Streaming reports whether Book serves p from the stream rather than
+	// falling back to REST.
+	Streaming(p model.Pair) bool
+}
+
+// FeeQuerier is implemented by exchanges that can fetch the fees charged to
+// the account.
+type FeeQuerier interface {
+	// QueryFee returns the maker and taker fee ratios charged on p.
+	QueryFee(p model.Pair) (maker, taker decimal.Decimal, err error)
+	// SetFees replaces the fee ratios used to price the venue's book of p.
+	SetFees(p model.Pair, maker, taker decimal.Decimal)
+}
+
+// RulesQuerier is implemented by exchanges that can fetch the order rules of
+// a pair.
+type RulesQuerier interface {
+	QueryRules(p model.Pair) (model.Rules, error)
+	// SetRules replaces the rules used to size and round orders on p.
+	SetRules(p model.Pair, r model.Rules)
+}
+
+// Withdrawer is implemented by exchanges that can withdraw funds on chain.
+type Withdrawer interface {
+	// Withdraw sends amount of currency to address over w's network and
+	// returns the venue's withdrawal ID.
+	Withdraw(w model.Withdrawal, currency, address string, amount decimal.Decimal) (string, error)
+}
+
+// Limited is implemented by exchanges whose calls go through a rate limiter.
+type Limited interface {
+	Limiter() *ratelimit.Limiter
+}
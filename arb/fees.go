@@ -0,0 +1,48 @@
+package arb
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/L3Sota/arbo/arb/exchange"
+	"github.com/L3Sota/arbo/arb/model"
+)
+
+// RefreshFees fetches the fees charged on each of pairs by each venue that can
+// report them and prices the venue's books with them.
+func RefreshFees(pairs []model.Pair) {
+	for _, e := range exchange.All() {
+		for _, p := range pairs {
+			if !refreshFee(e, p) {
+				break
+			}
+		}
+	}
+}
+
+// refreshFee refreshes the fees of p on e and reports whether e can report
+// its fees at all.
+func refreshFee(e exchange.Exchange, p model.Pair) bool {
+	q, ok := e.(exchange.FeeQuerier)
+	if !ok {
+		return false
+	}
+	v := e.Venue()
+
+	maker, taker, err := q.QueryFee(p)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return false
+	}
+	if err != nil {
+		fmt.Printf("%v %v fee error: %v\n", v.ID, p, err)
+		return true
+	}
+	if !maker.Equal(v.Fees.Maker) {
+		fmt.Printf("warning: %v %v maker fee is %v, configured %v\n", v.ID, p, maker, v.Fees.Maker)
+	}
+	if !taker.Equal(v.Fees.Taker) {
+		fmt.Printf("warning: %v %v taker fee is %v, configured %v\n", v.ID, p, taker, v.Fees.Taker)
+	}
+	q.SetFees(p, maker, taker)
+	return true
+}
@@ -0,0 +1,70 @@
+package fees
+
+import (
+	"sync"
+
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/shopspring/decimal"
+)
+
+// Schedule is the fees of one venue. It starts from the configured fees and
+// is updated per pair as the live ones are discovered. It is safe for
+// concurrent use.
+type Schedule struct {
+	mu    sync.RWMutex
+	fees  model.Fees
+	pairs map[model.Pair]model.Fees
+}
+
+func New(f model.Fees) *Schedule {
+	return &Schedule{fees: f, pairs: map[model.Pair]model.Fees{}}
+}
+
+// Fees returns the configured fees, which apply to pairs without live ones.
+func (s *Schedule) Fees() model.Fees {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.fees
+}
+
+// Pair returns the fees charged on p.
+func (s *Schedule) Pair(p model.Pair) model.Fees {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if f, ok := s.pairs[p]; ok {
+		return f
+	}
+	return s.fees
+}
+
+// All returns a copy of the live fees by pair.
+func (s *Schedule) All() map[model.Pair]model.Fees {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m := make(map[model.Pair]model.Fees, len(s.pairs))
+	for p, f := range s.pairs {
+		m[p] = f
+	}
+	return m
+}
+
+// Ratios returns the multipliers from a book price of p to the effective
+// price of buying at an ask and of selling at a bid, as a maker or a taker.
+func (s *Schedule) Ratios(p model.Pair, maker bool) (askAddition, bidReduction decimal.Decimal) {
+	f := s.Pair(p)
+	return f.AskAddition(maker), f.BidReduction(maker)
+}
+
+// Set sets the trading fee ratios of p.
+func (s *Schedule) Set(p model.Pair, maker, taker decimal.Decimal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f := s.fees
+	f.Maker = maker
+	f.Taker = taker
+	s.pairs[p] = f
+}
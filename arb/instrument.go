@@ -0,0 +1,136 @@
+package arb
+
+import (
+	"net/http"
+
+	"github.com/L3Sota/arbo/arb/exchange"
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/L3Sota/arbo/arb/ratelimit"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	bookSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "arbo_book_fetch_seconds",
+		Help:    "Time taken to fetch a venue's book.",
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+	}, []string{"venue"})
+	bookErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "arbo_book_fetch_errors_total",
+		Help: "Failed book fetches.",
+	}, []string{"venue"})
+	bestAsk = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "arbo_best_ask",
+		Help: "Best ask after fees.",
+	}, []string{"pair", "venue"})
+	bestBid = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "arbo_best_bid",
+		Help: "Best bid after fees.",
+	}, []string{"pair", "venue"})
+	crossSpread = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "arbo_cross_spread",
+		Help: "Best bid less best ask across venues, after fees. Positive when the books cross.",
+	}, []string{"pair"})
+	cycleProfit = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "arbo_profit",
+		Help: "Profit found by the last evaluation, in the quote currency.",
+	}, []string{"pair"})
+	ordersTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "arbo_orders_total",
+		Help: "Orders by outcome: placed or failed when sent, then filled, partially_filled or cancelled once settled.",
+	}, []string{"venue", "side", "outcome"})
+	balance = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "arbo_balance",
+		Help: "Available balance as last fetched, less what the engine has since reserved or spent.",
+	}, []string{"venue", "currency"})
+	rateLimit = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "arbo_ratelimit_remaining",
+		Help: "Requests that can be made without waiting, by rate limit class. Negative while requests are queued.",
+	}, []string{"venue", "class"})
+)
+
+// MetricsHandler serves the metrics in the Prometheus exposition format.
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
+
+// observeBooks records the best prices of the merged books of p.
+func observeBooks(p model.Pair, a, b []model.Order) {
+	observeBest(bestAsk, p.String(), a)
+	observeBest(bestBid, p.String(), b)
+	if len(a) > 0 && len(b) > 0 {
+		crossSpread.WithLabelValues(p.String()).Set(b[0].EffectivePrice.Sub(a[0].EffectivePrice).InexactFloat64())
+	} else {
+		crossSpread.DeleteLabelValues(p.String())
+	}
+}
+
+// observeBest sets g to the best price of each venue on one side of the book
+// of pair. The pair's series are dropped first, so venues missing from the
+// book are no longer exported.
+func observeBest(g *prometheus.GaugeVec, pair string, os []model.Order) {
+	g.DeletePartialMatch(prometheus.Labels{"pair": pair})
+	seen := map[model.VenueID]bool{}
+	for _, o := range os {
+		if !seen[o.Venue] {
+			seen[o.Venue] = true
+			g.WithLabelValues(pair, string(o.Venue)).Set(o.EffectivePrice.InexactFloat64())
+		}
+	}
+}
+
+// observeProfit records the profit found by the last evaluation of p.
+func observeProfit(p model.Pair, profit decimal.Decimal) {
+	cycleProfit.WithLabelValues(p.String()).Set(profit.InexactFloat64())
+}
+
+// observeFills counts the settled orders by how they ended: filled,
+// partially_filled when cancelled after a partial fill, or cancelled. Orders
+// left resting have not ended and are not counted.
+func observeFills(orders map[model.VenueID]*model.OrderStatus, cancelled []model.VenueID, totalBuyBase map[model.VenueID]decimal.Decimal) {
+	byUs := make(map[model.VenueID]bool, len(cancelled))
+	for _, v := range cancelled {
+		byUs[v] = true
+	}
+	for v, o := range orders {
+		if o == nil || o.Active {
+			continue
+		}
+		side := "sell"
+		if totalBuyBase[v].IsPositive() {
+			side = "buy"
+		}
+		outcome := "cancelled"
+		switch {
+		case o.Filled.IsPositive() && byUs[v]:
+			outcome = "partially_filled"
+		case o.Filled.IsPositive():
+			outcome = "filled"
+		}
+		ordersTotal.WithLabelValues(string(v), side, outcome).Inc()
+	}
+}
+
+func observeBalances(bs map[model.VenueID]model.Balances) {
+	for v, b := range bs {
+		for cur, amt := range b {
+			balance.WithLabelValues(string(v), cur).Set(amt.InexactFloat64())
+		}
+	}
+}
+
+// observeLimits records the rate limit budgets left on each venue.
+func observeLimits(es []exchange.Exchange) {
+	for _, e := range es {
+		l, ok := e.(exchange.Limited)
+		if !ok {
+			continue
+		}
+		for _, c := range ratelimit.Classes {
+			rateLimit.WithLabelValues(string(e.Venue().ID), c.String()).Set(l.Limiter().Remaining(c))
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package arb
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/shopspring/decimal"
+)
+
+func TestObserveBooks(t *testing.T) {
+	t.Parallel()
+
+	p := model.Pair{Base: "OBS", Quote: "USDT"}
+	series := func() []string {
+		t.Helper()
+		mfs, err := prometheus.DefaultGatherer.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ss []string
+		for _, mf := range mfs {
+			for _, m := range mf.GetMetric() {
+				var ls []string
+				for _, l := range m.GetLabel() {
+					ls = append(ls, fmt.Sprintf("%v=%q", l.GetName(), l.GetValue()))
+				}
+				if l := strings.Join(ls, ","); strings.Contains(l, `pair="OBS/USDT"`) {
+					ss = append(ss, fmt.Sprintf("%v{%v} %v", mf.GetName(), l, m.GetGauge().GetValue()))
+				}
+			}
+		}
+		return ss
+	}
+	order := func(v model.VenueID, price string) model.Order {
+		return model.Order{Venue: v, EffectivePrice: dec(price)}
+	}
+
+	observeBooks(p,
+		[]model.Order{order("Ku", "10"), order("Ga", "10.5"), order("Ku", "11")},
+		[]model.Order{order("Ga", "12"), order("Ku", "9")})
+	observeProfit(p, dec("1.5"))
+	want := []string{
+		`arbo_best_ask{pair="OBS/USDT",venue="Ga"} 10.5`,
+		`arbo_best_ask{pair="OBS/USDT",venue="Ku"} 10`,
+		`arbo_best_bid{pair="OBS/USDT",venue="Ga"} 12`,
+		`arbo_best_bid{pair="OBS/USDT",venue="Ku"} 9`,
+		`arbo_cross_spread{pair="OBS/USDT"} 2`,
+		`arbo_profit{pair="OBS/USDT"} 1.5`,
+	}
+	if diff := cmp.Diff(want, series()); diff != "" {
+		t.Errorf("series mismatch (-want +got):\n%s", diff)
+	}
+
+	// Ga dropped out of the asks and the bids are empty
+	observeBooks(p, []model.Order{order("Ku", "10.2")}, nil)
+	observeProfit(p, dec("0"))
+	want = []string{
+		`arbo_best_ask{pair="OBS/USDT",venue="Ku"} 10.2`,
+		`arbo_profit{pair="OBS/USDT"} 0`,
+	}
+	if diff := cmp.Diff(want, series()); diff != "" {
+		t.Errorf("series after a venue dropped out mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestObserveFills(t *testing.T) {
+	t.Parallel()
+
+	count := func(v, side, outcome string) float64 {
+		return testutil.ToFloat64(ordersTotal.WithLabelValues(v, side, outcome))
+	}
+	orders := map[model.VenueID]*model.OrderStatus{
+		"Of1": {Filled: dec("1")},
+		"Of2": {Filled: dec("0.5")},
+		"Of3": {},
+		"Of4": {Filled: dec("0.5"), Active: true},
+	}
+	buys := map[model.VenueID]decimal.Decimal{"Of1": dec("1")}
+	observeFills(orders, []model.VenueID{"Of2", "Of3"}, buys)
+
+	for _, tc := range []struct {
+		venue, side, outcome string
+		want                 float64
+	}{
+		{"Of1", "buy", "filled", 1},
+		{"Of2", "sell", "partially_filled", 1},
+		{"Of2", "sell", "filled", 0},
+		{"Of3", "sell", "cancelled", 1},
+		{"Of4", "sell", "filled", 0},
+		{"Of4", "sell", "partially_filled", 0},
+	} {
+		if got := count(tc.venue, tc.side, tc.outcome); got != tc.want {
+			t.Errorf("%v %v %v = %v, want %v", tc.venue, tc.side, tc.outcome, got, tc.want)
+		}
+	}
+}
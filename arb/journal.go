@@ -0,0 +1,98 @@
+package arb
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/L3Sota/arbo/arb/journal"
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/shopspring/decimal"
+)
+
+var tradeJournal atomic.Pointer[journal.Journal]
+
+// Journal makes Book write every opportunity to j. A nil j stops journaling.
+func Journal(j *journal.Journal) {
+	tradeJournal.Store(j)
+}
+
+// journalDepth is how many levels past those arbo crossed an entry keeps.
+const journalDepth = 5
+
+func newEntry(p model.Pair, as, bs side, balances map[model.VenueID]model.Balances, plan journal.Plan) *journal.Entry {
+	return &journal.Entry{
+		Time:     time.Now(),
+		Pair:     p,
+		Asks:     crossed(as),
+		Bids:     crossed(bs),
+		Balances: balances,
+		Plan:     plan,
+		Status:   journal.Unexecuted,
+	}
+}
+
+// crossed returns the levels of s arbo crossed and journalDepth beyond.
+func crossed(s side) []model.Order {
+	n := s.I + journalDepth
+	if n > len(s.Book) {
+		n = len(s.Book)
+	}
+	return s.Book[:n:n]
+}
+
+func newPlan(as, bs side, totalTradeBase, gain, withdrawQuote, withdrawBase, profit decimal.Decimal, totalBuyQuote, totalSellQuote, totalBuyBase, totalSellBase map[model.VenueID]decimal.Decimal) journal.Plan {
+	return journal.Plan{
+		Profit:        profit,
+		Gain:          gain,
+		TradeBase:     totalTradeBase,
+		WithdrawQuote: withdrawQuote,
+		WithdrawBase:  withdrawBase,
+		BuyQuote:      totalBuyQuote,
+		SellQuote:     totalSellQuote,
+		BuyBase:       totalBuyBase,
+		SellBase:      totalSellBase,
+		AskPrices:     as.LastPrice,
+		BidPrices:     bs.LastPrice,
+	}
+}
+
+// sent records the orders placed for e.
+func sent(e *journal.Entry, ids map[model.VenueID]string) {
+	for v, id := range ids {
+		o := journal.Order{Venue: v, ID: id}
+		if s := e.Plan.BuyBase[v]; s.IsPositive() {
+			o.Buy, o.Price, o.Size = true, e.Plan.AskPrices[v], s
+		} else {
+			o.Price, o.Size = e.Plan.BidPrices[v], e.Plan.SellBase[v]
+		}
+		e.Orders = append(e.Orders, o)
+	}
+}
+
+// settled records how the orders of e ended.
+func settled(e *journal.Entry, orders map[model.VenueID]*model.OrderStatus, cancelled []model.VenueID) {
+	e.Status = journal.Filled
+	for i, o := range e.Orders {
+		s := orders[o.Venue]
+		e.Orders[i].Status = s
+		if s == nil || s.Active {
+			e.Status = journal.Unfilled
+		}
+	}
+	if len(cancelled) > 0 {
+		e.Status = journal.Cancelled
+	}
+}
+
+// writeEntry adds e to the journal, if any. Failing to journal does not stop
+// trading.
+func writeEntry(e *journal.Entry) {
+	j := tradeJournal.Load()
+	if j == nil {
+		return
+	}
+	if err := j.Add(e); err != nil {
+		fmt.Printf("%v journal error: %v\n", e.Pair, err)
+	}
+}
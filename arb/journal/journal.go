@@ -0,0 +1,169 @@
+// Package journal keeps every opportunity the engine found, what it did about
+// it and how the orders ended, in a bbolt file.
+package journal
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/shopspring/decimal"
+	bolt "go.etcd.io/bbolt"
+)
+
+var bucket = []byte("entries")
+
+// Entry is one evaluation of a pair that showed a profit.
+type Entry struct {
+	ID   uint64
+	Time time.Time
+	Pair model.Pair
+	// Asks and Bids are the merged levels arbo crossed and a few beyond, not
+	// the full books.
+	Asks []model.Order
+	Bids []model.Order
+	// Balances is the engine's view of the balances before trading.
+	Balances map[model.VenueID]model.Balances
+	Plan     Plan
+	Orders   []Order
+	Status   string
+	Error    string `json:",omitempty"`
+}
+
+// Plan is what arbo proposed. Quote amounts are fee-adjusted.
+type Plan struct {
+	Profit        decimal.Decimal
+	Gain          decimal.Decimal
+	TradeBase     decimal.Decimal
+	WithdrawQuote decimal.Decimal
+	WithdrawBase  decimal.Decimal
+	BuyQuote      map[model.VenueID]decimal.Decimal
+	SellQuote     map[model.VenueID]decimal.Decimal
+	BuyBase       map[model.VenueID]decimal.Decimal
+	SellBase      map[model.VenueID]decimal.Decimal
+	AskPrices     map[model.VenueID]decimal.Decimal // limit prices
+	BidPrices     map[model.VenueID]decimal.Decimal
+}
+
+// Order is an order sent for an entry and, once known, its outcome.
+type Order struct {
+	Venue  model.VenueID
+	Buy    bool
+	Price  decimal.Decimal
+	Size   decimal.Decimal
+	ID     string
+	Status *model.OrderStatus `json:",omitempty"`
+}
+
+// Statuses of an entry.
+const (
+	Unexecuted = "not executed"
+	LowRate    = "skipped: profit rate too low"
+	BelowMin   = "skipped: below min order threshold"
+	Failed     = "failed"
+	Filled     = "filled"
+	Unfilled   = "not filled"
+	Cancelled  = "cancelled"
+)
+
+// Journal is a journal file. The file is only held open while an entry is
+// written or the entries are scanned, so it can be queried while the engine
+// runs.
+type Journal struct {
+	path string
+}
+
+// Open returns the journal at path, creating it if needed.
+func Open(path string) (*Journal, error) {
+	j := &Journal{path: path}
+	db, err := j.open(false)
+	if err != nil {
+		return nil, err
+	}
+	return j, db.Close()
+}
+
+// OpenReadOnly returns the existing journal at path for queries.
+func OpenReadOnly(path string) (*Journal, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	return &Journal{path: path}, nil
+}
+
+func (j *Journal) open(readOnly bool) (*bolt.DB, error) {
+	return bolt.Open(j.path, 0o644, &bolt.Options{Timeout: 5 * time.Second, ReadOnly: readOnly})
+}
+
+// Add assigns e an ID and writes it.
+func (j *Journal) Add(e *Entry) error {
+	db, err := j.open(false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(bucket)
+		if err != nil {
+			return err
+		}
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		e.ID = id
+
+		v, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		k := make([]byte, 8)
+		binary.BigEndian.PutUint64(k, id)
+		return b.Put(k, v)
+	})
+}
+
+// Query selects entries. Zero fields match everything.
+type Query struct {
+	Since  time.Time
+	Until  time.Time
+	Pair   model.Pair
+	Status string
+}
+
+func (q Query) match(e *Entry) bool {
+	return (q.Since.IsZero() || !e.Time.Before(q.Since)) &&
+		(q.Until.IsZero() || e.Time.Before(q.Until)) &&
+		(q.Pair == model.Pair{} || e.Pair == q.Pair) &&
+		(q.Status == "" || e.Status == q.Status)
+}
+
+// Scan calls fn with the entries matching q, oldest first, stopping at the
+// first error.
+func (j *Journal) Scan(q Query, fn func(Entry) error) error {
+	db, err := j.open(true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(_, v []byte) error {
+			var e Entry
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			if !q.match(&e) {
+				return nil
+			}
+			return fn(e)
+		})
+	})
+}
@@ -0,0 +1,103 @@
+package journal
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/google/go-cmp/cmp"
+	"github.com/shopspring/decimal"
+)
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "journal.db")
+	j, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := []string{Unexecuted, LowRate, BelowMin, Failed, Filled, Unfilled, Cancelled}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	other := model.Pair{Base: "ETH", Quote: "USDT"}
+	var want []Entry
+	for i, s := range statuses {
+		e := Entry{
+			Time:     start.Add(time.Duration(i) * time.Minute),
+			Pair:     model.XCHUSDT,
+			Balances: map[model.VenueID]model.Balances{"Ku": {"USDT": decimal.NewFromInt(100)}},
+			Plan:     Plan{Profit: decimal.NewFromInt(int64(i))},
+			Status:   s,
+		}
+		if i%2 == 1 {
+			e.Pair = other
+		}
+		if s == Failed {
+			e.Error = "trade: rejected"
+		}
+		if s == Filled {
+			e.Orders = []Order{{Venue: "Ku", Buy: true, Price: decimal.NewFromInt(30), Size: decimal.NewFromInt(1), ID: "1",
+				Status: &model.OrderStatus{ID: "1", Filled: decimal.NewFromInt(1), FilledFunds: decimal.NewFromInt(30), State: "done"}}}
+		}
+		if err := j.Add(&e); err != nil {
+			t.Fatal(err)
+		}
+		if e.ID != uint64(i+1) {
+			t.Errorf("entry %d has ID %d", i, e.ID)
+		}
+		want = append(want, e)
+	}
+
+	// queried while the engine still holds its journal
+	r, err := OpenReadOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scan := func(q Query) []Entry {
+		t.Helper()
+		var got []Entry
+		if err := r.Scan(q, func(e Entry) error {
+			got = append(got, e)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	if diff := cmp.Diff(want, scan(Query{})); diff != "" {
+		t.Errorf("all entries mismatch (-want +got):\n%s", diff)
+	}
+	for i, s := range statuses {
+		if diff := cmp.Diff(want[i:i+1], scan(Query{Status: s})); diff != "" {
+			t.Errorf("status %q mismatch (-want +got):\n%s", s, diff)
+		}
+	}
+	if diff := cmp.Diff([]Entry{want[1], want[3], want[5]}, scan(Query{Pair: other})); diff != "" {
+		t.Errorf("pair mismatch (-want +got):\n%s", diff)
+	}
+	q := Query{Since: want[2].Time, Until: want[4].Time}
+	if diff := cmp.Diff(want[2:4], scan(q)); diff != "" {
+		t.Errorf("time range mismatch (-want +got):\n%s", diff)
+	}
+
+	// and written to after the query
+	e := Entry{Time: start.Add(time.Hour), Pair: model.XCHUSDT, Status: Filled}
+	if err := j.Add(&e); err != nil {
+		t.Fatal(err)
+	}
+	if got := scan(Query{Since: e.Time}); len(got) != 1 || got[0].ID != e.ID {
+		t.Errorf("entries after the last write = %+v, want #%v", got, e.ID)
+	}
+}
+
+func TestOpenReadOnlyMissing(t *testing.T) {
+	t.Parallel()
+
+	if _, err := OpenReadOnly(filepath.Join(t.TempDir(), "journal.db")); err == nil {
+		t.Error("opened a journal that does not exist")
+	}
+}
@@ -1,17 +1,36 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/L3Sota/arbo/arb"
+	"github.com/L3Sota/arbo/arb/api"
 	"github.com/L3Sota/arbo/arb/config"
+	"github.com/L3Sota/arbo/arb/exchange"
+	"github.com/L3Sota/arbo/arb/journal"
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/L3Sota/arbo/arb/pnl"
+	"github.com/L3Sota/arbo/arb/record"
+	"github.com/L3Sota/arbo/arb/sim"
 	"github.com/L3Sota/arbo/c"
 	"github.com/L3Sota/arbo/g"
 	"github.com/L3Sota/arbo/h"
 	"github.com/L3Sota/arbo/k"
+	"github.com/L3Sota/arbo/m"
 	"github.com/gregdel/pushover"
+	"github.com/shopspring/decimal"
 )
 
 var (
@@ -31,39 +50,531 @@ var (
 	}
 )
 
-func oneoff() {
+func load() (*config.Config, []model.Pair) {
 	conf := config.Load()
-	k.LoadClient(conf)
-	h.LoadClient(conf)
-	c.LoadClient(conf)
-	g.LoadClient()
+
+	pairs := make([]model.Pair, 0, len(conf.Pairs))
+	for _, s := range conf.Pairs {
+		pair, err := model.ParsePair(strings.TrimSpace(s))
+		if err != nil {
+			panic(err)
+		}
+		pairs = append(pairs, pair)
+	}
+
+	for _, v := range conf.VenueConfigs() {
+		e, err := newExchange(v)
+		if err != nil {
+			panic(fmt.Sprintf("venue %v: %v", v.ID, err))
+		}
+		if conf.Simulate {
+			b, err := conf.SimBalance(v.ID)
+			if err != nil {
+				panic(err)
+			}
+			e = sim.New(e, b, conf.SimLatency, decimal.NewFromFloat(conf.SimFill))
+		}
+		exchange.Register(e)
+	}
+	arb.RefreshFees(pairs)
+	arb.RefreshRules(pairs)
 
 	if conf.PEnable {
 		p = pushover.New(conf.PKey)
 		r = pushover.NewRecipient(conf.PUser)
 	}
 
-	gatherBalances, msgs, err := arb.Book(true, conf)
-	fmt.Println(gatherBalances)
-	fmt.Println(msgs)
-	fmt.Println(err)
+	return conf, pairs
 }
 
-func repeat() {
-	tick := 500 * time.Millisecond
-	deadline := time.NewTimer(59*time.Minute + 50*time.Second)
-	ticker := time.NewTicker(tick)
+func newExchange(v config.Venue) (exchange.Exchange, error) {
+	t, err := model.ParseExchangeType(v.Exchange)
+	if err != nil {
+		return nil, err
+	}
+
+	id := model.VenueID(v.ID)
+	switch t {
+	case model.ExchangeTypeMe:
+		return m.New(id, v.Account)
+	case model.ExchangeTypeKu:
+		return k.New(id, v.Account), nil
+	case model.ExchangeTypeHu:
+		return h.New(id, v.Account), nil
+	case model.ExchangeTypeCo:
+		return c.New(id, v.Account), nil
+	case model.ExchangeTypeGa:
+		return g.New(id, v.Account), nil
+	default:
+		return nil, fmt.Errorf("unsupported exchange %v", t)
+	}
+}
 
+// venue returns the configured metadata of v without connecting to it.
+func venue(v config.Venue) (model.Venue, error) {
+	t, err := model.ParseExchangeType(v.Exchange)
+	if err != nil {
+		return model.Venue{}, err
+	}
+
+	mv := model.Venue{ID: model.VenueID(v.ID), Exchange: t}
+	switch t {
+	case model.ExchangeTypeMe:
+		mv.Fees, mv.Rules, mv.Deposits = m.Fees, m.Rules, m.Deposits
+	case model.ExchangeTypeKu:
+		mv.Fees, mv.Rules, mv.Deposits = k.Fees, k.Rules, k.Deposits
+	case model.ExchangeTypeHu:
+		mv.Fees, mv.Rules, mv.Deposits = h.Fees, h.Rules, h.Deposits
+	case model.ExchangeTypeCo:
+		mv.Fees, mv.Rules, mv.Deposits = c.Fees, c.Rules, c.Deposits
+	case model.ExchangeTypeGa:
+		mv.Fees, mv.Rules, mv.Deposits = g.Fees, g.Rules, g.Deposits
+	default:
+		return model.Venue{}, fmt.Errorf("unsupported exchange %v", t)
+	}
+	return mv, nil
+}
+
+// backtest replays the recorded books through the engine with the configured
+// venues and fees, starting from SimBalances.
+func backtest(args []string) {
 	conf := config.Load()
-	k.LoadClient(conf)
-	h.LoadClient(conf)
-	c.LoadClient(conf)
-	g.LoadClient()
 
-	if conf.PEnable {
-		p = pushover.New(conf.PKey)
-		r = pushover.NewRecipient(conf.PUser)
+	fs := flag.NewFlagSet("backtest", flag.ExitOnError)
+	dir := fs.String("dir", conf.RecordDir, "directory of the book recordings")
+	minRate := fs.String("min-profit-rate", "0", "minimum quote profit per base traded; 0 uses the engine's")
+	makers := fs.String("maker", "", "maker fee overrides as VENUE=RATIO,...")
+	takers := fs.String("taker", "", "taker fee overrides as VENUE=RATIO,...")
+	withdrawals := fs.String("withdrawal-fee", "", "withdrawal fee overrides as VENUE/CURRENCY[/NETWORK]=FEE,...; without a network, every network of the currency")
+	fs.Parse(args)
+
+	rate, err := decimal.NewFromString(*minRate)
+	if err != nil {
+		panic(fmt.Sprintf("min-profit-rate: %v", err))
+	}
+
+	venues := map[model.VenueID]model.Venue{}
+	balances := map[model.VenueID]model.Balances{}
+	for _, v := range conf.VenueConfigs() {
+		mv, err := venue(v)
+		if err != nil {
+			panic(fmt.Sprintf("venue %v: %v", v.ID, err))
+		}
+		venues[mv.ID] = mv
+
+		b, err := conf.SimBalance(v.ID)
+		if err != nil {
+			panic(err)
+		}
+		balances[mv.ID] = b
+	}
+	for k, maker := range overrides(*makers) {
+		mv, ok := venues[model.VenueID(k)]
+		if !ok {
+			panic(fmt.Sprintf("invalid maker fee for %q, want a configured VENUE", k))
+		}
+		mv.Fees.Maker = maker
+		venues[mv.ID] = mv
+	}
+	for k, taker := range overrides(*takers) {
+		mv, ok := venues[model.VenueID(k)]
+		if !ok {
+			panic(fmt.Sprintf("invalid taker fee for %q, want a configured VENUE", k))
+		}
+		mv.Fees.Taker = taker
+		venues[mv.ID] = mv
+	}
+	for k, fee := range overrides(*withdrawals) {
+		parts := strings.Split(k, "/")
+		mv, ok := venues[model.VenueID(parts[0])]
+		if !ok || len(parts) < 2 || len(parts) > 3 {
+			panic(fmt.Sprintf("invalid withdrawal fee for %q, want a configured VENUE/CURRENCY[/NETWORK]", k))
+		}
+		network := ""
+		if len(parts) == 3 {
+			network = parts[2]
+		}
+		mv.Fees.Withdrawals = withdrawalFee(mv.Fees.Withdrawals, parts[1], network, fee)
+		venues[mv.ID] = mv
+	}
+
+	bt := arb.NewBacktester(venues, balances, rate, conf)
+	if err := record.Scan(*dir, bt.Step); err != nil {
+		fmt.Println("backtest error:", err)
+	}
+	fmt.Println(bt.Report())
+	fmt.Println(bt.Balances())
+}
+
+// overrides parses KEY=DECIMAL,... into a map.
+func overrides(s string) map[string]decimal.Decimal {
+	m := map[string]decimal.Decimal{}
+	for _, o := range strings.Split(s, ",") {
+		o = strings.TrimSpace(o)
+		if o == "" {
+			continue
+		}
+		k, v, found := strings.Cut(o, "=")
+		d, err := decimal.NewFromString(v)
+		if !found || err != nil {
+			panic(fmt.Sprintf("invalid override %q, want KEY=DECIMAL", o))
+		}
+		m[k] = d
+	}
+	return m
+}
+
+// withdrawalFee returns a copy of ws with the fee of currency over network
+// set, or over every network of currency if network is empty. A network not
+// listed is added without a minimum.
+func withdrawalFee(ws map[string][]model.Withdrawal, currency, network string, fee decimal.Decimal) map[string][]model.Withdrawal {
+	m := make(map[string][]model.Withdrawal, len(ws)+1)
+	for c, w := range ws {
+		m[c] = w
+	}
+
+	cw := append([]model.Withdrawal(nil), m[currency]...)
+	found := false
+	for i := range cw {
+		if network == "" || cw[i].Network == network {
+			cw[i].Fee, found = fee, true
+		}
+	}
+	if !found {
+		cw = append(cw, model.Withdrawal{Network: network, Fee: fee})
+	}
+	m[currency] = cw
+	return m
+}
+
+// queryJournal prints the journal entries selected by args.
+func queryJournal(args []string) {
+	conf := config.Load()
+
+	fs := flag.NewFlagSet("journal", flag.ExitOnError)
+	path := fs.String("path", conf.JournalPath, "journal file")
+	since := fs.Duration("since", 0, "only entries this recent")
+	pair := fs.String("pair", "", "only entries of BASE/QUOTE")
+	status := fs.String("status", "", "only entries with this status, e.g. filled")
+	asJSON := fs.Bool("json", false, "print whole entries as JSON lines")
+	fs.Parse(args)
+
+	var q journal.Query
+	if *since > 0 {
+		q.Since = time.Now().Add(-*since)
+	}
+	if *pair != "" {
+		pp, err := model.ParsePair(*pair)
+		if err != nil {
+			panic(err)
+		}
+		q.Pair = pp
+	}
+	q.Status = *status
+
+	j, err := journal.OpenReadOnly(*path)
+	if err != nil {
+		panic(fmt.Sprintf("journal: %v", err))
+	}
+	enc := json.NewEncoder(os.Stdout)
+	err = j.Scan(q, func(e journal.Entry) error {
+		if *asJSON {
+			return enc.Encode(e)
+		}
+		status := e.Status
+		if e.Error != "" {
+			status += ": " + e.Error
+		}
+		fmt.Printf("#%v %v %v p %v: %v\n", e.ID, e.Time.Format(time.RFC3339), e.Pair, e.Plan.Profit, status)
+		for _, o := range e.Orders {
+			side := "sell"
+			if o.Buy {
+				side = "buy"
+			}
+			fill := "?"
+			if o.Status != nil {
+				fill = fmt.Sprintf("fill %v for %v, fee %v %v, %v", o.Status.Filled, o.Status.FilledFunds, o.Status.Fee, o.Status.FeeCurrency, o.Status.State)
+			}
+			fmt.Printf("  %v %v %v @ %v (%v) %v\n", o.Venue, side, o.Size, o.Price, o.ID, fill)
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "journal:", err)
+		os.Exit(1)
+	}
+}
+
+// reportPnL prints the realized PnL of the journaled trades selected by args.
+func reportPnL(args []string) {
+	conf := config.Load()
+
+	fs := flag.NewFlagSet("pnl", flag.ExitOnError)
+	path := fs.String("path", conf.JournalPath, "journal file")
+	since := fs.Duration("since", 0, "only trades this recent")
+	pair := fs.String("pair", "", "only trades of BASE/QUOTE")
+	fs.Parse(args)
+
+	var q journal.Query
+	if *since > 0 {
+		q.Since = time.Now().Add(-*since)
+	}
+	if *pair != "" {
+		pp, err := model.ParsePair(*pair)
+		if err != nil {
+			panic(err)
+		}
+		q.Pair = pp
+	}
+
+	j, err := journal.OpenReadOnly(*path)
+	if err != nil {
+		panic(fmt.Sprintf("journal: %v", err))
+	}
+	l := pnl.NewLedger()
+	if err := j.Scan(q, l.Add); err != nil {
+		fmt.Fprintln(os.Stderr, "pnl:", err)
+		os.Exit(1)
+	}
+
+	line := func(name string, p *pnl.PnL) {
+		fmt.Printf("  %-20v %4v trades  net %v  fees %v  withdrawals %v  expected %v", name, p.Trades, p.Net.StringFixed(4), p.Fees.StringFixed(4), p.Withdrawals.StringFixed(4), p.Expected.StringFixed(4))
+		if len(p.OtherFees) > 0 {
+			fmt.Printf("  other fees %v", p.OtherFees)
+		}
+		fmt.Println()
+	}
+	for _, r := range l.Reports() {
+		fmt.Println(r.Pair)
+		line("total", &r.Total)
+		fmt.Println(" by venue")
+		for _, k := range sortedKeys(r.ByVenue) {
+			line(string(k), r.ByVenue[k])
+		}
+		fmt.Println(" by route")
+		for _, k := range sortedKeys(r.ByRoute) {
+			line(k, r.ByRoute[k])
+		}
+		fmt.Println(" by day")
+		for _, k := range sortedKeys(r.ByDay) {
+			line(k, r.ByDay[k])
+		}
+		fmt.Println(" balance changes")
+		for _, k := range sortedKeys(r.Balances) {
+			fmt.Printf("  %-20v %v\n", k, r.Balances[k])
+		}
+	}
+}
+
+func sortedKeys[K ~string, V any](m map[K]V) []K {
+	ks := make([]K, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Slice(ks, func(i, j int) bool { return ks[i] < ks[j] })
+	return ks
+}
+
+func oneoff() {
+	conf, pairs := load()
+
+	if conf.Journal {
+		arb.Journal(newJournal(conf))
+	}
+
+	for _, pair := range pairs {
+		gatherBalances, msgs, err := arb.Book(pair, true, conf)
+		fmt.Println(gatherBalances)
+		fmt.Println(msgs)
+		fmt.Println(err)
+	}
+}
+
+// repeat runs one loop per pair until the deadline. The loops share the
+// registered venues and balances; when one loop ends, the others follow.
+func repeat() {
+	conf, pairs := load()
+
+	ctx, stop := context.WithTimeout(context.Background(), 59*time.Minute+50*time.Second)
+	defer stop()
+
+	if conf.MetricsAddr != "" {
+		go serve(ctx, conf.MetricsAddr, "/metrics", arb.MetricsHandler())
+	}
+	if conf.ControlAddr != "" {
+		token, err := api.Token(conf)
+		if err != nil {
+			panic(fmt.Sprintf("control token: %v", err))
+		}
+		if conf.ControlToken == "" {
+			fmt.Println("control API token written to", conf.ControlTokenFile)
+		}
+		go serve(ctx, conf.ControlAddr, "/", api.Handler(token, conf))
+	}
+
+	if conf.Journal {
+		arb.Journal(newJournal(conf))
+	}
+	if conf.Record {
+		rec := newRecorder(conf)
+		defer rec.Close()
+		arb.Record(rec)
+	}
+
+	if conf.FeeRefresh > 0 && len(pairs) > 0 {
+		go refreshFees(ctx, conf.FeeRefresh, pairs)
+	}
+	if conf.Rebalance && conf.RebalanceEvery > 0 {
+		go rebalance(ctx, conf, currencies(pairs))
+	}
+
+	var wg sync.WaitGroup
+	for _, pair := range pairs {
+		pair := pair
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer stop()
+			loop(ctx, conf, pair)
+		}()
+	}
+	wg.Wait()
+}
+
+// serve runs an HTTP server of h at path until ctx is done.
+func serve(ctx context.Context, addr, path string, h http.Handler) {
+	mux := http.NewServeMux()
+	mux.Handle(path, h)
+
+	srv := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Println("http server error:", err)
+	}
+}
+
+// newJournal opens the journal the engine writes to, creating it if needed.
+func newJournal(conf *config.Config) *journal.Journal {
+	j, err := journal.Open(conf.JournalPath)
+	if err != nil {
+		panic(fmt.Sprintf("journal: %v", err))
+	}
+	return j
+}
+
+func newRecorder(conf *config.Config) *record.Recorder {
+	rec, err := record.New(conf.RecordDir, conf.RecordRotate)
+	if err != nil {
+		panic(err)
+	}
+	return rec
+}
+
+// recordBooks only records the books of every pair, every conf.RecordEvery,
+// until interrupted.
+func recordBooks() {
+	conf, pairs := load()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	rec := newRecorder(conf)
+	defer rec.Close()
+	arb.Record(rec)
+
+	ticker := time.NewTicker(conf.RecordEvery)
+	defer ticker.Stop()
+
+	for {
+		for _, pair := range pairs {
+			if _, _, err := arb.GatherBooksP(pair); err != nil {
+				fmt.Println(pair, "books error:", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 	}
+}
+
+func refreshFees(ctx context.Context, every time.Duration, pairs []model.Pair) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			arb.RefreshFees(pairs)
+		}
+	}
+}
+
+// currencies returns the distinct currencies traded in pairs.
+func currencies(pairs []model.Pair) []string {
+	var cs []string
+	seen := map[string]bool{}
+	for _, p := range pairs {
+		for _, c := range []string{p.Base, p.Quote} {
+			if !seen[c] {
+				seen[c] = true
+				cs = append(cs, c)
+			}
+		}
+	}
+	return cs
+}
+
+func rebalance(ctx context.Context, conf *config.Config, currencies []string) {
+	ticker := time.NewTicker(conf.RebalanceEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		msgs, err := arb.Rebalance(currencies, conf)
+		if err != nil {
+			msgs = append(msgs, "rebalance error: "+err.Error())
+		}
+		for _, m := range msgs {
+			fmt.Println(m)
+		}
+		if conf.PEnable && len(msgs) > 0 {
+			resp, err := p.SendMessage(&pushover.Message{
+				Message: "rebalance\n" + strings.Join(msgs, "\n---\n"),
+			}, r)
+			if err != nil {
+				fmt.Println("push err:", err.Error())
+			} else {
+				fmt.Println("push ok:", resp.String())
+			}
+		}
+	}
+}
+
+// loop evaluates pair whenever a streamed book changes, after waiting
+// conf.Debounce for further changes. If some venue has to be polled, it also
+// evaluates on a short tick; otherwise the tick only retries books that were
+// too old.
+func loop(ctx context.Context, conf *config.Config, pair model.Pair) {
+	changes, streaming := arb.Watch(pair)
+	tick := 500 * time.Millisecond
+	if streaming && conf.MaxBookAge > 0 {
+		tick = conf.MaxBookAge
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
 
 	var (
 		gatherBalances = true
@@ -73,8 +584,8 @@ func repeat() {
 		waitMultiplier time.Duration = 1
 	)
 	for {
-		fmt.Println("arb at", time.Now().String())
-		gatherBalances, msgs, err = arb.Book(gatherBalances, conf)
+		fmt.Println(pair, "arb at", time.Now().String())
+		gatherBalances, msgs, err = arb.Book(pair, gatherBalances, conf)
 		if err != nil {
 			wait := time.Duration(0)
 			for _, e := range nonfatalErrors {
@@ -91,8 +602,8 @@ func repeat() {
 			}
 			if wait != time.Duration(0) {
 				select {
-				case t := <-deadline.C:
-					fmt.Println("deadline reached, ending at", t.String())
+				case <-ctx.Done():
+					fmt.Println(pair, "deadline reached, ending at", time.Now().String())
 					return
 				case <-time.After(waitMultiplier * wait):
 					waitMultiplier++
@@ -100,7 +611,7 @@ func repeat() {
 					continue
 				}
 			}
-			msg := fmt.Sprintf("[%v] arb ending due to error: %v", time.Now().String(), err.Error())
+			msg := fmt.Sprintf("[%v] %v arb ending due to error: %v", time.Now().String(), pair, err.Error())
 			fmt.Println(msg)
 			if conf.PEnable {
 				resp, err := p.SendMessage(&pushover.Message{
@@ -118,7 +629,7 @@ func repeat() {
 		}
 
 		if conf.PEnable && len(msgs) > 0 {
-			msg := strings.Join(msgs, "\n---\n")
+			msg := pair.String() + "\n" + strings.Join(msgs, "\n---\n")
 			resp, err := p.SendMessage(&pushover.Message{
 				Message: msg,
 			}, r)
@@ -131,8 +642,8 @@ func repeat() {
 
 			if strings.Contains(msg, "skip") {
 				select {
-				case t := <-deadline.C:
-					fmt.Println("deadline reached, ending at", t.String())
+				case <-ctx.Done():
+					fmt.Println(pair, "deadline reached, ending at", time.Now().String())
 					return
 				case <-time.After(waitMultiplier * time.Minute):
 					waitMultiplier++
@@ -143,16 +654,51 @@ func repeat() {
 		}
 
 		select {
-		case t := <-deadline.C:
-			fmt.Println("deadline reached, ending at", t.String())
+		case <-ctx.Done():
+			fmt.Println(pair, "deadline reached, ending at", time.Now().String())
 			return
 		case <-ticker.C:
 			waitMultiplier = 1
 			continue
+		case <-changes:
+			select {
+			case <-ctx.Done():
+				fmt.Println(pair, "deadline reached, ending at", time.Now().String())
+				return
+			case <-time.After(conf.Debounce):
+			}
+			// the changes seen while waiting are covered by this evaluation
+			select {
+			case <-changes:
+			default:
+			}
+			waitMultiplier = 1
+			continue
 		}
 	}
 }
 
 func main() {
-	repeat()
+	cmd := "repeat"
+	if len(os.Args) > 1 {
+		cmd = os.Args[1]
+	}
+
+	switch cmd {
+	case "repeat":
+		repeat()
+	case "oneoff":
+		oneoff()
+	case "record":
+		recordBooks()
+	case "backtest":
+		backtest(os.Args[2:])
+	case "journal":
+		queryJournal(os.Args[2:])
+	case "pnl":
+		reportPnL(os.Args[2:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q, want repeat, oneoff, record, backtest, journal or pnl\n", cmd)
+		os.Exit(2)
+	}
 }
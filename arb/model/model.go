@@ -1,6 +1,9 @@
 package model
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/shopspring/decimal"
 )
 
@@ -34,20 +37,174 @@ func (e ExchangeType) String() string {
 	}
 }
 
-type Balances struct {
-	XCH  decimal.Decimal
-	USDT decimal.Decimal
+// ParseExchangeType is the inverse of ExchangeType.String.
+func ParseExchangeType(s string) (ExchangeType, error) {
+	for _, e := range ExchangeTypes {
+		if e.String() == s {
+			return e, nil
+		}
+	}
+	return ExchangeTypeMax, fmt.Errorf("unknown exchange %q", s)
+}
+
+// Pair is a spot market, e.g. XCH/USDT. Currencies are upper case.
+type Pair struct {
+	Base  string
+	Quote string
+}
+
+var XCHUSDT = Pair{Base: "XCH", Quote: "USDT"}
+
+// ParsePair parses "BASE/QUOTE".
+func ParsePair(s string) (Pair, error) {
+	base, quote, found := strings.Cut(s, "/")
+	if !found || base == "" || quote == "" {
+		return Pair{}, fmt.Errorf("invalid pair %q, want BASE/QUOTE", s)
+	}
+	return Pair{Base: strings.ToUpper(base), Quote: strings.ToUpper(quote)}, nil
+}
+
+func (p Pair) String() string {
+	return p.Base + "/" + p.Quote
 }
 
+// VenueID identifies one account on one exchange, e.g. "Ku" or "Ku2".
+type VenueID string
+
+// Venue is the static metadata the engine needs about a venue.
+type Venue struct {
+	ID       VenueID
+	Exchange ExchangeType
+	Fees     Fees
+	// PairFees are the live fees of the pairs they have been fetched for.
+	PairFees map[Pair]Fees
+	Rules    map[Pair]Rules
+	// Deposits are the networks the venue accepts deposits on, by currency.
+	// A currency without an entry is accepted on any network.
+	Deposits map[string][]string
+}
+
+// FeesOf returns the fees v charges on p.
+func (v Venue) FeesOf(p Pair) Fees {
+	if f, ok := v.PairFees[p]; ok {
+		return f
+	}
+	return v.Fees
+}
+
+// Accepts reports whether v accepts deposits of currency over network.
+func (v Venue) Accepts(currency, network string) bool {
+	ns, ok := v.Deposits[currency]
+	if !ok {
+		return true
+	}
+	for _, n := range ns {
+		if n == network {
+			return true
+		}
+	}
+	return false
+}
+
+// Rules are a venue's order constraints for a pair: the minimum order size in
+// each currency and the size and price increments. Zero means no constraint.
+type Rules struct {
+	MinBase        decimal.Decimal
+	MinQuote       decimal.Decimal
+	BaseIncrement  decimal.Decimal
+	PriceIncrement decimal.Decimal
+}
+
+func (r Rules) Equal(o Rules) bool {
+	return r.MinBase.Equal(o.MinBase) &&
+		r.MinQuote.Equal(o.MinQuote) &&
+		r.BaseIncrement.Equal(o.BaseIncrement) &&
+		r.PriceIncrement.Equal(o.PriceIncrement)
+}
+
+// Increment returns the increment of a precision given in decimal places.
+func Increment(places int32) decimal.Decimal {
+	return decimal.New(1, -places)
+}
+
+// RoundSize rounds size down to the base increment, or to 4 decimal places
+// if the increment is unknown.
+func (r Rules) RoundSize(size decimal.Decimal) decimal.Decimal {
+	if !r.BaseIncrement.IsPositive() {
+		return size.RoundDown(4)
+	}
+	return size.Div(r.BaseIncrement).Floor().Mul(r.BaseIncrement)
+}
+
+// RoundPrice rounds price to the price increment, up if up is set and down
+// otherwise.
+func (r Rules) RoundPrice(price decimal.Decimal, up bool) decimal.Decimal {
+	if !r.PriceIncrement.IsPositive() {
+		return price
+	}
+	q := price.Div(r.PriceIncrement)
+	if up {
+		q = q.Ceil()
+	} else {
+		q = q.Floor()
+	}
+	return q.Mul(r.PriceIncrement)
+}
+
+// Balances are available amounts keyed by currency.
+type Balances map[string]decimal.Decimal
+
 type Order struct {
-	Ex             ExchangeType
+	Venue          VenueID
 	Price          decimal.Decimal
-	EffectivePrice decimal.Decimal
+	EffectivePrice decimal.Decimal // Price after the taker fee of crossing it
 	Amount         decimal.Decimal
 }
 
+// OrderStatus is a venue-agnostic view of a placed order.
+type OrderStatus struct {
+	ID          string
+	Filled      decimal.Decimal // base currency
+	FilledFunds decimal.Decimal // quote currency
+	Fee         decimal.Decimal
+	FeeCurrency string
+	Active      bool // still resting on the book
+	State       string
+}
+
+// Fees are a venue's fee ratios. Maker applies to orders that rest on the
+// book and may be negative, a rebate; Taker applies to orders that cross it.
 type Fees struct {
-	MakerTakerRatio    decimal.Decimal
-	WithdrawalFlatXCH  decimal.Decimal
-	WithdrawalFlatUSDT decimal.Decimal
+	Maker       decimal.Decimal
+	Taker       decimal.Decimal
+	Withdrawals map[string][]Withdrawal // by currency
+}
+
+// Withdrawal is the cost of withdrawing a currency over one network.
+type Withdrawal struct {
+	Network string
+	Fee     decimal.Decimal // flat, in the currency withdrawn
+	Min     decimal.Decimal // smallest amount that can be withdrawn
+	// OnTop means Fee is charged on top of the amount withdrawn rather than
+	// out of it.
+	OnTop bool
+}
+
+func (f Fees) rate(maker bool) decimal.Decimal {
+	if maker {
+		return f.Maker
+	}
+	return f.Taker
+}
+
+// AskAddition returns the multiplier from a price to the effective price of
+// buying at it as a maker or a taker.
+func (f Fees) AskAddition(maker bool) decimal.Decimal {
+	return decimal.NewFromInt(1).Add(f.rate(maker))
+}
+
+// BidReduction returns the multiplier from a price to the effective price of
+// selling at it as a maker or a taker.
+func (f Fees) BidReduction(maker bool) decimal.Decimal {
+	return decimal.NewFromInt(1).Sub(f.rate(maker))
 }
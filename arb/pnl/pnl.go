@@ -0,0 +1,223 @@
+// Package pnl computes realized profit and loss from the fills in the trade
+// journal.
+package pnl
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/L3Sota/arbo/arb/journal"
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/shopspring/decimal"
+)
+
+// PnL is realized profit in the quote currency. Base amounts are valued at
+// the mean fill price of the trade they belong to.
+type PnL struct {
+	Trades      int
+	Net         decimal.Decimal // after fees and withdrawals
+	Fees        decimal.Decimal // charged in base or quote
+	Withdrawals decimal.Decimal // amortized, as estimated by arbo
+	// OtherFees are fees charged in neither currency of the pair, e.g. in
+	// venue points, by currency. They are not valued.
+	OtherFees model.Balances
+	Expected  decimal.Decimal // arbo's profit for the same trades
+}
+
+func (p *PnL) add(o PnL) {
+	p.Trades += o.Trades
+	p.Net = p.Net.Add(o.Net)
+	p.Fees = p.Fees.Add(o.Fees)
+	p.Withdrawals = p.Withdrawals.Add(o.Withdrawals)
+	p.Expected = p.Expected.Add(o.Expected)
+	for cur, amt := range o.OtherFees {
+		if p.OtherFees == nil {
+			p.OtherFees = model.Balances{}
+		}
+		p.OtherFees[cur] = p.OtherFees[cur].Add(amt)
+	}
+}
+
+// Report is the realized PnL of one pair.
+type Report struct {
+	Pair    model.Pair
+	Total   PnL
+	ByVenue map[model.VenueID]*PnL
+	ByRoute map[string]*PnL // "buy venues→sell venues"
+	ByDay   map[string]*PnL // UTC, as 2006-01-02
+
+	// Balances is the change of each venue's balances between the first and
+	// the last journaled balance view.
+	Balances map[model.VenueID]model.Balances
+
+	first, last map[model.VenueID]model.Balances
+}
+
+// Ledger accumulates journal entries into one report per pair.
+type Ledger struct {
+	reports map[model.Pair]*Report
+}
+
+func NewLedger() *Ledger {
+	return &Ledger{reports: map[model.Pair]*Report{}}
+}
+
+// Add accounts for e. Entries without fills only contribute their balances.
+// Its signature fits journal.Scan.
+func (l *Ledger) Add(e journal.Entry) error {
+	r, ok := l.reports[e.Pair]
+	if !ok {
+		r = &Report{
+			Pair:    e.Pair,
+			ByVenue: map[model.VenueID]*PnL{},
+			ByRoute: map[string]*PnL{},
+			ByDay:   map[string]*PnL{},
+		}
+		l.reports[e.Pair] = r
+	}
+	if e.Balances != nil {
+		if r.first == nil {
+			r.first = e.Balances
+		}
+		r.last = e.Balances
+	}
+
+	total, venues, ok := Realized(e)
+	if !ok {
+		return nil
+	}
+	r.Total.add(total)
+	for v, p := range venues {
+		if r.ByVenue[v] == nil {
+			r.ByVenue[v] = &PnL{}
+		}
+		r.ByVenue[v].add(p)
+	}
+	route := Route(e)
+	if r.ByRoute[route] == nil {
+		r.ByRoute[route] = &PnL{}
+	}
+	r.ByRoute[route].add(total)
+	day := e.Time.UTC().Format("2006-01-02")
+	if r.ByDay[day] == nil {
+		r.ByDay[day] = &PnL{}
+	}
+	r.ByDay[day].add(total)
+	return nil
+}
+
+// Reports returns the reports, ordered by pair.
+func (l *Ledger) Reports() []Report {
+	rs := make([]Report, 0, len(l.reports))
+	for _, r := range l.reports {
+		c := *r
+		c.Balances = delta(r.first, r.last)
+		rs = append(rs, c)
+	}
+	sort.Slice(rs, func(i, j int) bool { return rs[i].Pair.String() < rs[j].Pair.String() })
+	return rs
+}
+
+func delta(first, last map[model.VenueID]model.Balances) map[model.VenueID]model.Balances {
+	m := map[model.VenueID]model.Balances{}
+	for v, b := range last {
+		d := model.Balances{}
+		for cur, amt := range b {
+			d[cur] = amt.Sub(first[v][cur])
+		}
+		for cur, amt := range first[v] {
+			if _, ok := b[cur]; !ok {
+				d[cur] = amt.Neg()
+			}
+		}
+		m[v] = d
+	}
+	return m
+}
+
+// Route names the venues e bought on and sold on, e.g. "Ku→Ga+Me".
+func Route(e journal.Entry) string {
+	var buys, sells []string
+	for _, o := range e.Orders {
+		if o.Buy {
+			buys = append(buys, string(o.Venue))
+		} else {
+			sells = append(sells, string(o.Venue))
+		}
+	}
+	sort.Strings(buys)
+	sort.Strings(sells)
+	return strings.Join(buys, "+") + "→" + strings.Join(sells, "+")
+}
+
+// Realized returns the PnL of the fills of e, in total and by venue, and
+// whether anything filled. The withdrawal costs arbo estimated are spread
+// over the venues they are paid from: base over the buying venues and quote
+// over the selling venues, in proportion to what each filled.
+func Realized(e journal.Entry) (PnL, map[model.VenueID]PnL, bool) {
+	p := e.Pair
+
+	filled, funds := decimal.Zero, decimal.Zero
+	boughtBase, soldQuote := decimal.Zero, decimal.Zero
+	for _, o := range e.Orders {
+		if o.Status == nil {
+			continue
+		}
+		filled = filled.Add(o.Status.Filled)
+		funds = funds.Add(o.Status.FilledFunds)
+		if o.Buy {
+			boughtBase = boughtBase.Add(o.Status.Filled)
+		} else {
+			soldQuote = soldQuote.Add(o.Status.FilledFunds)
+		}
+	}
+	if !filled.IsPositive() {
+		return PnL{}, nil, false
+	}
+	price := funds.Div(filled)
+
+	total := PnL{Trades: 1, Expected: e.Plan.Profit}
+	venues := map[model.VenueID]PnL{}
+	for _, o := range e.Orders {
+		s := o.Status
+		if s == nil || !s.Filled.IsPositive() {
+			continue
+		}
+
+		var v PnL
+		switch s.FeeCurrency {
+		case p.Quote:
+			v.Fees = s.Fee
+		case p.Base:
+			v.Fees = s.Fee.Mul(price)
+		default:
+			if !s.Fee.IsZero() {
+				v.OtherFees = model.Balances{s.FeeCurrency: s.Fee}
+			}
+		}
+
+		// gross value of the fill at the trade's mean price
+		value := s.Filled.Mul(price).Sub(s.FilledFunds)
+		if o.Buy {
+			if boughtBase.IsPositive() {
+				v.Withdrawals = e.Plan.WithdrawBase.Mul(s.Filled).Div(boughtBase).Mul(price)
+			}
+		} else {
+			value = value.Neg()
+			if soldQuote.IsPositive() {
+				v.Withdrawals = e.Plan.WithdrawQuote.Mul(s.FilledFunds).Div(soldQuote)
+			}
+		}
+		v.Net = value.Sub(v.Fees).Sub(v.Withdrawals)
+
+		vv := venues[o.Venue]
+		vv.add(v)
+		venues[o.Venue] = vv
+		total.add(v)
+	}
+	for v, vv := range venues {
+		vv.Trades = 1
+		venues[v] = vv
+	}
+	return total, venues, true
+}
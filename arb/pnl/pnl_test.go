@@ -0,0 +1,75 @@
+package pnl
+
+import (
+	"testing"
+
+	"github.com/L3Sota/arbo/arb/journal"
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/google/go-cmp/cmp"
+	"github.com/shopspring/decimal"
+)
+
+func d(s string) decimal.Decimal { return decimal.RequireFromString(s) }
+
+func TestRealized(t *testing.T) {
+	t.Parallel()
+
+	e := journal.Entry{
+		Pair: model.XCHUSDT,
+		Plan: journal.Plan{
+			Profit:        d("1.5"),
+			WithdrawBase:  d("0.01"),
+			WithdrawQuote: d("0.5"),
+		},
+		Orders: []journal.Order{
+			// Gate charges buys in the base currency
+			{Venue: "Ga", Buy: true, Status: &model.OrderStatus{Filled: d("1"), FilledFunds: d("30"), Fee: d("0.002"), FeeCurrency: "XCH"}},
+			{Venue: "Ku", Status: &model.OrderStatus{Filled: d("1"), FilledFunds: d("32"), Fee: d("0.032"), FeeCurrency: "USDT"}},
+		},
+	}
+
+	total, venues, ok := Realized(e)
+	if !ok {
+		t.Fatal("Realized() found no fills")
+	}
+	// mean price 31: Ga +1 -0.062 -0.31, Ku +1 -0.032 -0.5
+	want := PnL{Trades: 1, Net: d("1.096"), Fees: d("0.094"), Withdrawals: d("0.81"), Expected: d("1.5")}
+	if diff := cmp.Diff(want, total); diff != "" {
+		t.Errorf("total mismatch (-want +got):\n%s", diff)
+	}
+	wantGa := PnL{Trades: 1, Net: d("0.628"), Fees: d("0.062"), Withdrawals: d("0.31")}
+	if diff := cmp.Diff(wantGa, venues["Ga"]); diff != "" {
+		t.Errorf("Ga mismatch (-want +got):\n%s", diff)
+	}
+	if got := Route(e); got != "Ga→Ku" {
+		t.Errorf("Route() = %q, want Ga→Ku", got)
+	}
+}
+
+func TestRealizedFeeCurrencies(t *testing.T) {
+	t.Parallel()
+
+	e := journal.Entry{
+		Pair: model.XCHUSDT,
+		Plan: journal.Plan{Profit: d("1")},
+		Orders: []journal.Order{
+			// CoinEx charges buys in the base currency unless paid in CET
+			{Venue: "Co", Buy: true, Status: &model.OrderStatus{Filled: d("2"), FilledFunds: d("60"), Fee: d("0.004"), FeeCurrency: "XCH"}},
+			{Venue: "Co2", Status: &model.OrderStatus{Filled: d("2"), FilledFunds: d("62"), Fee: d("0.1"), FeeCurrency: "CET"}},
+		},
+	}
+
+	total, venues, ok := Realized(e)
+	if !ok {
+		t.Fatal("Realized() found no fills")
+	}
+	// mean price 30.5: Co +1 -0.122, Co2 +1 and 0.1 CET unvalued
+	want := PnL{Trades: 1, Net: d("1.878"), Fees: d("0.122"), OtherFees: model.Balances{"CET": d("0.1")}, Expected: d("1")}
+	if diff := cmp.Diff(want, total); diff != "" {
+		t.Errorf("total mismatch (-want +got):\n%s", diff)
+	}
+	wantCo2 := PnL{Trades: 1, Net: d("1"), OtherFees: model.Balances{"CET": d("0.1")}}
+	if diff := cmp.Diff(wantCo2, venues["Co2"]); diff != "" {
+		t.Errorf("Co2 mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,225 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Class is a group of endpoints that shares a budget.
+type Class uint8
+
+const (
+	Public Class = iota
+	Private
+	Order
+	ClassMax
+)
+
+var Classes = [ClassMax]Class{Public, Private, Order}
+
+func (c Class) String() string {
+	switch c {
+	case Public:
+		return "public"
+	case Private:
+		return "private"
+	case Order:
+		return "order"
+	default:
+		return "unknown"
+	}
+}
+
+// Limit is a documented request limit of N requests per Per.
+type Limit struct {
+	N   int
+	Per time.Duration
+}
+
+// Headers names the response headers in which a venue reports its budget.
+// Empty names are not read.
+type Headers struct {
+	Remaining string // budget left in the current window
+	Reset     string // when the window resets
+	// ResetUnit is the unit of a Reset relative to now; zero means Reset is
+	// a Unix timestamp in seconds.
+	ResetUnit time.Duration
+	// Weight is how much of the reported budget one request of each class
+	// uses. The headers are not applied to a class of zero weight.
+	Weight [ClassMax]float64
+}
+
+// Limiter is a set of token buckets, one per class, for a single venue. It is
+// safe for concurrent use.
+type Limiter struct {
+	buckets [ClassMax]*bucket
+	headers Headers
+}
+
+func New(limits [ClassMax]Limit, h Headers) *Limiter {
+	l := &Limiter{headers: h}
+	for c, lim := range limits {
+		l.buckets[c] = newBucket(lim)
+	}
+	return l
+}
+
+// Wait blocks until a request of class c may be made or ctx is done.
+func (l *Limiter) Wait(ctx context.Context, c Class) error {
+	d := l.buckets[c].reserve(time.Now())
+	if d <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// Observe adapts the bucket of class c to the budget reported in h, if any.
+func (l *Limiter) Observe(c Class, h http.Header) {
+	w := l.headers.Weight[c]
+	if l.headers.Remaining == "" || w <= 0 {
+		return
+	}
+	v := h.Get(l.headers.Remaining)
+	if v == "" {
+		return
+	}
+	remaining, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	var reset time.Time
+	if r, err := strconv.ParseInt(h.Get(l.headers.Reset), 10, 64); err == nil {
+		if l.headers.ResetUnit == 0 {
+			reset = time.Unix(r, 0)
+		} else {
+			reset = now.Add(time.Duration(r) * l.headers.ResetUnit)
+		}
+	}
+
+	l.buckets[c].observe(now, math.Floor(remaining/w), reset)
+}
+
+// Remaining returns the requests of class c that can be made without
+// waiting. It is negative while requests are queued.
+func (l *Limiter) Remaining(c Class) float64 {
+	return l.buckets[c].remaining(time.Now())
+}
+
+// Transport rate limits the requests made through it and observes the
+// budgets reported in the responses.
+type Transport struct {
+	Base     http.RoundTripper // http.DefaultTransport if nil
+	Limiter  *Limiter
+	Classify func(*http.Request) Class
+}
+
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c := t.Classify(r)
+	if err := t.Limiter.Wait(r.Context(), c); err != nil {
+		return nil, err
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(r)
+	if err == nil {
+		t.Limiter.Observe(c, resp.Header)
+	}
+	return resp, err
+}
+
+// bucket is a token bucket. Reservations may take it below zero; the
+// deficit is how long later callers have to wait.
+type bucket struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+	until  time.Time // venue reported the budget exhausted until then
+}
+
+func newBucket(l Limit) *bucket {
+	b := &bucket{
+		rate:  math.Inf(1),
+		burst: math.Inf(1),
+	}
+	if l.N > 0 && l.Per > 0 {
+		b.rate = float64(l.N) / l.Per.Seconds()
+		b.burst = float64(l.N)
+	}
+	b.tokens = b.burst
+	return b
+}
+
+// refill adds the tokens accrued since the last call. The caller must hold
+// b.mu.
+func (b *bucket) refill(now time.Time) {
+	if !b.last.IsZero() && !math.IsInf(b.rate, 1) {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+}
+
+// reserve takes a token and returns how long to wait before using it.
+func (b *bucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+	if math.IsInf(b.tokens, 1) {
+		return 0
+	}
+	b.tokens--
+
+	var d time.Duration
+	if b.tokens < 0 {
+		d = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	if w := b.until.Sub(now); w > d {
+		d = w
+	}
+	return d
+}
+
+// observe lowers the tokens to the requests the venue reports remaining and
+// holds them until reset if there are none.
+func (b *bucket) observe(now time.Time, remaining float64, reset time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+	if remaining < b.tokens {
+		b.tokens = remaining
+	}
+	if remaining <= 0 && reset.After(b.until) {
+		b.until = reset
+	}
+}
+
+func (b *bucket) remaining(now time.Time) float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+	if now.Before(b.until) {
+		return 0
+	}
+	return b.tokens
+}
@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBucket(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1700000000, 0)
+	b := newBucket(Limit{N: 2, Per: time.Second})
+
+	if d := b.reserve(now); d != 0 {
+		t.Errorf("first reserve waits %v", d)
+	}
+	if d := b.reserve(now); d != 0 {
+		t.Errorf("second reserve waits %v", d)
+	}
+	if d := b.reserve(now); d != 500*time.Millisecond {
+		t.Errorf("reserve over the burst waits %v, want 500ms", d)
+	}
+	if got := b.remaining(now); got != -1 {
+		t.Errorf("remaining = %v, want -1", got)
+	}
+
+	// refills at 2/s up to the burst
+	if got := b.remaining(now.Add(time.Second)); got != 1 {
+		t.Errorf("remaining after 1s = %v, want 1", got)
+	}
+	if got := b.remaining(now.Add(time.Minute)); got != 2 {
+		t.Errorf("remaining after 1m = %v, want 2", got)
+	}
+}
+
+func TestBucketObserve(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1700000000, 0)
+	b := newBucket(Limit{N: 10, Per: time.Second})
+
+	// a higher report does not raise the bucket
+	b.observe(now, 20, time.Time{})
+	if got := b.remaining(now); got != 10 {
+		t.Errorf("remaining = %v, want 10", got)
+	}
+	b.observe(now, 3, time.Time{})
+	if got := b.remaining(now); got != 3 {
+		t.Errorf("remaining = %v, want 3", got)
+	}
+
+	// exhausted until the reported reset
+	reset := now.Add(5 * time.Second)
+	b.observe(now, 0, reset)
+	if got := b.remaining(now.Add(time.Second)); got != 0 {
+		t.Errorf("remaining before reset = %v, want 0", got)
+	}
+	if d := b.reserve(now.Add(time.Second)); d != 4*time.Second {
+		t.Errorf("reserve before reset waits %v, want 4s", d)
+	}
+	if got := b.remaining(reset); got != 10 {
+		t.Errorf("remaining at reset = %v, want 10", got)
+	}
+}
+
+func TestUnlimited(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1700000000, 0)
+	b := newBucket(Limit{})
+	for i := 0; i < 1000; i++ {
+		if d := b.reserve(now); d != 0 {
+			t.Fatalf("reserve %d waits %v", i, d)
+		}
+	}
+	if got := b.remaining(now); !math.IsInf(got, 1) {
+		t.Errorf("remaining = %v, want +Inf", got)
+	}
+}
+
+func TestObserveWeight(t *testing.T) {
+	t.Parallel()
+
+	l := New([ClassMax]Limit{
+		Public:  {N: 100, Per: time.Hour},
+		Private: {N: 100, Per: time.Hour},
+		Order:   {N: 100, Per: time.Hour},
+	}, Headers{
+		Remaining: "Remaining",
+		Weight:    [ClassMax]float64{Public: 4, Private: 1},
+	})
+	h := http.Header{}
+	h.Set("Remaining", "42")
+	for _, c := range Classes {
+		l.Observe(c, h)
+	}
+
+	// the refill over the test's run is negligible at 100/h
+	for c, want := range map[Class]float64{Public: 10, Private: 42, Order: 100} {
+		if got := l.Remaining(c); math.Abs(got-want) > 0.01 {
+			t.Errorf("%v remaining = %v, want %v", c, got, want)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package arb
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/L3Sota/arbo/arb/config"
+	"github.com/L3Sota/arbo/arb/exchange"
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/shopspring/decimal"
+)
+
+// Transfer is a withdrawal that moves funds from one venue to another.
+type Transfer struct {
+	From       model.VenueID
+	To         model.VenueID
+	Currency   string
+	Withdrawal model.Withdrawal
+	Address    string
+	Amount     decimal.Decimal
+}
+
+func (t Transfer) String() string {
+	return fmt.Sprintf("%v %v %v -> %v over %v (fee %v) to %v", t.Amount, t.Currency, t.From, t.To, t.Withdrawal.Network, t.Withdrawal.Fee, t.Address)
+}
+
+// Debit is what the transfer takes from its source.
+func (t Transfer) Debit() decimal.Decimal {
+	if t.Withdrawal.OnTop {
+		return t.Amount.Add(t.Withdrawal.Fee)
+	}
+	return t.Amount
+}
+
+// Credit is what the transfer brings to its destination.
+func (t Transfer) Credit() decimal.Decimal {
+	if t.Withdrawal.OnTop {
+		return t.Amount
+	}
+	return t.Amount.Sub(t.Withdrawal.Fee)
+}
+
+// pending is a transfer that has left its source but has not been seen
+// arriving at its destination.
+type pending struct {
+	Transfer
+	sent   time.Time
+	before decimal.Decimal // destination balance when sent
+}
+
+// inflight are the pending transfers. They are guarded by funds.mu.
+var inflight []pending
+
+// arrived drops the pending transfers whose destinations are credited, or
+// that are older than timeout. The caller must hold funds.mu.
+func arrived(balances map[model.VenueID]model.Balances, now time.Time, timeout time.Duration) {
+	kept := inflight[:0]
+	for _, t := range inflight {
+		got := balances[t.To][t.Currency].Sub(t.before)
+		if got.GreaterThanOrEqual(t.Credit()) || now.Sub(t.sent) > timeout {
+			continue
+		}
+		kept = append(kept, t)
+	}
+	inflight = kept
+}
+
+// Rebalance refreshes the shared balances, proposes transfers that bring them
+// back toward their targets and, unless conf.RebalanceDryRun is set, makes
+// them through the venues' withdrawal APIs. Transfers still in flight count
+// toward their destinations, so a top-up is not sent again before it arrives.
+func Rebalance(currencies []string, conf *config.Config) ([]string, error) {
+	// reserve the transfers under the lock, then withdraw without it
+	funds.mu.Lock()
+	if err := funds.refresh(); err != nil {
+		funds.mu.Unlock()
+		return nil, fmt.Errorf("balances: %w", err)
+	}
+	bb := funds.snapshot()
+	arrived(bb, time.Now(), conf.RebalanceTimeout)
+	ts, err := plan(currencies, exchange.Venues(), bb, inflight, conf)
+	if err != nil {
+		funds.mu.Unlock()
+		return nil, err
+	}
+	if !conf.RebalanceDryRun {
+		for _, t := range ts {
+			funds.hold(t.From, t.Currency, t.Debit())
+		}
+		observeBalances(funds.snapshot())
+	}
+	funds.mu.Unlock()
+
+	msgs := make([]string, 0, len(ts))
+	var failed error
+	for _, t := range ts {
+		if conf.RebalanceDryRun {
+			msgs = append(msgs, "(dry run) withdraw "+t.String())
+			continue
+		}
+		if failed != nil {
+			unreserve(t)
+			continue
+		}
+
+		e, ok := exchange.Get(t.From)
+		if !ok {
+			unreserve(t)
+			continue
+		}
+		w, ok := e.(exchange.Withdrawer)
+		if !ok {
+			unreserve(t)
+			msgs = append(msgs, fmt.Sprintf("(skipped: %v cannot withdraw) %v", t.From, t))
+			continue
+		}
+		id, err := w.Withdraw(t.Withdrawal, t.Currency, t.Address, t.Amount)
+		if err != nil {
+			unreserve(t)
+			failed = fmt.Errorf("%v withdraw: %w", t.From, err)
+			continue
+		}
+		track(t, bb[t.To][t.Currency])
+		msgs = append(msgs, fmt.Sprintf("withdrew %v: %v", id, t))
+	}
+	return msgs, failed
+}
+
+// unreserve returns the funds reserved for a transfer that was not made.
+func unreserve(t Transfer) {
+	funds.mu.Lock()
+	defer funds.mu.Unlock()
+
+	funds.release(t.From, t.Currency, t.Debit())
+	observeBalances(funds.snapshot())
+}
+
+// track deducts the funds reserved for t, which has left its source, and
+// keeps it in flight until its destination holds before plus its credit.
+func track(t Transfer, before decimal.Decimal) {
+	funds.mu.Lock()
+	defer funds.mu.Unlock()
+
+	funds.commit(t.From, t.Currency, t.Debit())
+	observeBalances(funds.snapshot())
+
+	inflight = append(inflight, pending{Transfer: t, sent: time.Now(), before: before})
+}
+
+// plan tops up each venue holding less than conf.RebalanceBelow of its
+// target amount of a currency, drawing first on the venues furthest above
+// their targets. Funds in flight count as already at their destinations.
+// Transfers leave their fee out of the donor's surplus and cover it in what
+// they bring to the destination.
+func plan(currencies []string, venues map[model.VenueID]model.Venue, balances map[model.VenueID]model.Balances, inflight []pending, conf *config.Config) ([]Transfer, error) {
+	below := decimal.NewFromFloat(conf.RebalanceBelow)
+
+	// balances as they will be once the transfers in flight arrive
+	eventual := make(map[model.VenueID]model.Balances, len(balances))
+	for v, b := range balances {
+		if b == nil {
+			continue
+		}
+		c := make(model.Balances, len(b))
+		for cur, amt := range b {
+			c[cur] = amt
+		}
+		eventual[v] = c
+	}
+	for _, t := range inflight {
+		if b := eventual[t.To]; b != nil {
+			b[t.Currency] = b[t.Currency].Add(t.Credit())
+		}
+	}
+
+	var ts []Transfer
+	for _, cur := range currencies {
+		targets, err := targets(cur, eventual, conf)
+		if err != nil {
+			return nil, err
+		}
+
+		ids := make([]model.VenueID, 0, len(targets))
+		for v := range targets {
+			ids = append(ids, v)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+		surplus := map[model.VenueID]decimal.Decimal{}
+		var short []model.VenueID
+		for _, v := range ids {
+			b, t := eventual[v][cur], targets[v]
+			if b.GreaterThan(t) {
+				surplus[v] = b.Sub(t)
+			} else if b.LessThan(t.Mul(below)) {
+				short = append(short, v)
+			}
+		}
+
+		for _, to := range short {
+			need := targets[to].Sub(eventual[to][cur])
+
+			donors := make([]model.VenueID, 0, len(surplus))
+			for v := range surplus {
+				donors = append(donors, v)
+			}
+			sort.Slice(donors, func(i, j int) bool {
+				if c := surplus[donors[i]].Cmp(surplus[donors[j]]); c != 0 {
+					return c > 0
+				}
+				return donors[i] < donors[j]
+			})
+
+			for _, from := range donors {
+				if !need.IsPositive() {
+					break
+				}
+				w, addr, ok := transferRoute(venues, from, to, cur, decimal.Min(need, surplus[from]), conf)
+				if !ok {
+					continue
+				}
+				t := Transfer{
+					From:       from,
+					To:         to,
+					Currency:   cur,
+					Withdrawal: w,
+					Address:    addr,
+				}
+				if w.OnTop {
+					t.Amount = decimal.Min(need, surplus[from].Sub(w.Fee))
+				} else {
+					t.Amount = decimal.Min(need.Add(w.Fee), surplus[from])
+				}
+				if !t.Credit().IsPositive() || t.Amount.LessThan(w.Min) {
+					continue
+				}
+				ts = append(ts, t)
+				surplus[from] = surplus[from].Sub(t.Debit())
+				need = need.Sub(t.Credit())
+			}
+		}
+	}
+	return ts, nil
+}
+
+// targets returns the target amount of currency on each venue that reports
+// balances. Configured shares come first and the rest is split equally.
+func targets(currency string, balances map[model.VenueID]model.Balances, conf *config.Config) (map[model.VenueID]decimal.Decimal, error) {
+	total := decimal.Zero
+	shares := map[model.VenueID]decimal.Decimal{}
+	var rest []model.VenueID
+	left := decimal.NewFromInt(1)
+	for v, b := range balances {
+		if b == nil {
+			continue
+		}
+		total = total.Add(b[currency])
+
+		share, ok, err := conf.RebalanceTarget(string(v), currency)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			rest = append(rest, v)
+			continue
+		}
+		shares[v] = decimal.NewFromFloat(share)
+		left = left.Sub(shares[v])
+	}
+	if left.IsNegative() {
+		return nil, fmt.Errorf("%v rebalance targets add up to more than 1", currency)
+	}
+	for _, v := range rest {
+		shares[v] = left.Div(decimal.NewFromInt(int64(len(rest))))
+	}
+
+	m := make(map[model.VenueID]decimal.Decimal, len(shares))
+	for v, s := range shares {
+		m[v] = total.Mul(s)
+	}
+	return m, nil
+}
+
+// transferRoute returns the cheapest withdrawal of amount of currency from
+// venue from that to accepts and that has an allowlisted address.
+func transferRoute(venues map[model.VenueID]model.Venue, from, to model.VenueID, currency string, amount decimal.Decimal, conf *config.Config) (model.Withdrawal, string, bool) {
+	var (
+		best  model.Withdrawal
+		addr  string
+		found bool
+	)
+	for _, w := range venues[from].Fees.Withdrawals[currency] {
+		if amount.LessThan(w.Min) || !venues[to].Accepts(currency, w.Network) {
+			continue
+		}
+		a, ok := conf.WithdrawAddress(string(to), currency, w.Network)
+		if !ok {
+			continue
+		}
+		if !found || w.Fee.LessThan(best.Fee) {
+			best, addr, found = w, a, true
+		}
+	}
+	return best, addr, found
+}
@@ -0,0 +1,248 @@
+package arb
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/L3Sota/arbo/arb/config"
+	"github.com/L3Sota/arbo/arb/exchange"
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/google/go-cmp/cmp"
+	"github.com/shopspring/decimal"
+)
+
+func TestTargets(t *testing.T) {
+	t.Parallel()
+
+	balances := map[model.VenueID]model.Balances{
+		"Ku": {"USDT": dec("100")},
+		"Ga": {"USDT": dec("300")},
+		"Hu": {},
+		"Co": nil, // reports no balances
+	}
+	for _, tc := range []struct {
+		name    string
+		targets []string
+		want    map[model.VenueID]decimal.Decimal
+		err     bool
+	}{
+		{
+			name:    "rest split equally",
+			targets: []string{"Ku/USDT=0.5", "Ku/XCH=1"},
+			want:    map[model.VenueID]decimal.Decimal{"Ku": dec("200"), "Ga": dec("100"), "Hu": dec("100")},
+		},
+		{
+			name:    "all configured",
+			targets: []string{"Ku/USDT=0.25", "Ga/USDT=0.25", "Hu/USDT=0.5"},
+			want:    map[model.VenueID]decimal.Decimal{"Ku": dec("100"), "Ga": dec("100"), "Hu": dec("200")},
+		},
+		{
+			name:    "over 1",
+			targets: []string{"Ku/USDT=0.7", "Ga/USDT=0.4"},
+			err:     true,
+		},
+		{
+			name:    "malformed",
+			targets: []string{"Ku/USDT"},
+			err:     true,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := targets("USDT", balances, &config.Config{RebalanceTargets: tc.targets})
+			if (err != nil) != tc.err {
+				t.Fatalf("targets() error = %v, want error %v", err, tc.err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("targets() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPlan(t *testing.T) {
+	t.Parallel()
+
+	venues := map[model.VenueID]model.Venue{
+		"Ku": {ID: "Ku", Deposits: map[string][]string{"USDT": {"SOL", "TRC20"}}},
+		"Ga": {ID: "Ga", Fees: model.Fees{Withdrawals: map[string][]model.Withdrawal{"USDT": {
+			{Network: "TRC20", Fee: dec("0.5")},
+			{Network: "SOL", Fee: dec("1")},
+			{Network: "ARB", Fee: dec("0.1")}, // not accepted by Ku
+		}}}},
+		"Hu": {ID: "Hu", Fees: model.Fees{Withdrawals: map[string][]model.Withdrawal{"USDT": {
+			{Network: "SOL", Fee: dec("1"), OnTop: true},
+		}}}},
+	}
+	balances := map[model.VenueID]model.Balances{
+		"Ku": {"USDT": dec("0")},
+		"Ga": {"USDT": dec("200")},
+		"Hu": {"USDT": dec("160")},
+	}
+	sol := config.Config{
+		RebalanceBelow:    0.5,
+		RebalanceTargets:  []string{"Ku/USDT=0.5"},
+		WithdrawAddresses: []string{"Ku/USDT/SOL=kusol", "Ku/USDT/ARB=kuarb"},
+	}
+	trc := sol
+	trc.WithdrawAddresses = []string{"Ku/USDT/SOL=kusol", "Ku/USDT/TRC20=kutrc"}
+	none := sol
+	none.WithdrawAddresses = nil
+
+	for _, tc := range []struct {
+		name     string
+		balances map[model.VenueID]model.Balances
+		inflight []pending
+		conf     config.Config
+		want     []Transfer
+	}{
+		{
+			// Ku targets 180 and needs all of it. Ga has the larger surplus,
+			// 110, and pays its fee out of the amount; Hu covers the rest and
+			// pays its fee on top.
+			name:     "largest donor first, fees left out of surplus",
+			balances: balances,
+			conf:     sol,
+			want: []Transfer{
+				{From: "Ga", To: "Ku", Currency: "USDT", Withdrawal: venues["Ga"].Fees.Withdrawals["USDT"][1], Address: "kusol", Amount: dec("110")},
+				{From: "Hu", To: "Ku", Currency: "USDT", Withdrawal: venues["Hu"].Fees.Withdrawals["USDT"][0], Address: "kusol", Amount: dec("69")},
+			},
+		},
+		{
+			name:     "cheapest allowlisted network",
+			balances: balances,
+			conf:     trc,
+			want: []Transfer{
+				{From: "Ga", To: "Ku", Currency: "USDT", Withdrawal: venues["Ga"].Fees.Withdrawals["USDT"][0], Address: "kutrc", Amount: dec("110")},
+				{From: "Hu", To: "Ku", Currency: "USDT", Withdrawal: venues["Hu"].Fees.Withdrawals["USDT"][0], Address: "kusol", Amount: dec("69")},
+			},
+		},
+		{
+			name:     "no allowlisted address",
+			balances: balances,
+			conf:     none,
+		},
+		{
+			name: "in flight counts at the destination",
+			balances: map[model.VenueID]model.Balances{
+				"Ku": {"USDT": dec("0")},
+				"Ga": {"USDT": dec("90")},
+				"Hu": {"USDT": dec("160")},
+			},
+			inflight: []pending{{Transfer: Transfer{From: "Ga", To: "Ku", Currency: "USDT", Withdrawal: venues["Ga"].Fees.Withdrawals["USDT"][1], Amount: dec("110")}}},
+			conf:     sol,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := plan([]string{"USDT"}, venues, tc.balances, tc.inflight, &tc.conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("plan() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// fakeWallet is an exchange holding only balances, for Rebalance.
+type fakeWallet struct {
+	id        model.VenueID
+	fees      model.Fees
+	balances  model.Balances
+	withdrawn []decimal.Decimal
+}
+
+func (f *fakeWallet) Venue() model.Venue {
+	return model.Venue{ID: f.id, Fees: f.fees}
+}
+func (f *fakeWallet) Book(p model.Pair) ([]model.Order, []model.Order, error) {
+	return nil, nil, errors.ErrUnsupported
+}
+func (f *fakeWallet) Balances() (model.Balances, error) {
+	b := model.Balances{}
+	for cur, amt := range f.balances {
+		b[cur] = amt
+	}
+	return b, nil
+}
+func (f *fakeWallet) Buy(p model.Pair, price, size decimal.Decimal) (string, error) {
+	return "", errors.ErrUnsupported
+}
+func (f *fakeWallet) Sell(p model.Pair, price, size decimal.Decimal) (string, error) {
+	return "", errors.ErrUnsupported
+}
+func (f *fakeWallet) GetOrder(p model.Pair, id string) (model.OrderStatus, error) {
+	return model.OrderStatus{}, errors.ErrUnsupported
+}
+func (f *fakeWallet) Cancel(p model.Pair, id string) error { return errors.ErrUnsupported }
+func (f *fakeWallet) Withdraw(w model.Withdrawal, currency, address string, amount decimal.Decimal) (string, error) {
+	f.withdrawn = append(f.withdrawn, amount)
+	f.balances[currency] = f.balances[currency].Sub(amount)
+	return "w1", nil
+}
+
+// TestRebalance uses the exchange registry and the shared wallet, so it does
+// not run in parallel.
+func TestRebalance(t *testing.T) {
+	from := &fakeWallet{
+		id:       "Rb1",
+		fees:     model.Fees{Withdrawals: map[string][]model.Withdrawal{"USDT": {{Network: "SOL", Fee: dec("1")}}}},
+		balances: model.Balances{"USDT": dec("100")},
+	}
+	to := &fakeWallet{id: "Rb2", balances: model.Balances{"USDT": dec("0")}}
+	exchange.Register(from, to)
+	conf := &config.Config{
+		RebalanceDryRun:   true,
+		RebalanceBelow:    0.5,
+		RebalanceTimeout:  time.Hour,
+		WithdrawAddresses: []string{"Rb2/USDT/SOL=addr"},
+	}
+
+	msgs, err := Rebalance([]string{"USDT"}, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || !strings.HasPrefix(msgs[0], "(dry run)") {
+		t.Errorf("dry run messages = %q", msgs)
+	}
+	if len(from.withdrawn) != 0 || len(inflight) != 0 {
+		t.Fatalf("dry run withdrew %v, in flight %v", from.withdrawn, inflight)
+	}
+
+	conf.RebalanceDryRun = false
+	if _, err := Rebalance([]string{"USDT"}, conf); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]decimal.Decimal{dec("50")}, from.withdrawn); diff != "" {
+		t.Errorf("withdrawn mismatch (-want +got):\n%s", diff)
+	}
+
+	// the deposit has not arrived; the top-up must not be sent again
+	if _, err := Rebalance([]string{"USDT"}, conf); err != nil {
+		t.Fatal(err)
+	}
+	if len(from.withdrawn) != 1 {
+		t.Errorf("withdrew again while in flight: %v", from.withdrawn)
+	}
+
+	// once it arrives it is no longer in flight
+	to.balances["USDT"] = dec("49")
+	funds.mu.Lock()
+	if err := funds.refresh(); err != nil {
+		t.Fatal(err)
+	}
+	arrived(funds.snapshot(), time.Now(), conf.RebalanceTimeout)
+	n := len(inflight)
+	funds.mu.Unlock()
+	if n != 0 {
+		t.Errorf("%v transfers still in flight after arrival", n)
+	}
+}
@@ -0,0 +1,174 @@
+// Package record keeps a history of the merged books the engine saw as
+// gzipped JSON lines, one record per line, in files that rotate on a fixed
+// period.
+package record
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/L3Sota/arbo/arb/model"
+)
+
+// Record is one merged book. Each order carries its venue, raw price and
+// amount, and its effective price after fees.
+type Record struct {
+	Time time.Time
+	Pair model.Pair
+	Asks []model.Order
+	Bids []model.Order
+}
+
+// Recorder appends records to files in a directory, starting a new file
+// every period. Each record is flushed to the file as it is written, so a
+// crash loses at most the record being written. It is safe for concurrent
+// use.
+type Recorder struct {
+	dir    string
+	period time.Duration
+
+	mu    sync.Mutex
+	start time.Time // of the current file's period
+	f     *os.File
+	gz    *gzip.Writer
+}
+
+// New returns a recorder writing to dir, which is created if needed. A zero
+// period never rotates.
+func New(dir string, period time.Duration) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Recorder{dir: dir, period: period}, nil
+}
+
+// Write appends a record of the books of p.
+func (r *Recorder) Write(p model.Pair, asks, bids []model.Order) error {
+	return r.write(Record{Time: time.Now().UTC(), Pair: p, Asks: asks, Bids: bids})
+}
+
+func (r *Recorder) write(rec Record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.rotate(rec.Time); err != nil {
+		return err
+	}
+	if _, err := r.gz.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return r.gz.Flush()
+}
+
+// rotate opens the file for now's period if it is not already open. Reopening
+// a file appends a new gzip member, which readers handle transparently. The
+// caller must hold r.mu.
+func (r *Recorder) rotate(now time.Time) error {
+	start := r.start
+	if r.period > 0 {
+		start = now.Truncate(r.period)
+	} else if r.f == nil {
+		start = now
+	}
+	if r.f != nil && start.Equal(r.start) {
+		return nil
+	}
+	if err := r.close(); err != nil {
+		return err
+	}
+
+	name := filepath.Join(r.dir, "books-"+start.Format("20060102T150405Z")+".jsonl.gz")
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	r.start = start
+	r.f = f
+	r.gz = gzip.NewWriter(f)
+	return nil
+}
+
+// Close closes the current file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.close()
+}
+
+func (r *Recorder) close() error {
+	if r.f == nil {
+		return nil
+	}
+	f := r.f
+	r.f = nil
+	if err := r.gz.Close(); err != nil {
+		f.Close()
+		return fmt.Errorf("close %v: %w", f.Name(), err)
+	}
+	return f.Close()
+}
+
+// Scan calls fn with every record in dir, oldest file first, stopping at the
+// first error.
+func Scan(dir string, fn func(Record) error) error {
+	names, err := filepath.Glob(filepath.Join(dir, "books-*.jsonl.gz"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := scanFile(name, fn); err != nil {
+			return fmt.Errorf("%v: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func scanFile(name string, fn func(Record) error) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	d := json.NewDecoder(gz)
+	for {
+		var r Record
+		err := d.Decode(&r)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		// a file still being written, or cut short by a crash, ends
+		// without the gzip trailer or mid-record
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package record
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/google/go-cmp/cmp"
+	"github.com/shopspring/decimal"
+)
+
+func testRecord(t time.Time, price int64) Record {
+	o := model.Order{Venue: "Ku", Price: decimal.NewFromInt(price), EffectivePrice: decimal.NewFromInt(price), Amount: decimal.NewFromInt(1)}
+	return Record{Time: t, Pair: model.XCHUSDT, Asks: []model.Order{o}, Bids: []model.Order{o}}
+}
+
+func scanAll(t *testing.T, dir string) []Record {
+	t.Helper()
+
+	var got []Record
+	if err := Scan(dir, func(r Record) error {
+		got = append(got, r)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func files(t *testing.T, dir string) []string {
+	t.Helper()
+
+	names, err := filepath.Glob(filepath.Join(dir, "books-*.jsonl.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, n := range names {
+		names[i] = filepath.Base(n)
+	}
+	return names
+}
+
+func TestRotate(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	want := []Record{
+		testRecord(start.Add(10*time.Minute), 30),
+		testRecord(start.Add(50*time.Minute), 31),
+		testRecord(start.Add(70*time.Minute), 32),
+	}
+
+	r, err := New(dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range want[:2] {
+		if err := r.write(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a restart appends to the period's file, then rotates
+	r, err = New(dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extra := testRecord(start.Add(55*time.Minute), 33)
+	for _, rec := range []Record{extra, want[2]} {
+		if err := r.write(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want = []Record{want[0], want[1], extra, want[2]}
+
+	wantFiles := []string{"books-20240101T100000Z.jsonl.gz", "books-20240101T110000Z.jsonl.gz"}
+	if diff := cmp.Diff(wantFiles, files(t, dir)); diff != "" {
+		t.Errorf("files mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(want, scanAll(t, dir)); diff != "" {
+		t.Errorf("records mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestNoRotate(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	r, err := New(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	want := []Record{testRecord(start, 30), testRecord(start.Add(48*time.Hour), 31)}
+	for _, rec := range want {
+		if err := r.write(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := files(t, dir); len(got) != 1 {
+		t.Errorf("files = %v, want one", got)
+	}
+	if diff := cmp.Diff(want, scanAll(t, dir)); diff != "" {
+		t.Errorf("records mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWriteFlushes(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	r, err := New(dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if err := r.Write(model.XCHUSDT, testRecord(time.Time{}, 30).Asks, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// readable without closing the file
+	got := scanAll(t, dir)
+	if len(got) != 1 || got[0].Pair != model.XCHUSDT || len(got[0].Asks) != 1 {
+		t.Errorf("records = %+v, want the one written", got)
+	}
+}
+
+func TestScanTruncated(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	r, err := New(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	want := testRecord(start, 30)
+	if err := r.write(want); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(dir, files(t, dir)[0])
+	fi, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := fi.Size()
+	if err := r.write(testRecord(start.Add(time.Second), 31)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a crash mid-way through the second record
+	fi, err = os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(name, (first+fi.Size())/2); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]Record{want}, scanAll(t, dir)); diff != "" {
+		t.Errorf("records mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,29 @@
+package arb
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/L3Sota/arbo/arb/record"
+)
+
+var recorder atomic.Pointer[record.Recorder]
+
+// Record makes GatherBooksP append every merged book to r. A nil r stops
+// recording.
+func Record(r *record.Recorder) {
+	recorder.Store(r)
+}
+
+// recordBooks writes the books of p to the recorder, if any. Failing to record
+// does not stop trading.
+func recordBooks(p model.Pair, a, b []model.Order) {
+	r := recorder.Load()
+	if r == nil {
+		return
+	}
+	if err := r.Write(p, a, b); err != nil {
+		fmt.Printf("%v record error: %v\n", p, err)
+	}
+}
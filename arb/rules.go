@@ -0,0 +1,36 @@
+package arb
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/L3Sota/arbo/arb/exchange"
+	"github.com/L3Sota/arbo/arb/model"
+)
+
+// RefreshRules fetches the order rules of each pair from each venue that can
+// report them. Venues keep their configured rules for pairs that fail.
+func RefreshRules(pairs []model.Pair) {
+	for _, e := range exchange.All() {
+		q, ok := e.(exchange.RulesQuerier)
+		if !ok {
+			continue
+		}
+		v := e.Venue()
+
+		for _, p := range pairs {
+			r, err := q.QueryRules(p)
+			if errors.Is(err, errors.ErrUnsupported) {
+				break
+			}
+			if err != nil {
+				fmt.Printf("%v %v rules error: %v\n", v.ID, p, err)
+				continue
+			}
+			if c, ok := v.Rules[p]; ok && !c.Equal(r) {
+				fmt.Printf("%v %v rules are %+v, configured %+v\n", v.ID, p, r, c)
+			}
+			q.SetRules(p, r)
+		}
+	}
+}
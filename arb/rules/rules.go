@@ -0,0 +1,49 @@
+package rules
+
+import (
+	"sync"
+
+	"github.com/L3Sota/arbo/arb/model"
+)
+
+// Table is the order rules of one venue by pair. It starts from the
+// configured rules and is updated as the live ones are fetched. It is safe for
+// concurrent use.
+type Table struct {
+	mu    sync.RWMutex
+	rules map[model.Pair]model.Rules
+}
+
+func New(rules map[model.Pair]model.Rules) *Table {
+	t := &Table{rules: make(map[model.Pair]model.Rules, len(rules))}
+	for p, r := range rules {
+		t.rules[p] = r
+	}
+	return t
+}
+
+func (t *Table) Get(p model.Pair) model.Rules {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.rules[p]
+}
+
+// All returns a copy of the table.
+func (t *Table) All() map[model.Pair]model.Rules {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	m := make(map[model.Pair]model.Rules, len(t.rules))
+	for p, r := range t.rules {
+		m[p] = r
+	}
+	return m
+}
+
+func (t *Table) Set(p model.Pair, r model.Rules) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.rules[p] = r
+}
@@ -0,0 +1,91 @@
+package arb
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/L3Sota/arbo/arb/exchange"
+	"github.com/L3Sota/arbo/arb/model"
+)
+
+var settlePoll = 500 * time.Millisecond
+
+// settle polls the placed orders until none is active or timeout passes, then
+// cancels the ones still resting and returns the venues it cancelled on. A
+// zero timeout reads each status once and leaves the orders resting.
+func settle(p model.Pair, placed map[model.VenueID]string, timeout time.Duration) (map[model.VenueID]*model.OrderStatus, []model.VenueID) {
+	orders := make(map[model.VenueID]*model.OrderStatus, len(placed))
+	deadline := time.Now().Add(timeout)
+	for {
+		if !poll(p, placed, orders) || timeout <= 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(settlePoll)
+	}
+	if timeout <= 0 {
+		return orders, nil
+	}
+	return orders, cancelActive(p, placed, orders, fmt.Sprint("after ", timeout))
+}
+
+// abort reads the status of the placed orders once and cancels the ones still
+// resting right away, whatever the configured timeout, for when another leg
+// of the trade failed.
+func abort(p model.Pair, placed map[model.VenueID]string) (map[model.VenueID]*model.OrderStatus, []model.VenueID) {
+	orders := make(map[model.VenueID]*model.OrderStatus, len(placed))
+	poll(p, placed, orders)
+	return orders, cancelActive(p, placed, orders, "as another leg failed")
+}
+
+// cancelActive cancels the placed orders not known to be done, records their
+// final status in orders and returns the venues it cancelled on.
+func cancelActive(p model.Pair, placed map[model.VenueID]string, orders map[model.VenueID]*model.OrderStatus, why string) []model.VenueID {
+	var cancelled []model.VenueID
+	for v, id := range placed {
+		if o := orders[v]; o != nil && !o.Active {
+			continue
+		}
+		e, ok := exchange.Get(v)
+		if !ok {
+			continue
+		}
+		if err := e.Cancel(p, id); err != nil {
+			fmt.Printf("%v Cancel(%v) error: %v\n", v, id, err)
+			continue
+		}
+		fmt.Printf("%v: cancelled %v %v\n", v, id, why)
+		cancelled = append(cancelled, v)
+
+		o, err := e.GetOrder(p, id)
+		if err != nil {
+			fmt.Printf("%v GetOrder(%v) error: %v\n", v, id, err)
+			continue
+		}
+		orders[v] = &o
+	}
+	return cancelled
+}
+
+// poll refreshes the status of the orders that are not known to be done and
+// reports whether any may still be active.
+func poll(p model.Pair, placed map[model.VenueID]string, orders map[model.VenueID]*model.OrderStatus) bool {
+	active := false
+	for v, id := range placed {
+		if o := orders[v]; o != nil && !o.Active {
+			continue
+		}
+		e, ok := exchange.Get(v)
+		if !ok {
+			continue
+		}
+		o, err := e.GetOrder(p, id)
+		if err != nil {
+			fmt.Printf("%v GetOrder(%v) error: %v\n", v, id, err)
+			active = true
+			continue
+		}
+		orders[v] = &o
+		active = active || o.Active
+	}
+	return active
+}
@@ -0,0 +1,84 @@
+package arb
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/L3Sota/arbo/arb/exchange"
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/google/go-cmp/cmp"
+	"github.com/shopspring/decimal"
+)
+
+// fakeLeg is an exchange whose orders rest until cancelled, or that rejects
+// them.
+type fakeLeg struct {
+	id        model.VenueID
+	reject    bool
+	cancelled []string
+}
+
+func (f *fakeLeg) Venue() model.Venue {
+	return model.Venue{ID: f.id}
+}
+func (f *fakeLeg) Book(p model.Pair) ([]model.Order, []model.Order, error) {
+	return nil, nil, errors.ErrUnsupported
+}
+
+// Balances is unsupported so that the venue holds no funds in the shared
+// wallet.
+func (f *fakeLeg) Balances() (model.Balances, error) {
+	return nil, errors.ErrUnsupported
+}
+func (f *fakeLeg) Buy(p model.Pair, price, size decimal.Decimal) (string, error) {
+	return f.place()
+}
+func (f *fakeLeg) Sell(p model.Pair, price, size decimal.Decimal) (string, error) {
+	return f.place()
+}
+func (f *fakeLeg) place() (string, error) {
+	if f.reject {
+		return "", errors.New("rejected")
+	}
+	return "1", nil
+}
+func (f *fakeLeg) GetOrder(p model.Pair, id string) (model.OrderStatus, error) {
+	return model.OrderStatus{ID: id, Active: len(f.cancelled) == 0}, nil
+}
+func (f *fakeLeg) Cancel(p model.Pair, id string) error {
+	f.cancelled = append(f.cancelled, id)
+	return nil
+}
+
+// TestAbortFailedLeg uses the exchange registry, so it does not run in
+// parallel.
+func TestAbortFailedLeg(t *testing.T) {
+	p := model.XCHUSDT
+	buy := &fakeLeg{id: "Ab1"}
+	sell := &fakeLeg{id: "Ab2", reject: true}
+	exchange.Register(buy, sell)
+	venues := map[model.VenueID]model.Venue{"Ab1": buy.Venue(), "Ab2": sell.Venue()}
+
+	one := decimal.NewFromInt(1)
+	ids, err := trade(p, venues,
+		map[model.VenueID]decimal.Decimal{"Ab1": dec("10")}, map[model.VenueID]decimal.Decimal{"Ab2": dec("11")},
+		map[model.VenueID]decimal.Decimal{"Ab1": one}, map[model.VenueID]decimal.Decimal{"Ab2": one},
+		map[model.VenueID]decimal.Decimal{"Ab1": dec("10")}, map[model.VenueID]decimal.Decimal{"Ab2": dec("11")})
+	if err == nil {
+		t.Fatal("trade succeeded with a rejected leg")
+	}
+	if diff := cmp.Diff(map[model.VenueID]string{"Ab1": "1"}, ids); diff != "" {
+		t.Fatalf("placed mismatch (-want +got):\n%s", diff)
+	}
+
+	orders, cancelled := abort(p, ids)
+	if diff := cmp.Diff([]model.VenueID{"Ab1"}, cancelled); diff != "" {
+		t.Errorf("cancelled mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"1"}, buy.cancelled); diff != "" {
+		t.Errorf("Cancel calls mismatch (-want +got):\n%s", diff)
+	}
+	if o := orders["Ab1"]; o == nil || o.Active {
+		t.Errorf("Ab1 status after abort = %+v, want inactive", o)
+	}
+}
@@ -0,0 +1,421 @@
+// Package sim is a paper-trading venue. It reads books from a real venue, or
+// any other exchange.Exchange, and fills orders against them while keeping
+// virtual balances, so the engine can run end to end without risking
+// capital.
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/L3Sota/arbo/arb/exchange"
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/shopspring/decimal"
+)
+
+// Exchange simulates trading on the venue it wraps. An order is matched
+// against the wrapped venue's book Latency after it is placed, taking at most
+// Fill of the liquidity on the levels it crosses. Any remainder rests and is
+// matched again whenever the wrapped venue streams a change to its best
+// prices, and at least every rematchEvery. Fills pay the venue's taker fee in the quote
+// currency.
+//
+// The liquidity simulated orders take is tracked per level until the wrapped
+// book changes, so two orders cannot fill against the same levels. An order
+// whose book cannot be fetched bookRetries times in a row fails.
+type Exchange struct {
+	e       exchange.Exchange
+	latency time.Duration
+	fill    decimal.Decimal
+
+	mu       sync.Mutex
+	balances model.Balances // available, less what open orders hold
+	orders   map[string]*order
+	next     int
+	books    map[model.Pair]*book
+	resting  map[model.Pair]bool // rematching the resting orders of the pair
+	updates  map[model.Pair]<-chan struct{}
+}
+
+const (
+	// rematchEvery is how often resting orders are matched again when the
+	// wrapped venue does not stream a change sooner.
+	rematchEvery = time.Second
+	// bookRetries is how many book fetches in a row may fail before the
+	// order fails.
+	bookRetries = 3
+)
+
+// book is the last book of a pair matched against and the liquidity taken
+// from it, by side and price.
+type book struct {
+	asks, bids []model.Order
+	taken      map[level]decimal.Decimal
+}
+
+type level struct {
+	buy   bool
+	price string
+}
+
+type order struct {
+	status model.OrderStatus
+	pair   model.Pair
+	buy    bool
+	price  decimal.Decimal
+	size   decimal.Decimal
+	held   decimal.Decimal // quote for buys, base for sells
+	timer  *time.Timer
+	// matched is set once the order has been matched after its latency;
+	// from then on it is rematched while it rests.
+	matched bool
+	errors  int // book fetches failed in a row
+}
+
+// New returns a simulator of e starting with balances. A fill of zero or
+// above one is treated as one.
+func New(e exchange.Exchange, balances model.Balances, latency time.Duration, fill decimal.Decimal) exchange.Exchange {
+	if !fill.IsPositive() || fill.GreaterThan(decimal.NewFromInt(1)) {
+		fill = decimal.NewFromInt(1)
+	}
+	b := make(model.Balances, len(balances))
+	for cur, amt := range balances {
+		b[cur] = amt
+	}
+	x := &Exchange{
+		e:        e,
+		latency:  latency,
+		fill:     fill,
+		balances: b,
+		orders:   map[string]*order{},
+		books:    map[model.Pair]*book{},
+		resting:  map[model.Pair]bool{},
+		updates:  map[model.Pair]<-chan struct{}{},
+	}
+	if s, ok := e.(exchange.Streamer); ok {
+		return &streaming{Exchange: x, s: s}
+	}
+	return x
+}
+
+// streaming is a simulator of a venue that streams its books.
+type streaming struct {
+	*Exchange
+	s exchange.Streamer
+}
+
+func (x *streaming) Watch(p model.Pair) <-chan struct{} {
+	return x.s.Watch(p)
+}
+
+func (x *streaming) Age(p model.Pair) time.Duration {
+	return x.s.Age(p)
+}
+
+func (x *streaming) Streaming(p model.Pair) bool {
+	return x.s.Streaming(p)
+}
+
+func (x *Exchange) Venue() model.Venue {
+	return x.e.Venue()
+}
+
+func (x *Exchange) Book(p model.Pair) ([]model.Order, []model.Order, error) {
+	return x.e.Book(p)
+}
+
+func (x *Exchange) Balances() (model.Balances, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	b := make(model.Balances, len(x.balances))
+	for cur, amt := range x.balances {
+		b[cur] = amt
+	}
+	return b, nil
+}
+
+func (x *Exchange) Buy(p model.Pair, price, size decimal.Decimal) (string, error) {
+	return x.place(p, true, price, size)
+}
+
+func (x *Exchange) Sell(p model.Pair, price, size decimal.Decimal) (string, error) {
+	return x.place(p, false, price, size)
+}
+
+// place holds the funds the order may spend and schedules its match.
+func (x *Exchange) place(p model.Pair, buy bool, price, size decimal.Decimal) (string, error) {
+	v := x.e.Venue()
+	r := v.Rules[p]
+	size = r.RoundSize(size)
+	price = r.RoundPrice(price, buy)
+	if !size.IsPositive() || !price.IsPositive() {
+		return "", fmt.Errorf("invalid order %v @ %v", size, price)
+	}
+
+	cur, held := p.Base, size
+	if buy {
+		cur, held = p.Quote, size.Mul(price).Mul(v.FeesOf(p).AskAddition(false))
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.balances[cur].LessThan(held) {
+		return "", fmt.Errorf("insufficient %v: have %v, need %v", cur, x.balances[cur], held)
+	}
+	x.balances[cur] = x.balances[cur].Sub(held)
+
+	x.next++
+	id := "sim-" + strconv.Itoa(x.next)
+	o := &order{
+		status: model.OrderStatus{
+			ID:          id,
+			FeeCurrency: p.Quote,
+			Active:      true,
+			State:       "new",
+		},
+		pair:  p,
+		buy:   buy,
+		price: price,
+		size:  size,
+		held:  held,
+	}
+	x.orders[id] = o
+	o.timer = time.AfterFunc(x.latency, func() { x.match(id) })
+
+	return id, nil
+}
+
+// match fills what is left of the order against the wrapped venue's current
+// book and leaves any remainder resting.
+func (x *Exchange) match(id string) {
+	x.mu.Lock()
+	o, ok := x.orders[id]
+	if !ok || !o.status.Active {
+		x.mu.Unlock()
+		return
+	}
+	p := o.pair
+	x.mu.Unlock()
+
+	asks, bids, err := x.e.Book(p)
+	taker := x.e.Venue().FeesOf(p).Taker
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	// cancelled while the book was fetched
+	if !o.status.Active {
+		return
+	}
+	o.matched = true
+
+	if err != nil {
+		o.errors++
+		fmt.Printf("%v sim %v book error (%v of %v): %v\n", x.e.Venue().ID, id, o.errors, bookRetries, err)
+		if o.errors >= bookRetries {
+			o.status.Active = false
+			o.status.State = "failed"
+			x.release(o)
+			return
+		}
+		x.rest(p)
+		return
+	}
+	o.errors = 0
+
+	b := x.book(p, asks, bids)
+	side := bids
+	if o.buy {
+		side = asks
+	}
+	left := o.size.Sub(o.status.Filled)
+	filled, funds := decimal.Zero, decimal.Zero
+	for _, l := range side {
+		if o.buy && l.Price.GreaterThan(o.price) || !o.buy && l.Price.LessThan(o.price) {
+			break
+		}
+		k := level{buy: o.buy, price: l.Price.String()}
+		amt := decimal.Min(l.Amount.Mul(x.fill).Sub(b.taken[k]), left.Sub(filled))
+		if !amt.IsPositive() {
+			continue
+		}
+		b.taken[k] = b.taken[k].Add(amt)
+		filled = filled.Add(amt)
+		funds = funds.Add(amt.Mul(l.Price))
+		if filled.Equal(left) {
+			break
+		}
+	}
+	fee := funds.Mul(taker)
+
+	if o.buy {
+		x.balances[p.Base] = x.balances[p.Base].Add(filled)
+		o.held = o.held.Sub(funds).Sub(fee)
+	} else {
+		x.balances[p.Quote] = x.balances[p.Quote].Add(funds).Sub(fee)
+		o.held = o.held.Sub(filled)
+	}
+
+	o.status.Filled = o.status.Filled.Add(filled)
+	o.status.FilledFunds = o.status.FilledFunds.Add(funds)
+	o.status.Fee = o.status.Fee.Add(fee)
+	switch {
+	case o.status.Filled.Equal(o.size):
+		o.status.Active = false
+		o.status.State = "filled"
+		x.release(o)
+		return
+	case o.status.Filled.IsPositive():
+		o.status.State = "partially filled"
+	default:
+		o.status.State = "open"
+	}
+	x.rest(p)
+}
+
+// book returns the record of the liquidity taken from the book of p, started
+// afresh if the book has changed since it was last matched against. The
+// caller must hold x.mu.
+func (x *Exchange) book(p model.Pair, asks, bids []model.Order) *book {
+	b := x.books[p]
+	if b == nil || !same(b.asks, asks) || !same(b.bids, bids) {
+		b = &book{asks: asks, bids: bids, taken: map[level]decimal.Decimal{}}
+		x.books[p] = b
+	}
+	return b
+}
+
+// same reports whether two sides of a book hold the same levels.
+func same(a, b []model.Order) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Price.Equal(b[i].Price) || !a[i].Amount.Equal(b[i].Amount) {
+			return false
+		}
+	}
+	return true
+}
+
+// rest starts rematching the resting orders of p, unless it is already
+// running. The caller must hold x.mu.
+func (x *Exchange) rest(p model.Pair) {
+	if x.resting[p] {
+		return
+	}
+	x.resting[p] = true
+
+	updates, ok := x.updates[p]
+	if s, streams := x.e.(exchange.Streamer); streams && !ok {
+		updates = s.Watch(p)
+		x.updates[p] = updates
+	}
+	go x.rematch(p, updates)
+}
+
+// rematch matches the resting orders of p again on every streamed change to
+// the best prices, or every rematchEvery, until none is left.
+func (x *Exchange) rematch(p model.Pair, updates <-chan struct{}) {
+	t := time.NewTicker(rematchEvery)
+	defer t.Stop()
+	for {
+		select {
+		case <-updates:
+		case <-t.C:
+		}
+
+		x.mu.Lock()
+		var ids []string
+		for id, o := range x.orders {
+			if o.pair == p && o.status.Active && o.matched {
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == 0 {
+			x.resting[p] = false
+			x.mu.Unlock()
+			return
+		}
+		x.mu.Unlock()
+
+		for _, id := range ids {
+			x.match(id)
+		}
+	}
+}
+
+// release returns what the order still holds to the balances. The caller
+// must hold x.mu.
+func (x *Exchange) release(o *order) {
+	cur := o.pair.Base
+	if o.buy {
+		cur = o.pair.Quote
+	}
+	x.balances[cur] = x.balances[cur].Add(o.held)
+	o.held = decimal.Zero
+}
+
+func (x *Exchange) GetOrder(p model.Pair, id string) (model.OrderStatus, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	o, ok := x.orders[id]
+	if !ok {
+		return model.OrderStatus{}, fmt.Errorf("order %v not found", id)
+	}
+	return o.status, nil
+}
+
+func (x *Exchange) Cancel(p model.Pair, id string) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	o, ok := x.orders[id]
+	if !ok {
+		return fmt.Errorf("order %v not found", id)
+	}
+	if !o.status.Active {
+		return nil
+	}
+	o.timer.Stop()
+	o.status.Active = false
+	o.status.State = "cancelled"
+	x.release(o)
+	return nil
+}
+
+// QueryFee reports the wrapped venue's fees, if it can.
+func (x *Exchange) QueryFee(p model.Pair) (maker, taker decimal.Decimal, err error) {
+	q, ok := x.e.(exchange.FeeQuerier)
+	if !ok {
+		return decimal.Zero, decimal.Zero, errors.ErrUnsupported
+	}
+	return q.QueryFee(p)
+}
+
+func (x *Exchange) SetFees(p model.Pair, maker, taker decimal.Decimal) {
+	if q, ok := x.e.(exchange.FeeQuerier); ok {
+		q.SetFees(p, maker, taker)
+	}
+}
+
+// QueryRules reports the wrapped venue's rules, if it can.
+func (x *Exchange) QueryRules(p model.Pair) (model.Rules, error) {
+	q, ok := x.e.(exchange.RulesQuerier)
+	if !ok {
+		return model.Rules{}, errors.ErrUnsupported
+	}
+	return q.QueryRules(p)
+}
+
+func (x *Exchange) SetRules(p model.Pair, r model.Rules) {
+	if q, ok := x.e.(exchange.RulesQuerier); ok {
+		q.SetRules(p, r)
+	}
+}
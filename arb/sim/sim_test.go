@@ -0,0 +1,210 @@
+package sim
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/L3Sota/arbo/arb/exchange"
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/google/go-cmp/cmp"
+	"github.com/shopspring/decimal"
+)
+
+type fixed struct {
+	asks, bids []model.Order
+}
+
+func (f fixed) Venue() model.Venue {
+	return model.Venue{ID: "Fx", Fees: model.Fees{Taker: decimal.RequireFromString("0.001")}}
+}
+
+func (f fixed) Book(p model.Pair) ([]model.Order, []model.Order, error) {
+	return f.asks, f.bids, nil
+}
+
+func (f fixed) Balances() (model.Balances, error) { return nil, errors.ErrUnsupported }
+func (f fixed) Buy(p model.Pair, price, size decimal.Decimal) (string, error) {
+	return "", errors.ErrUnsupported
+}
+func (f fixed) Sell(p model.Pair, price, size decimal.Decimal) (string, error) {
+	return "", errors.ErrUnsupported
+}
+func (f fixed) GetOrder(p model.Pair, id string) (model.OrderStatus, error) {
+	return model.OrderStatus{}, errors.ErrUnsupported
+}
+func (f fixed) Cancel(p model.Pair, id string) error { return errors.ErrUnsupported }
+
+func d(s string) decimal.Decimal { return decimal.RequireFromString(s) }
+
+func TestPartialFillAndCancel(t *testing.T) {
+	t.Parallel()
+
+	f := fixed{
+		asks: []model.Order{
+			{Price: d("10"), Amount: d("1")},
+			{Price: d("11"), Amount: d("2")},
+			{Price: d("12"), Amount: d("5")},
+		},
+	}
+	x := New(f, model.Balances{"USDT": d("100")}, 0, d("0.5"))
+
+	id, err := x.Buy(model.XCHUSDT, d("11"), d("3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var o model.OrderStatus
+	for i := 0; i < 100; i++ {
+		if o, err = x.GetOrder(model.XCHUSDT, id); err != nil {
+			t.Fatal(err)
+		}
+		if o.State != "new" {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// half of the 10 and 11 levels
+	want := model.OrderStatus{
+		ID:          id,
+		Filled:      d("1.5"),
+		FilledFunds: d("16"),
+		Fee:         d("0.016"),
+		FeeCurrency: "USDT",
+		Active:      true,
+		State:       "partially filled",
+	}
+	if diff := cmp.Diff(want, o); diff != "" {
+		t.Errorf("GetOrder() mismatch (-want +got):\n%s", diff)
+	}
+
+	if err := x.Cancel(model.XCHUSDT, id); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := x.Balances()
+	wantB := model.Balances{"USDT": d("83.984"), "XCH": d("1.5")}
+	if diff := cmp.Diff(wantB, b); diff != "" {
+		t.Errorf("Balances() mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := x.Sell(model.XCHUSDT, d("9"), d("2")); err == nil {
+		t.Error("Sell() beyond balance succeeded")
+	}
+}
+
+// live is a streaming venue whose book the test replaces.
+type live struct {
+	fixed
+	mu      sync.Mutex
+	err     error
+	updates chan struct{}
+}
+
+func newLive(asks []model.Order) *live {
+	return &live{fixed: fixed{asks: asks}, updates: make(chan struct{}, 1)}
+}
+
+func (l *live) Book(p model.Pair) ([]model.Order, []model.Order, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.asks, l.bids, l.err
+}
+
+// set replaces the book, or fails it with err, and notifies the watcher.
+func (l *live) set(asks []model.Order, err error) {
+	l.mu.Lock()
+	l.asks, l.err = asks, err
+	l.mu.Unlock()
+	l.updates <- struct{}{}
+}
+
+func (l *live) Watch(p model.Pair) <-chan struct{} { return l.updates }
+func (l *live) Age(p model.Pair) time.Duration     { return 0 }
+func (l *live) Streaming(p model.Pair) bool        { return true }
+
+// await polls the order until done reports true.
+func await(t *testing.T, x exchange.Exchange, id string, done func(model.OrderStatus) bool) model.OrderStatus {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		o, err := x.GetOrder(model.XCHUSDT, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if done(o) {
+			return o
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("order %v timed out", id)
+	return model.OrderStatus{}
+}
+
+func TestRestingOrderRematched(t *testing.T) {
+	t.Parallel()
+
+	l := newLive([]model.Order{{Price: d("10"), Amount: d("1")}})
+	x := New(l, model.Balances{"USDT": d("100")}, 0, d("1"))
+
+	id, err := x.Buy(model.XCHUSDT, d("10"), d("3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	await(t, x, id, func(o model.OrderStatus) bool { return o.Filled.Equal(d("1")) })
+
+	// the ask is replenished
+	l.set([]model.Order{{Price: d("9"), Amount: d("5")}}, nil)
+	o := await(t, x, id, func(o model.OrderStatus) bool { return !o.Active })
+	if o.State != "filled" || !o.FilledFunds.Equal(d("28")) {
+		t.Errorf("GetOrder() = %v filled for %v, want filled for 28", o.State, o.FilledFunds)
+	}
+}
+
+func TestLiquidityTakenOnce(t *testing.T) {
+	t.Parallel()
+
+	f := fixed{asks: []model.Order{{Price: d("10"), Amount: d("1")}}}
+	x := New(f, model.Balances{"USDT": d("100")}, 0, d("1"))
+
+	var ids []string
+	for i := 0; i < 2; i++ {
+		id, err := x.Buy(model.XCHUSDT, d("10"), d("1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	filled := decimal.Zero
+	for _, id := range ids {
+		o := await(t, x, id, func(o model.OrderStatus) bool { return o.State != "new" })
+		filled = filled.Add(o.Filled)
+	}
+	if !filled.Equal(d("1")) {
+		t.Errorf("filled %v against a level of 1", filled)
+	}
+}
+
+func TestBookErrorFailsOrder(t *testing.T) {
+	t.Parallel()
+
+	l := newLive(nil)
+	x := New(l, model.Balances{"USDT": d("100")}, 0, d("1"))
+	l.err = errors.New("no book")
+
+	id, err := x.Buy(model.XCHUSDT, d("10"), d("3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < bookRetries; i++ {
+		l.set(nil, l.err)
+	}
+	o := await(t, x, id, func(o model.OrderStatus) bool { return !o.Active })
+	if o.State != "failed" {
+		t.Errorf("GetOrder() state = %v, want failed", o.State)
+	}
+	b, _ := x.Balances()
+	if !b["USDT"].Equal(d("100")) {
+		t.Errorf("USDT = %v, want the order's funds released", b["USDT"])
+	}
+}
@@ -0,0 +1,135 @@
+package arb
+
+import (
+	"sync"
+
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/shopspring/decimal"
+)
+
+// wallet is the balance view shared by every pair loop in the process. The
+// quote currency is usually common to all pairs, so orders are sized and
+// their funds reserved under the lock, before another loop can size against
+// the same funds. The lock is not held while orders are placed or settled.
+//
+// Reservations are kept apart from the fetched balances: a refresh by another
+// loop while orders are being placed would otherwise hand their funds out
+// again. They last until the orders are confirmed placed, when the funds are
+// deducted from the balances until the next refresh, or are refunded.
+type wallet struct {
+	mu       sync.Mutex
+	balances map[model.VenueID]model.Balances
+	reserved map[model.VenueID]model.Balances
+}
+
+var funds wallet
+
+// refresh replaces the balances with those fetched from the venues. The
+// caller must hold w.mu.
+func (w *wallet) refresh() error {
+	b, err := GatherBalancesP()
+	if err != nil {
+		return err
+	}
+	w.update(b)
+	return nil
+}
+
+// update replaces the balances, keeping the reservations. The caller must
+// hold w.mu.
+func (w *wallet) update(b map[model.VenueID]model.Balances) {
+	w.balances = b
+	observeBalances(w.snapshot())
+}
+
+// snapshot returns a copy of the balances less the reservations. The caller
+// must hold w.mu.
+func (w *wallet) snapshot() map[model.VenueID]model.Balances {
+	m := make(map[model.VenueID]model.Balances, len(w.balances))
+	for v, b := range w.balances {
+		if b == nil {
+			// the venue does not report balances
+			m[v] = nil
+			continue
+		}
+		c := make(model.Balances, len(b))
+		for cur, amt := range b {
+			c[cur] = amt
+		}
+		for cur, amt := range w.reserved[v] {
+			c[cur] = c[cur].Sub(amt)
+		}
+		m[v] = c
+	}
+	return m
+}
+
+// spend reserves the funds planned orders will commit: quote on the buying
+// venues and base on the selling venues. Proceeds are not credited until the
+// next refresh. The caller must hold w.mu.
+func (w *wallet) spend(p model.Pair, buyQuote, sellBase map[model.VenueID]decimal.Decimal) {
+	each(p, buyQuote, sellBase, w.hold)
+	observeBalances(w.snapshot())
+}
+
+// confirm releases the funds reserved for the orders of p: those of the
+// placed orders are deducted from the balances, the rest are refunded. The
+// caller must hold w.mu.
+func (w *wallet) confirm(p model.Pair, placed map[model.VenueID]string, buyQuote, sellBase map[model.VenueID]decimal.Decimal) {
+	each(p, buyQuote, sellBase, func(v model.VenueID, currency string, amount decimal.Decimal) {
+		if _, ok := placed[v]; ok {
+			w.commit(v, currency, amount)
+		} else {
+			w.release(v, currency, amount)
+		}
+	})
+	observeBalances(w.snapshot())
+}
+
+// each calls f with the quote of every buy and the base of every sell.
+func each(p model.Pair, buyQuote, sellBase map[model.VenueID]decimal.Decimal, f func(v model.VenueID, currency string, amount decimal.Decimal)) {
+	for v, q := range buyQuote {
+		if q.IsPositive() {
+			f(v, p.Quote, q)
+		}
+	}
+	for v, s := range sellBase {
+		if s.IsPositive() {
+			f(v, p.Base, s)
+		}
+	}
+}
+
+// hold reserves amount of currency on v. The caller must hold w.mu.
+func (w *wallet) hold(v model.VenueID, currency string, amount decimal.Decimal) {
+	if w.reserved == nil {
+		w.reserved = map[model.VenueID]model.Balances{}
+	}
+	r := w.reserved[v]
+	if r == nil {
+		r = model.Balances{}
+		w.reserved[v] = r
+	}
+	r[currency] = r[currency].Add(amount)
+}
+
+// release returns funds held on v. The caller must hold w.mu.
+func (w *wallet) release(v model.VenueID, currency string, amount decimal.Decimal) {
+	r := w.reserved[v]
+	if r == nil {
+		return
+	}
+	r[currency] = r[currency].Sub(amount)
+	if !r[currency].IsPositive() {
+		delete(r, currency)
+	}
+}
+
+// commit releases funds held on v that have left the balance, deducting them
+// until the next refresh. The caller must hold w.mu.
+func (w *wallet) commit(v model.VenueID, currency string, amount decimal.Decimal) {
+	w.release(v, currency, amount)
+	if b := w.balances[v]; b != nil {
+		b[currency] = b[currency].Sub(amount)
+	}
+}
@@ -0,0 +1,57 @@
+package arb
+
+import (
+	"testing"
+
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/google/go-cmp/cmp"
+	"github.com/shopspring/decimal"
+)
+
+func dec(s string) decimal.Decimal { return decimal.RequireFromString(s) }
+
+func TestWalletReservations(t *testing.T) {
+	t.Parallel()
+
+	p := model.XCHUSDT
+	other := model.Pair{Base: "ETH", Quote: "USDT"}
+	fetched := func(usdt string) map[model.VenueID]model.Balances {
+		return map[model.VenueID]model.Balances{"A": {"USDT": dec(usdt), "XCH": dec("1")}}
+	}
+	usdt := func(w *wallet) decimal.Decimal {
+		return w.snapshot()["A"]["USDT"]
+	}
+
+	var w wallet
+	w.update(fetched("100"))
+
+	// the first loop reserves 60 and places its order without the lock
+	w.spend(p, map[model.VenueID]decimal.Decimal{"A": dec("60")}, nil)
+
+	// the second loop refreshes before the venue holds the order's funds
+	w.update(fetched("100"))
+	if got := usdt(&w); !got.Equal(dec("40")) {
+		t.Fatalf("USDT after a refresh = %v, want the first loop's 60 still reserved", got)
+	}
+	w.spend(other, map[model.VenueID]decimal.Decimal{"A": dec("30")}, nil)
+
+	// the first order is placed; the second is not
+	w.confirm(p, map[model.VenueID]string{"A": "1"}, map[model.VenueID]decimal.Decimal{"A": dec("60")}, nil)
+	if got := usdt(&w); !got.Equal(dec("10")) {
+		t.Errorf("USDT after placing = %v, want 10", got)
+	}
+	w.confirm(other, nil, map[model.VenueID]decimal.Decimal{"A": dec("30")}, nil)
+	if got := usdt(&w); !got.Equal(dec("40")) {
+		t.Errorf("USDT after the refund = %v, want 40", got)
+	}
+
+	// the venue now holds the placed order's funds
+	w.update(fetched("40"))
+	want := map[model.VenueID]model.Balances{"A": {"USDT": dec("40"), "XCH": dec("1")}}
+	if diff := cmp.Diff(want, w.snapshot()); diff != "" {
+		t.Errorf("snapshot mismatch (-want +got):\n%s", diff)
+	}
+	if len(w.reserved["A"]) != 0 {
+		t.Errorf("reservations left: %v", w.reserved)
+	}
+}
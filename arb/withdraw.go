@@ -0,0 +1,49 @@
+package arb
+
+import (
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/shopspring/decimal"
+)
+
+// route returns the cheapest withdrawal of amount of currency from venue
+// from to any of the venues to. A route must be on a network the destination
+// accepts deposits on and must not be below the network's minimum. With no
+// destinations, any network will do.
+func route(venues map[model.VenueID]model.Venue, from model.VenueID, to []model.VenueID, currency string, amount decimal.Decimal) (model.Withdrawal, bool) {
+	var (
+		best  model.Withdrawal
+		found bool
+	)
+	for _, w := range venues[from].Fees.Withdrawals[currency] {
+		if amount.LessThan(w.Min) || !accepted(venues, to, currency, w.Network) {
+			continue
+		}
+		if !found || w.Fee.LessThan(best.Fee) {
+			best, found = w, true
+		}
+	}
+	return best, found
+}
+
+func accepted(venues map[model.VenueID]model.Venue, to []model.VenueID, currency, network string) bool {
+	if len(to) == 0 {
+		return true
+	}
+	for _, v := range to {
+		if venues[v].Accepts(currency, network) {
+			return true
+		}
+	}
+	return false
+}
+
+// counterparts returns the venues other than e with a positive total.
+func counterparts(e model.VenueID, totals map[model.VenueID]decimal.Decimal) []model.VenueID {
+	var vs []model.VenueID
+	for v, t := range totals {
+		if v != e && t.IsPositive() {
+			vs = append(vs, v)
+		}
+	}
+	return vs
+}
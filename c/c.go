@@ -3,25 +3,75 @@ package c
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
 
+	"github.com/L3Sota/arbo/arb/config"
+	"github.com/L3Sota/arbo/arb/depth"
+	"github.com/L3Sota/arbo/arb/fees"
 	"github.com/L3Sota/arbo/arb/model"
+	"github.com/L3Sota/arbo/arb/ratelimit"
+	"github.com/L3Sota/arbo/arb/rules"
 	"github.com/shopspring/decimal"
 	"gopkg.in/resty.v1"
 )
 
 var (
 	Fees = model.Fees{
-		MakerTakerRatio:    decimal.RequireFromString("0.003"), // 0.3%
-		WithdrawalFlatXCH:  decimal.RequireFromString("0.001"),
-		WithdrawalFlatUSDT: decimal.RequireFromString("1.4"), // TRC20
+		Maker: decimal.RequireFromString("0.003"), // 0.3%
+		Taker: decimal.RequireFromString("0.003"), // 0.3%
+		Withdrawals: map[string][]model.Withdrawal{
+			"XCH":  {{Network: "CHIA", Fee: decimal.RequireFromString("0.001"), OnTop: true}},
+			"USDT": {{Network: "TRC20", Fee: decimal.RequireFromString("1.4"), OnTop: true}},
+		},
+	}
+	Deposits = map[string][]string{
+		"XCH":  {"CHIA"},
+		"USDT": {"TRC20", "SOL"},
 	}
 
-	AskAddition  = decimal.NewFromInt(1).Add(Fees.MakerTakerRatio)
-	BidReduction = decimal.NewFromInt(1).Sub(Fees.MakerTakerRatio)
-
-	rest *resty.Client
+	Rules = map[model.Pair]model.Rules{
+		model.XCHUSDT: {
+			MinBase: decimal.RequireFromString("0.05"),
+		},
+	}
 )
 
+type Exchange struct {
+	id       model.VenueID
+	accessID string
+	secret   string
+	client   *http.Client
+	rest     *resty.Client
+	wsURL    string
+	limiter  *ratelimit.Limiter
+
+	smu   sync.Mutex
+	books map[model.Pair]*depth.Book
+
+	fees  *fees.Schedule
+	rules *rules.Table
+}
+
+func New(id model.VenueID, a config.Account) *Exchange {
+	l := ratelimit.New(Limits, ratelimit.Headers{})
+	return &Exchange{
+		id:       id,
+		accessID: a.Key,
+		secret:   a.Sec,
+		client: &http.Client{
+			Transport: &ratelimit.Transport{Limiter: l, Classify: classifyPrivate},
+		},
+		rest:    resty.New().SetTransport(&ratelimit.Transport{Limiter: l, Classify: classifyPublic}),
+		wsURL:   wsURL,
+		limiter: l,
+		books:   map[model.Pair]*depth.Book{},
+		fees:    fees.New(Fees),
+		rules:   rules.New(Rules),
+	}
+}
+
 type book struct {
 	Last string
 	Time int64
@@ -29,8 +79,24 @@ type book struct {
 	Bids [][]string
 }
 
-func Book() ([]model.Order, []model.Order, error) {
-	resp, err := rest.R().Get("https://api.coinex.com/v1/market/depth?market=XCHUSDT&merge=0.01&limit=50")
+// Symbol returns the CoinEx market for p, e.g. XCHUSDT.
+func Symbol(p model.Pair) string {
+	return p.Base + p.Quote
+}
+
+// Book serves the streamed book for p, falling back to REST until the stream
+// has a snapshot.
+func (e *Exchange) Book(p model.Pair) ([]model.Order, []model.Order, error) {
+	if s := e.stream(p); s.Ready() {
+		askAddition, bidReduction := e.fees.Ratios(p, false)
+		a, b := s.Orders(e.id, askAddition, bidReduction)
+		return a, b, nil
+	}
+	return e.restBook(p)
+}
+
+func (e *Exchange) restBook(p model.Pair) ([]model.Order, []model.Order, error) {
+	resp, err := e.rest.R().Get("https://api.coinex.com/v1/market/depth?market=" + Symbol(p) + "&merge=0&limit=50")
 	if err != nil {
 		return nil, nil, fmt.Errorf("rest err: %w; resp: %+v", err, resp)
 	}
@@ -49,9 +115,10 @@ func Book() ([]model.Order, []model.Order, error) {
 
 	o := raw.Data
 
+	askAddition, bidReduction := e.fees.Ratios(p, false)
 	a := make([]model.Order, 0, len(o.Asks))
 	for _, ask := range o.Asks {
-		p, err := decimal.NewFromString(ask[0])
+		price, err := decimal.NewFromString(ask[0])
 		if err != nil {
 			return nil, nil, fmt.Errorf("tried to parse %v, got err: %w\nraw resp: %v", ask[0], err, resp.String())
 		}
@@ -60,16 +127,16 @@ func Book() ([]model.Order, []model.Order, error) {
 			return nil, nil, fmt.Errorf("tried to parse %v, got err: %w\nraw resp: %v", ask[1], err, resp.String())
 		}
 		o := model.Order{
-			Ex:     model.ExchangeTypeCo,
-			Price:  p,
+			Venue:  e.id,
+			Price:  price,
 			Amount: amt,
 		}
-		o.EffectivePrice = o.Price.Mul(AskAddition)
+		o.EffectivePrice = o.Price.Mul(askAddition)
 		a = append(a, o)
 	}
 	b := make([]model.Order, 0, len(o.Bids))
 	for _, bid := range o.Bids {
-		p, err := decimal.NewFromString(bid[0])
+		price, err := decimal.NewFromString(bid[0])
 		if err != nil {
 			return nil, nil, fmt.Errorf("tried to parse %v, got err: %w\nraw resp: %v", bid[0], err, resp.String())
 		}
@@ -78,20 +145,20 @@ func Book() ([]model.Order, []model.Order, error) {
 			return nil, nil, fmt.Errorf("tried to parse %v, got err: %w\nraw resp: %v", bid[1], err, resp.String())
 		}
 		o := model.Order{
-			Ex:     model.ExchangeTypeCo,
-			Price:  p,
+			Venue:  e.id,
+			Price:  price,
 			Amount: amt,
 		}
-		o.EffectivePrice = o.Price.Mul(BidReduction)
+		o.EffectivePrice = o.Price.Mul(bidReduction)
 		b = append(b, o)
 	}
 
 	return a, b, nil
 }
 
-func Balances() (b model.Balances, err error) {
+func (e *Exchange) Balances() (b model.Balances, err error) {
 	//Inquire account asset constructure
-	accooutRespBody, err := GetAccount()
+	accooutRespBody, err := e.GetAccount()
 	if err != nil {
 		return b, err
 	}
@@ -102,66 +169,133 @@ func Balances() (b model.Balances, err error) {
 		return b, fmt.Errorf("[Error %d] %v", a.Code, a.Message)
 	}
 
+	b = make(model.Balances, len(a.AssetBalance))
 	for currency, aa := range a.AssetBalance {
-		switch currency {
-		case "USDT":
-			usdt, err := decimal.NewFromString(aa.Available)
-			if err != nil {
-				return b, fmt.Errorf("failed to parse %v into decimal: %w", aa.Available, err)
-			}
-			b.USDT = usdt
-		case "XCH":
-			xch, err := decimal.NewFromString(aa.Available)
-			if err != nil {
-				return b, fmt.Errorf("failed to parse %v into decimal: %w", aa.Available, err)
-			}
-			b.XCH = xch
+		avail, err := decimal.NewFromString(aa.Available)
+		if err != nil {
+			return b, fmt.Errorf("failed to parse %v into decimal: %w", aa.Available, err)
 		}
+		b[currency] = avail
 	}
 
 	return b, nil
 }
 
-func Buy(price, size decimal.Decimal) (*OrderResp, error) {
+func (e *Exchange) Buy(p model.Pair, price, size decimal.Decimal) (string, error) {
+	r := e.rules.Get(p)
 	//put limit order
-	limitOrderRespBody, err := PutLimitOrder(
-		size.RoundDown(4).String(),
-		price.String(),
+	limitOrderRespBody, err := e.PutLimitOrder(
+		r.RoundSize(size).String(),
+		r.RoundPrice(price, true).String(),
 		"buy",
-		"XCHUSDT")
+		Symbol(p))
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	var putLimitOrderResp OrderResp
 	if err := json.Unmarshal(limitOrderRespBody, &putLimitOrderResp); err != nil {
-		return nil, err
+		return "", err
+	}
+	if putLimitOrderResp.Code != 0 {
+		return "", fmt.Errorf("[Error %d] %v", putLimitOrderResp.Code, putLimitOrderResp.Message)
 	}
-	return &putLimitOrderResp, nil
+	return strconv.FormatInt(putLimitOrderResp.Order.ID, 10), nil
 }
 
-func Sell(price, size decimal.Decimal) (*OrderResp, error) {
+func (e *Exchange) Sell(p model.Pair, price, size decimal.Decimal) (string, error) {
+	r := e.rules.Get(p)
 	//put limit order
-	limitOrderRespBody, err := PutLimitOrder(
-		size.RoundDown(4).String(),
-		price.String(),
+	limitOrderRespBody, err := e.PutLimitOrder(
+		r.RoundSize(size).String(),
+		r.RoundPrice(price, false).String(),
 		"sell",
-		"XCHUSDT")
+		Symbol(p))
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	var putLimitOrderResp OrderResp
 	if err := json.Unmarshal(limitOrderRespBody, &putLimitOrderResp); err != nil {
-		return nil, err
+		return "", err
+	}
+	if putLimitOrderResp.Code != 0 {
+		return "", fmt.Errorf("[Error %d] %v", putLimitOrderResp.Code, putLimitOrderResp.Message)
+	}
+	return strconv.FormatInt(putLimitOrderResp.Order.ID, 10), nil
+}
+
+type market struct {
+	MinAmount      string `json:"min_amount"`
+	TradingDecimal int32  `json:"trading_decimal"`
+	PricingDecimal int32  `json:"pricing_decimal"`
+}
+
+// QueryRules returns the order rules of p.
+func (e *Exchange) QueryRules(p model.Pair) (r model.Rules, err error) {
+	resp, err := e.rest.R().Get("https://api.coinex.com/v1/market/detail?market=" + Symbol(p))
+	if err != nil {
+		return r, fmt.Errorf("rest err: %w; resp: %+v", err, resp)
+	}
+
+	raw := &struct {
+		CommonResp
+		Data market `json:"data"`
+	}{}
+	if err := json.Unmarshal(resp.Body(), raw); err != nil {
+		return r, fmt.Errorf("json err: %w; resp: %+v", err, resp)
+	}
+	if raw.Code != 0 {
+		return r, fmt.Errorf("[Error %d] %v", raw.Code, raw.Message)
+	}
+
+	r.MinBase, err = decimal.NewFromString(raw.Data.MinAmount)
+	if err != nil {
+		return r, fmt.Errorf("failed to parse %v into decimal: %w", raw.Data.MinAmount, err)
+	}
+	r.BaseIncrement = model.Increment(raw.Data.TradingDecimal)
+	r.PriceIncrement = model.Increment(raw.Data.PricingDecimal)
+	return r, nil
+}
+
+// QueryFee returns the maker and taker fee ratios the account pays on p.
+func (e *Exchange) QueryFee(p model.Pair) (maker, taker decimal.Decimal, err error) {
+	body, err := e.HTTPGet(APIHTTPHOST+"/v1/account/market/fee", map[string]interface{}{
+		"market": Symbol(p),
+	})
+	if err != nil {
+		return maker, taker, err
+	}
+
+	raw := &struct {
+		CommonResp
+		Data struct {
+			Maker string `json:"maker"`
+			Taker string `json:"taker"`
+		} `json:"data"`
+	}{}
+	if err := json.Unmarshal(body, raw); err != nil {
+		return maker, taker, err
+	}
+	if raw.Code != 0 {
+		return maker, taker, fmt.Errorf("[Error %d] %v", raw.Code, raw.Message)
+	}
+
+	maker, err = decimal.NewFromString(raw.Data.Maker)
+	if err != nil {
+		return maker, taker, fmt.Errorf("failed to parse %v into decimal: %w", raw.Data.Maker, err)
+	}
+	taker, err = decimal.NewFromString(raw.Data.Taker)
+	if err != nil {
+		return maker, taker, fmt.Errorf("failed to parse %v into decimal: %w", raw.Data.Taker, err)
 	}
-	return &putLimitOrderResp, nil
+	return maker, taker, nil
 }
 
-func OrderTest() {
-	putLimitOrderResp, err := Buy(decimal.NewFromInt(20),
+func (e *Exchange) OrderTest() {
+	id, err := e.Buy(model.XCHUSDT, decimal.NewFromInt(20),
 		decimal.NewFromInt(1).Div(decimal.NewFromInt(10)))
 	if err != nil {
 		fmt.Printf("PutLimitOrder Error: %v\n", err)
 		return
 	}
-	fmt.Printf("PutLimitOrder: %v\n", putLimitOrderResp)
+	fmt.Printf("PutLimitOrder: %v\n", id)
 }
@@ -12,24 +12,8 @@ import (
 	"sort"
 	"strings"
 	"time"
-
-	"github.com/L3Sota/arbo/arb/config"
-	"gopkg.in/resty.v1"
-)
-
-var (
-	id     string
-	secret string
-	client *http.Client
 )
 
-func LoadClient(conf *config.Config) {
-	id = conf.CId
-	secret = conf.CSec
-	client = &http.Client{}
-	rest = resty.New()
-}
-
 // APIHTTPHOST api host
 const APIHTTPHOST = "https://api.coinex.com"
 
@@ -73,6 +57,7 @@ type OrderInfo struct {
 	CreateTime   int64  `json:"create_time"`
 	DealAmount   string `json:"deal_amount"`
 	DealFee      string `json:"deal_fee"`
+	FeeAsset     string `json:"fee_asset"`
 	DealMoney    string `json:"deal_money"`
 	FinishedTime int64  `json:"finished_time"`
 	MakerFeeRate string `json:"maker_fee_rate"`
@@ -139,13 +124,13 @@ func interfaceToString(v interface{}) string {
 	}
 }
 
-func httpRequest(method, urlHost string, reqParameters map[string]interface{}) ([]byte, error) {
+func (e *Exchange) httpRequest(method, urlHost string, reqParameters map[string]interface{}) ([]byte, error) {
 	params := make(map[string]interface{}, len(reqParameters))
 	for k, v := range reqParameters {
 		params[k] = v
 	}
 	currentMilliseconds := fmt.Sprintf("%d", time.Now().UnixNano()/1e6)
-	params["access_id"] = id
+	params["access_id"] = e.accessID
 	params["tonce"] = currentMilliseconds
 	var reqBody io.Reader
 	if method == "POST" {
@@ -170,7 +155,7 @@ func httpRequest(method, urlHost string, reqParameters map[string]interface{}) (
 	for _, k := range keys {
 		queryParamsString += fmt.Sprintf("%s=%s&", k, interfaceToString(params[k]))
 	}
-	toEncodeparamsString := queryParamsString + "secret_key=" + secret
+	toEncodeparamsString := queryParamsString + "secret_key=" + e.secret
 	req.Header.Set("Content-Type", CONTENTTYPE)
 	req.Header.Set("User-Agent", USERAGENT)
 	req.Header.Set("authorization", generateAuthorization(toEncodeparamsString))
@@ -178,7 +163,7 @@ func httpRequest(method, urlHost string, reqParameters map[string]interface{}) (
 		req.URL.RawQuery = queryParamsString
 	}
 
-	resp, err := client.Do(req)
+	resp, err := e.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -193,23 +178,23 @@ func httpRequest(method, urlHost string, reqParameters map[string]interface{}) (
 }
 
 // HTTPGet http get method
-func HTTPGet(urlHost string, parameters map[string]interface{}) ([]byte, error) {
-	return httpRequest("GET", urlHost, parameters)
+func (e *Exchange) HTTPGet(urlHost string, parameters map[string]interface{}) ([]byte, error) {
+	return e.httpRequest("GET", urlHost, parameters)
 }
 
 // HTTPPost http get method
-func HTTPPost(urlHost string, parameters map[string]interface{}) ([]byte, error) {
-	return httpRequest("POST", urlHost, parameters)
+func (e *Exchange) HTTPPost(urlHost string, parameters map[string]interface{}) ([]byte, error) {
+	return e.httpRequest("POST", urlHost, parameters)
 }
 
 // HTTPDelete http get method
-func HTTPDelete(urlHost string, parameters map[string]interface{}) ([]byte, error) {
-	return httpRequest("DELETE", urlHost, parameters)
+func (e *Exchange) HTTPDelete(urlHost string, parameters map[string]interface{}) ([]byte, error) {
+	return e.httpRequest("DELETE", urlHost, parameters)
 }
 
 // GetAccount Inquire account asset constructure
-func GetAccount() ([]byte, error) {
-	resp, err := HTTPGet(APIHTTPHOST+"/v1/balance/info", nil)
+func (e *Exchange) GetAccount() ([]byte, error) {
+	resp, err := e.HTTPGet(APIHTTPHOST+"/v1/balance/info", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -218,14 +203,14 @@ func GetAccount() ([]byte, error) {
 }
 
 // QueryOrderPending Acquire Unexecuted Order List.
-func QueryOrderPending(market string, accountID, page, limit int) ([]byte, error) {
+func (e *Exchange) QueryOrderPending(market string, accountID, page, limit int) ([]byte, error) {
 	parameters := map[string]interface{}{
 		"market":     market,
 		"page":       page,
 		"limit":      limit,
 		"account_id": accountID,
 	}
-	resp, err := HTTPGet(APIHTTPHOST+"/v1/order/pending", parameters)
+	resp, err := e.HTTPGet(APIHTTPHOST+"/v1/order/pending", parameters)
 	if err != nil {
 		return nil, err
 	}
@@ -234,14 +219,28 @@ func QueryOrderPending(market string, accountID, page, limit int) ([]byte, error
 }
 
 // QueryOrderFinished Acquire executed order list
-func QueryOrderFinished(market string, accountID, page, limit int) ([]byte, error) {
+func (e *Exchange) QueryOrderFinished(market string, accountID, page, limit int) ([]byte, error) {
 	parameters := map[string]interface{}{
 		"market":     market,
 		"page":       page,
 		"limit":      limit,
 		"account_id": accountID,
 	}
-	resp, err := HTTPGet(APIHTTPHOST+"/v1/order/finished", parameters)
+	resp, err := e.HTTPGet(APIHTTPHOST+"/v1/order/finished", parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// QueryOrder Acquire order status
+func (e *Exchange) QueryOrder(orderID int64, market string) ([]byte, error) {
+	parameters := map[string]interface{}{
+		"id":     orderID,
+		"market": market,
+	}
+	resp, err := e.HTTPGet(APIHTTPHOST+"/v1/order/status", parameters)
 	if err != nil {
 		return nil, err
 	}
@@ -250,14 +249,14 @@ func QueryOrderFinished(market string, accountID, page, limit int) ([]byte, erro
 }
 
 // PutLimitOrder create limit order
-func PutLimitOrder(amount, price, orderType, market string) ([]byte, error) {
+func (e *Exchange) PutLimitOrder(amount, price, orderType, market string) ([]byte, error) {
 	parameters := map[string]interface{}{
 		"amount": amount,
 		"price":  price,
 		"type":   orderType,
 		"market": market,
 	}
-	resp, err := HTTPPost(APIHTTPHOST+"/v1/order/limit", parameters)
+	resp, err := e.HTTPPost(APIHTTPHOST+"/v1/order/limit", parameters)
 	if err != nil {
 		return nil, err
 	}
@@ -266,13 +265,13 @@ func PutLimitOrder(amount, price, orderType, market string) ([]byte, error) {
 }
 
 // PutMarketOrder create market order
-func PutMarketOrder(amount, orderType, market string) ([]byte, error) {
+func (e *Exchange) PutMarketOrder(amount, orderType, market string) ([]byte, error) {
 	parameters := map[string]interface{}{
 		"amount": amount,
 		"type":   orderType,
 		"market": market,
 	}
-	resp, err := HTTPPost(APIHTTPHOST+"/v1/order/market", parameters)
+	resp, err := e.HTTPPost(APIHTTPHOST+"/v1/order/market", parameters)
 	if err != nil {
 		return nil, err
 	}
@@ -281,13 +280,13 @@ func PutMarketOrder(amount, orderType, market string) ([]byte, error) {
 }
 
 // CreateSubAccount create sub account
-func CreateSubAccount(name string, allowedIPs []string) ([]byte, error) {
+func (e *Exchange) CreateSubAccount(name string, allowedIPs []string) ([]byte, error) {
 	parameters := map[string]interface{}{
 		"allow_trade":   true,
 		"allowed_ips":   allowedIPs,
 		"sub_user_name": name,
 	}
-	resp, err := HTTPPost(APIHTTPHOST+"/v1/sub_account/auth/api", parameters)
+	resp, err := e.HTTPPost(APIHTTPHOST+"/v1/sub_account/auth/api", parameters)
 	if err != nil {
 		return nil, err
 	}
@@ -295,12 +294,12 @@ func CreateSubAccount(name string, allowedIPs []string) ([]byte, error) {
 }
 
 // CancelOrder Cancel unexecuted order
-func CancelOrder(orderID int64, market string) ([]byte, error) {
+func (e *Exchange) CancelOrder(orderID int64, market string) ([]byte, error) {
 	parameters := map[string]interface{}{
 		"id":     orderID,
 		"market": market,
 	}
-	resp, err := HTTPDelete(APIHTTPHOST+"/v1/order/pending", parameters)
+	resp, err := e.HTTPDelete(APIHTTPHOST+"/v1/order/pending", parameters)
 	if err != nil {
 		return nil, err
 	}
@@ -308,9 +307,9 @@ func CancelOrder(orderID int64, market string) ([]byte, error) {
 	return resp, nil
 }
 
-func main() {
+func (e *Exchange) demo() {
 	//Inquire account asset constructure
-	accooutRespBody, err := GetAccount()
+	accooutRespBody, err := e.GetAccount()
 	if err != nil {
 		fmt.Printf("GetAccount Error: %s", err)
 		return
@@ -320,7 +319,7 @@ func main() {
 	fmt.Printf("%v\n", balanceResp)
 
 	//put limit order
-	limitOrderRespBody, err := PutLimitOrder("1", "1", "buy", "BTCUSDT")
+	limitOrderRespBody, err := e.PutLimitOrder("1", "1", "buy", "BTCUSDT")
 	if err != nil {
 		fmt.Printf("PutLimitOrder Error: %v\n", err)
 		return
@@ -330,7 +329,7 @@ func main() {
 	fmt.Printf("PutLimitOrder: %v\n", putLimitOrderResp)
 
 	// Cancel order
-	cancelOrderRespBody, err := CancelOrder(putLimitOrderResp.Order.ID, "BTCUSDT")
+	cancelOrderRespBody, err := e.CancelOrder(putLimitOrderResp.Order.ID, "BTCUSDT")
 	if err != nil {
 		fmt.Printf("CancelOrder Error: %v", err)
 		return
@@ -340,7 +339,7 @@ func main() {
 	fmt.Printf("CancelOrder: %v\n", cancleOrderResp)
 
 	// put market order
-	putMarketRespBody, err := PutMarketOrder("100", "buy", "BTCUSDT")
+	putMarketRespBody, err := e.PutMarketOrder("100", "buy", "BTCUSDT")
 	if err != nil {
 		fmt.Printf("PutMarketOrder Error: %v\n", err)
 		return
@@ -350,7 +349,7 @@ func main() {
 	fmt.Printf("PutMarketOrder: %v\n", putMarketOrderResp)
 
 	//Acquire Unexecuted Order List
-	queryOrderRespBody, err := QueryOrderPending("BTCUSDT", 0, 1, 10)
+	queryOrderRespBody, err := e.QueryOrderPending("BTCUSDT", 0, 1, 10)
 	if err != nil {
 		fmt.Printf("CancelOrder Error: %v", err)
 		return
@@ -360,7 +359,7 @@ func main() {
 	fmt.Printf("QueryOrder: %v\n", queryOrders)
 
 	// Acquire executed order list
-	queryFinishedOrderRespBody, err := QueryOrderFinished("BTCUSDT", 0, 1, 10)
+	queryFinishedOrderRespBody, err := e.QueryOrderFinished("BTCUSDT", 0, 1, 10)
 	if err != nil {
 		fmt.Printf("CancelOrder Error: %v", err)
 		return
@@ -0,0 +1,106 @@
+package c
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/L3Sota/arbo/arb/ratelimit"
+	"github.com/shopspring/decimal"
+)
+
+func (e *Exchange) Venue() model.Venue {
+	return model.Venue{
+		ID:       e.id,
+		Exchange: model.ExchangeTypeCo,
+		Fees:     e.fees.Fees(),
+		PairFees: e.fees.All(),
+		Rules:    e.rules.All(),
+		Deposits: Deposits,
+	}
+}
+
+func (e *Exchange) GetOrder(p model.Pair, id string) (s model.OrderStatus, err error) {
+	oid, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return s, fmt.Errorf("invalid order id %v: %w", id, err)
+	}
+
+	body, err := e.QueryOrder(oid, Symbol(p))
+	if err != nil {
+		return s, err
+	}
+	var o OrderResp
+	if err := json.Unmarshal(body, &o); err != nil {
+		return s, err
+	}
+	if o.Code != 0 {
+		return s, fmt.Errorf("[Error %d] %v", o.Code, o.Message)
+	}
+
+	filled, err := decimal.NewFromString(o.Order.DealAmount)
+	if err != nil {
+		return s, fmt.Errorf("failed to parse %v into decimal: %w", o.Order.DealAmount, err)
+	}
+	funds, err := decimal.NewFromString(o.Order.DealMoney)
+	if err != nil {
+		return s, fmt.Errorf("failed to parse %v into decimal: %w", o.Order.DealMoney, err)
+	}
+	fee, err := decimal.NewFromString(o.Order.DealFee)
+	if err != nil {
+		return s, fmt.Errorf("failed to parse %v into decimal: %w", o.Order.DealFee, err)
+	}
+
+	// fee_asset is only set when the fee is paid in another asset, e.g. CET;
+	// otherwise buys are charged in the base currency, sells in the quote
+	feeCurrency := o.Order.FeeAsset
+	if feeCurrency == "" {
+		feeCurrency = p.Quote
+		if o.Order.Type == "buy" {
+			feeCurrency = p.Base
+		}
+	}
+
+	return model.OrderStatus{
+		ID:          id,
+		Filled:      filled,
+		FilledFunds: funds,
+		Fee:         fee,
+		FeeCurrency: feeCurrency,
+		Active:      o.Order.Status == "not_deal" || o.Order.Status == "part_deal",
+		State:       o.Order.Status,
+	}, nil
+}
+
+func (e *Exchange) Cancel(p model.Pair, id string) error {
+	oid, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid order id %v: %w", id, err)
+	}
+
+	body, err := e.CancelOrder(oid, Symbol(p))
+	if err != nil {
+		return err
+	}
+	var o CancelOrderResp
+	if err := json.Unmarshal(body, &o); err != nil {
+		return err
+	}
+	if o.Code != 0 {
+		return fmt.Errorf("[Error %d] %v", o.Code, o.Message)
+	}
+	return nil
+}
+
+func (e *Exchange) Limiter() *ratelimit.Limiter {
+	return e.limiter
+}
+
+func (e *Exchange) SetRules(p model.Pair, r model.Rules) {
+	e.rules.Set(p, r)
+}
+
+func (e *Exchange) SetFees(p model.Pair, maker, taker decimal.Decimal) {
+	e.fees.Set(p, maker, taker)
+}
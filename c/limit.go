@@ -0,0 +1,31 @@
+package c
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/L3Sota/arbo/arb/ratelimit"
+)
+
+// Limits are CoinEx's documented v1 limits: 20 requests per second for market
+// data and for account queries, and 100 order placements or cancellations per
+// 10s.
+var Limits = [ratelimit.ClassMax]ratelimit.Limit{
+	ratelimit.Public:  {N: 20, Per: time.Second},
+	ratelimit.Private: {N: 20, Per: time.Second},
+	ratelimit.Order:   {N: 100, Per: 10 * time.Second},
+}
+
+func classifyPublic(*http.Request) ratelimit.Class {
+	return ratelimit.Public
+}
+
+// classifyPrivate classes the signed requests: placing and cancelling orders
+// under /v1/order, everything else as private.
+func classifyPrivate(r *http.Request) ratelimit.Class {
+	if strings.HasPrefix(r.URL.Path, "/v1/order/") && (r.Method == http.MethodPost || r.Method == http.MethodDelete) {
+		return ratelimit.Order
+	}
+	return ratelimit.Private
+}
@@ -4,28 +4,29 @@ import (
 	"fmt"
 
 	"github.com/L3Sota/arbo/arb/config"
+	"github.com/L3Sota/arbo/arb/model"
 	"github.com/L3Sota/arbo/c"
 )
 
 func main() {
-	c.LoadClient(config.Load())
-	c.OrderTest()
-	// balances()
-	// book()
+	e := c.New("Co", config.Load().Account("Co"))
+	e.OrderTest()
+	// balances(e)
+	// book(e)
 }
 
-func book() {
-	a, b, err := c.Book()
+func book(e *c.Exchange) {
+	a, b, err := e.Book(model.XCHUSDT)
 
 	fmt.Println(a)
 	fmt.Println(b)
 	fmt.Println(err)
 }
 
-func balances() {
-	b, err := c.Balances()
+func balances(e *c.Exchange) {
+	b, err := e.Balances()
 
-	fmt.Println(b.USDT.String())
-	fmt.Println(b.XCH.String())
+	fmt.Println(b["USDT"].String())
+	fmt.Println(b["XCH"].String())
 	fmt.Println(err)
 }
@@ -0,0 +1,58 @@
+package c
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/shopspring/decimal"
+)
+
+// Chains are CoinEx's smart contract names by network. Currencies on a single
+// chain, like XCH, take none.
+var Chains = map[string]string{
+	"CHIA":  "",
+	"TRC20": "TRC20",
+	"SOL":   "SOL",
+}
+
+// WithdrawResp response for api '/v1/balance/coin/withdraw'
+type WithdrawResp struct {
+	CommonResp
+	Data struct {
+		ID int64 `json:"coin_withdraw_id"`
+	} `json:"data"`
+}
+
+// Withdraw sends amount of currency to address over w's network. The fee is
+// charged on top of amount.
+func (e *Exchange) Withdraw(w model.Withdrawal, currency, address string, amount decimal.Decimal) (string, error) {
+	chain, ok := Chains[w.Network]
+	if !ok {
+		return "", fmt.Errorf("no chain for network %v", w.Network)
+	}
+
+	parameters := map[string]interface{}{
+		"coin_type":       currency,
+		"coin_address":    address,
+		"actual_amount":   amount.String(),
+		"transfer_method": "onchain",
+	}
+	if chain != "" {
+		parameters["smart_contract_name"] = chain
+	}
+	body, err := e.HTTPPost(APIHTTPHOST+"/v1/balance/coin/withdraw", parameters)
+	if err != nil {
+		return "", err
+	}
+
+	var r WithdrawResp
+	if err := json.Unmarshal(body, &r); err != nil {
+		return "", err
+	}
+	if r.Code != 0 {
+		return "", fmt.Errorf("[Error %d] %v", r.Code, r.Message)
+	}
+	return strconv.FormatInt(r.Data.ID, 10), nil
+}
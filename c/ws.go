@@ -0,0 +1,228 @@
+package c
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/L3Sota/arbo/arb/depth"
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/gorilla/websocket"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	wsURL = "wss://socket.coinex.com/"
+
+	depthLimit = 50
+	// pingEvery keeps the connection alive; each pong also confirms the book.
+	pingEvery = 5 * time.Second
+	// restartAfter is how long a stream may stay quiet before it is reopened.
+	restartAfter = 6 * pingEvery
+)
+
+var errQuiet = errors.New("stream quiet")
+
+type wsRequest struct {
+	ID     int64         `json:"id"`
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+type wsMessage struct {
+	ID     *int64            `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+	Result json.RawMessage   `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// depthUpdate is a depth.update push. Clean updates carry the full book,
+// others only the changed levels; a zero amount removes the level.
+type depthUpdate struct {
+	Clean bool
+	Asks  []depth.Level
+	Bids  []depth.Level
+}
+
+// stream returns the local book for p, starting its stream on first use.
+func (e *Exchange) stream(p model.Pair) *depth.Book {
+	e.smu.Lock()
+	defer e.smu.Unlock()
+
+	if b, ok := e.books[p]; ok {
+		return b
+	}
+	b := depth.New()
+	e.books[p] = b
+	go e.watch(p, b)
+	return b
+}
+
+// watch keeps b in sync with the depth subscription of p, reconnecting
+// whenever the stream fails.
+func (e *Exchange) watch(p model.Pair, b *depth.Book) {
+	for {
+		err := e.follow(context.Background(), p, b)
+		b.Reset()
+		fmt.Printf("%v %v depth stream: %v\n", e.id, p, err)
+		time.Sleep(time.Second)
+	}
+}
+
+// follow subscribes to the depth of p and applies updates to b until the
+// connection fails or ctx is done. CoinEx starts with a clean update, so no
+// REST snapshot is needed.
+func (e *Exchange) follow(ctx context.Context, p model.Pair, b *depth.Book) error {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, e.wsURL, nil)
+	if err != nil {
+		return fmt.Errorf("dial: %w", err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteJSON(wsRequest{
+		ID:     1,
+		Method: "depth.subscribe",
+		Params: []interface{}{Symbol(p), depthLimit, "0", true},
+	}); err != nil {
+		return fmt.Errorf("subscribe: %w", err)
+	}
+
+	// a nil update is a pong
+	updates := make(chan *depthUpdate, 64)
+	errc := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			var m wsMessage
+			if err := conn.ReadJSON(&m); err != nil {
+				errc <- err
+				return
+			}
+			if m.Error != nil {
+				errc <- fmt.Errorf("[Error %d] %v", m.Error.Code, m.Error.Message)
+				return
+			}
+			var u *depthUpdate
+			switch {
+			case m.Method == "depth.update":
+				d, err := parseUpdate(m.Params)
+				if err != nil {
+					errc <- err
+					return
+				}
+				u = &d
+			case string(m.Result) != `"pong"`:
+				continue
+			}
+			select {
+			case updates <- u:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(pingEvery)
+	defer ping.Stop()
+	quiet := time.NewTimer(restartAfter)
+	defer quiet.Stop()
+	for id := int64(2); ; {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errc:
+			return err
+		case <-quiet.C:
+			return errQuiet
+		case <-ping.C:
+			if err := conn.WriteJSON(wsRequest{ID: id, Method: "server.ping", Params: []interface{}{}}); err != nil {
+				return fmt.Errorf("ping: %w", err)
+			}
+			id++
+		case u := <-updates:
+			if !quiet.Stop() {
+				<-quiet.C
+			}
+			quiet.Reset(restartAfter)
+
+			switch {
+			case u == nil:
+				if b.Ready() {
+					b.Touch()
+				}
+			case u.Clean:
+				b.Replace(u.Asks, u.Bids)
+			case b.Ready():
+				b.Apply(u.Asks, u.Bids)
+			}
+		}
+	}
+}
+
+// parseUpdate parses the params of depth.update: [clean, depth, market].
+func parseUpdate(params []json.RawMessage) (u depthUpdate, err error) {
+	if len(params) < 2 {
+		return u, fmt.Errorf("short depth.update params: %d", len(params))
+	}
+	if err := json.Unmarshal(params[0], &u.Clean); err != nil {
+		return u, fmt.Errorf("read clean: %w", err)
+	}
+	var d struct {
+		Asks [][]string `json:"asks"`
+		Bids [][]string `json:"bids"`
+	}
+	if err := json.Unmarshal(params[1], &d); err != nil {
+		return u, fmt.Errorf("read depth: %w", err)
+	}
+	if u.Asks, err = levels(d.Asks); err != nil {
+		return u, err
+	}
+	if u.Bids, err = levels(d.Bids); err != nil {
+		return u, err
+	}
+	return u, nil
+}
+
+// levels parses [price, amount] rows.
+func levels(rows [][]string) ([]depth.Level, error) {
+	ls := make([]depth.Level, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 2 {
+			return nil, fmt.Errorf("short level %v", row)
+		}
+		p, err := decimal.NewFromString(row[0])
+		if err != nil {
+			return nil, fmt.Errorf("tried to parse %v, got err: %w", row[0], err)
+		}
+		amt, err := decimal.NewFromString(row[1])
+		if err != nil {
+			return nil, fmt.Errorf("tried to parse %v, got err: %w", row[1], err)
+		}
+		ls = append(ls, depth.Level{Price: p, Amount: amt})
+	}
+	return ls, nil
+}
+
+// Watch returns a channel that receives whenever the best bid or ask of p
+// changes.
+func (e *Exchange) Watch(p model.Pair) <-chan struct{} {
+	return e.stream(p).Watch()
+}
+
+// Age returns how long ago the streamed book for p was last confirmed in
+// sync.
+func (e *Exchange) Age(p model.Pair) time.Duration {
+	return e.stream(p).Age()
+}
+
+// Streaming reports whether Book serves p from the stream.
+func (e *Exchange) Streaming(p model.Pair) bool {
+	return e.stream(p).Ready()
+}
@@ -0,0 +1,75 @@
+package g
+
+import (
+	"fmt"
+
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/L3Sota/arbo/arb/ratelimit"
+	"github.com/gateio/gateapi-go/v6"
+	"github.com/shopspring/decimal"
+)
+
+func (e *Exchange) Venue() model.Venue {
+	return model.Venue{
+		ID:       e.id,
+		Exchange: model.ExchangeTypeGa,
+		Fees:     e.fees.Fees(),
+		PairFees: e.fees.All(),
+		Rules:    e.rules.All(),
+		Deposits: Deposits,
+	}
+}
+
+func (e *Exchange) GetOrder(p model.Pair, id string) (model.OrderStatus, error) {
+	o, err := e.Order(p, id)
+	if err != nil {
+		return model.OrderStatus{}, err
+	}
+	return orderStatus(o)
+}
+
+func (e *Exchange) Cancel(p model.Pair, id string) error {
+	_, _, err := e.client.SpotApi.CancelOrder(e.authContext(), id, Symbol(p), nil)
+	return err
+}
+
+func orderStatus(o gateapi.Order) (s model.OrderStatus, err error) {
+	amount, err := decimal.NewFromString(o.Amount)
+	if err != nil {
+		return s, fmt.Errorf("failed to parse %v into decimal: %w", o.Amount, err)
+	}
+	left, err := decimal.NewFromString(o.Left)
+	if err != nil {
+		return s, fmt.Errorf("failed to parse %v into decimal: %w", o.Left, err)
+	}
+	funds, err := decimal.NewFromString(o.FilledTotal)
+	if err != nil {
+		return s, fmt.Errorf("failed to parse %v into decimal: %w", o.FilledTotal, err)
+	}
+	fee, err := decimal.NewFromString(o.Fee)
+	if err != nil {
+		return s, fmt.Errorf("failed to parse %v into decimal: %w", o.Fee, err)
+	}
+
+	return model.OrderStatus{
+		ID:          o.Id,
+		Filled:      amount.Sub(left),
+		FilledFunds: funds,
+		Fee:         fee,
+		FeeCurrency: o.FeeCurrency,
+		Active:      o.Status == "open",
+		State:       o.Status,
+	}, nil
+}
+
+func (e *Exchange) Limiter() *ratelimit.Limiter {
+	return e.limiter
+}
+
+func (e *Exchange) SetFees(p model.Pair, maker, taker decimal.Decimal) {
+	e.fees.Set(p, maker, taker)
+}
+
+func (e *Exchange) SetRules(p model.Pair, r model.Rules) {
+	e.rules.Set(p, r)
+}
@@ -3,34 +3,100 @@ package g
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"sync"
 
 	"github.com/L3Sota/arbo/arb/config"
+	"github.com/L3Sota/arbo/arb/depth"
+	"github.com/L3Sota/arbo/arb/fees"
 	"github.com/L3Sota/arbo/arb/model"
+	"github.com/L3Sota/arbo/arb/ratelimit"
+	"github.com/L3Sota/arbo/arb/rules"
+	"github.com/antihax/optional"
 	"github.com/gateio/gateapi-go/v6"
 	"github.com/shopspring/decimal"
 )
 
 var (
 	Fees = model.Fees{
-		MakerTakerRatio:    decimal.RequireFromString("0.00097"), // 0.097%
-		WithdrawalFlatXCH:  decimal.RequireFromString("0.0145"),  // variable?
-		WithdrawalFlatUSDT: decimal.RequireFromString("0.5"),     // SOL
+		Maker: decimal.RequireFromString("0.00097"), // 0.097%
+		Taker: decimal.RequireFromString("0.00097"), // 0.097%
+		Withdrawals: map[string][]model.Withdrawal{
+			"XCH":  {{Network: "CHIA", Fee: decimal.RequireFromString("0.0145")}}, // variable?
+			"USDT": {{Network: "SOL", Fee: decimal.RequireFromString("0.5")}},
+		},
+	}
+	Deposits = map[string][]string{
+		"XCH":  {"CHIA"},
+		"USDT": {"TRC20", "SOL", "ARB"},
+	}
+	Rules = map[model.Pair]model.Rules{
+		model.XCHUSDT: {
+			MinQuote: decimal.NewFromInt(3),
+		},
 	}
-	AskAddition  = decimal.NewFromInt(1).Add(Fees.MakerTakerRatio)
-	BidReduction = decimal.NewFromInt(1).Sub(Fees.MakerTakerRatio)
-
-	client *gateapi.APIClient
 )
 
-func LoadClient() {
-	client = gateapi.NewAPIClient(gateapi.NewConfiguration())
+type Exchange struct {
+	id      model.VenueID
+	client  *gateapi.APIClient
+	auth    gateapi.GateAPIV4
+	wsURL   string
+	limiter *ratelimit.Limiter
+
+	smu   sync.Mutex
+	books map[model.Pair]*depth.Book
+
+	fees  *fees.Schedule
+	rules *rules.Table
+}
+
+func New(id model.VenueID, a config.Account) *Exchange {
+	l := ratelimit.New(Limits, Headers)
+	cfg := gateapi.NewConfiguration()
+	cfg.HTTPClient = &http.Client{
+		Transport: &ratelimit.Transport{Limiter: l, Classify: classify},
+	}
+	return &Exchange{
+		id:      id,
+		client:  gateapi.NewAPIClient(cfg),
+		limiter: l,
+		auth: gateapi.GateAPIV4{
+			Key:    a.Key,
+			Secret: a.Sec,
+		},
+		wsURL: wsURL,
+		books: map[model.Pair]*depth.Book{},
+		fees:  fees.New(Fees),
+		rules: rules.New(Rules),
+	}
+}
+
+func (e *Exchange) authContext() context.Context {
+	return context.WithValue(context.Background(), gateapi.ContextGateAPIV4, e.auth)
+}
+
+// Symbol returns the Gate currency pair for p, e.g. XCH_USDT.
+func Symbol(p model.Pair) string {
+	return p.Base + "_" + p.Quote
 }
 
-func Book() ([]model.Order, []model.Order, error) {
+// Book serves the streamed book for p, falling back to REST until the stream
+// is in sync.
+func (e *Exchange) Book(p model.Pair) ([]model.Order, []model.Order, error) {
+	if s := e.stream(p); s.Ready() {
+		askAddition, bidReduction := e.fees.Ratios(p, false)
+		a, b := s.Orders(e.id, askAddition, bidReduction)
+		return a, b, nil
+	}
+	return e.restBook(p)
+}
+
+func (e *Exchange) restBook(p model.Pair) ([]model.Order, []model.Order, error) {
 	// uncomment the next line if your are testing against testnet
-	// client.ChangeBasePath("https://fx-api-testnet.gateio.ws/api/v4")
+	// e.client.ChangeBasePath("https://fx-api-testnet.gateio.ws/api/v4")
 
-	o, _, err := client.SpotApi.ListOrderBook(context.Background(), "XCH_USDT", nil)
+	o, _, err := e.client.SpotApi.ListOrderBook(context.Background(), Symbol(p), nil)
 	if err != nil {
 		if e, ok := err.(gateapi.GateAPIError); ok {
 			fmt.Printf("gate api error: %s\n", e.Error())
@@ -40,9 +106,10 @@ func Book() ([]model.Order, []model.Order, error) {
 		return nil, nil, fmt.Errorf("order book: %w", err)
 	}
 
+	askAddition, bidReduction := e.fees.Ratios(p, false)
 	a := make([]model.Order, 0, len(o.Asks))
 	for _, ask := range o.Asks {
-		p, err := decimal.NewFromString(ask[0])
+		price, err := decimal.NewFromString(ask[0])
 		if err != nil {
 			return nil, nil, fmt.Errorf("tried to parse %v, got err: %w", ask[0], err)
 		}
@@ -51,16 +118,16 @@ func Book() ([]model.Order, []model.Order, error) {
 			return nil, nil, fmt.Errorf("tried to parse %v, got err: %w", ask[1], err)
 		}
 		o := model.Order{
-			Ex:     model.ExchangeTypeGa,
-			Price:  p,
+			Venue:  e.id,
+			Price:  price,
 			Amount: amt,
 		}
-		o.EffectivePrice = o.Price.Mul(AskAddition)
+		o.EffectivePrice = o.Price.Mul(askAddition)
 		a = append(a, o)
 	}
 	b := make([]model.Order, 0, len(o.Bids))
 	for _, bid := range o.Bids {
-		p, err := decimal.NewFromString(bid[0])
+		price, err := decimal.NewFromString(bid[0])
 		if err != nil {
 			return nil, nil, fmt.Errorf("tried to parse %v, got err: %w", bid[0], err)
 		}
@@ -69,101 +136,93 @@ func Book() ([]model.Order, []model.Order, error) {
 			return nil, nil, fmt.Errorf("tried to parse %v, got err: %w", bid[1], err)
 		}
 		o := model.Order{
-			Ex:     model.ExchangeTypeGa,
-			Price:  p,
+			Venue:  e.id,
+			Price:  price,
 			Amount: amt,
 		}
-		o.EffectivePrice = o.Price.Mul(BidReduction)
+		o.EffectivePrice = o.Price.Mul(bidReduction)
 		b = append(b, o)
 	}
 
 	return a, b, nil
 }
 
-func Balances(c *config.Config) (b model.Balances, err error) {
-	ctx := context.WithValue(context.Background(),
-		gateapi.ContextGateAPIV4,
-		gateapi.GateAPIV4{
-			Key:    c.GKey,
-			Secret: c.GSec,
-		},
-	)
+func (e *Exchange) Balances() (b model.Balances, err error) {
+	ctx := e.authContext()
 
-	a, _, err := client.SpotApi.ListSpotAccounts(ctx, nil)
+	a, _, err := e.client.SpotApi.ListSpotAccounts(ctx, nil)
 	if err != nil {
 		return b, err
 	}
 
+	b = make(model.Balances)
 	for _, aa := range a {
-		switch aa.Currency {
-		case "USDT":
-			usdt, err := decimal.NewFromString(aa.Available)
-			if err != nil {
-				return b, fmt.Errorf("failed to parse %v into decimal: %w", aa.Available, err)
-			}
-			b.USDT = usdt
-		case "XCH":
-			xch, err := decimal.NewFromString(aa.Available)
-			if err != nil {
-				return b, fmt.Errorf("failed to parse %v into decimal: %w", aa.Available, err)
-			}
-			b.XCH = xch
+		avail, err := decimal.NewFromString(aa.Available)
+		if err != nil {
+			return b, fmt.Errorf("failed to parse %v into decimal: %w", aa.Available, err)
 		}
+		b[aa.Currency] = avail
 	}
 
 	return b, nil
 }
 
-func Buy(price, size decimal.Decimal, c *config.Config) (gateapi.Order, error) {
-	ctx := context.WithValue(context.Background(),
-		gateapi.ContextGateAPIV4,
-		gateapi.GateAPIV4{
-			Key:    c.GKey,
-			Secret: c.GSec,
-		},
-	)
+func (e *Exchange) Buy(p model.Pair, price, size decimal.Decimal) (string, error) {
+	r := e.rules.Get(p)
+	ctx := e.authContext()
 
 	// min order size 1 USDT
-	o, _, err := client.SpotApi.CreateOrder(ctx, gateapi.Order{
-		CurrencyPair: "XCH_USDT",
+	o, _, err := e.client.SpotApi.CreateOrder(ctx, gateapi.Order{
+		CurrencyPair: Symbol(p),
 		Type:         "limit",
 		Account:      "spot",
 		Side:         "buy",
-		Amount:       size.RoundDown(4).String(), // Amount in XCH (base currency)
-		Price:        price.String(),             // Price in USDT (quote currency)
+		Amount:       r.RoundSize(size).String(),         // Amount in base currency
+		Price:        r.RoundPrice(price, true).String(), // Price in quote currency
 		TimeInForce:  "gtc",
 	})
+	if err != nil {
+		return "", err
+	}
 
-	return o, err
+	return o.Id, nil
 }
 
-func Sell(price, size decimal.Decimal, c *config.Config) (gateapi.Order, error) {
-	ctx := context.WithValue(context.Background(),
-		gateapi.ContextGateAPIV4,
-		gateapi.GateAPIV4{
-			Key:    c.GKey,
-			Secret: c.GSec,
-		},
-	)
+func (e *Exchange) Sell(p model.Pair, price, size decimal.Decimal) (string, error) {
+	r := e.rules.Get(p)
+	ctx := e.authContext()
 
 	// min order size 1 USDT
-	o, _, err := client.SpotApi.CreateOrder(ctx, gateapi.Order{
-		CurrencyPair: "XCH_USDT",
+	o, _, err := e.client.SpotApi.CreateOrder(ctx, gateapi.Order{
+		CurrencyPair: Symbol(p),
 		Type:         "limit",
 		Account:      "spot",
 		Side:         "sell",
-		Amount:       size.RoundDown(4).String(), // Amount in XCH (base currency)
-		Price:        price.String(),             // Price in USDT (quote currency)
+		Amount:       r.RoundSize(size).String(),          // Amount in base currency
+		Price:        r.RoundPrice(price, false).String(), // Price in quote currency
 		TimeInForce:  "gtc",
 	})
+	if err != nil {
+		return "", err
+	}
+
+	return o.Id, nil
+}
 
+func (e *Exchange) Order(p model.Pair, id string) (gateapi.Order, error) {
+	o, _, err := e.client.SpotApi.GetOrder(e.authContext(), id, Symbol(p), nil)
 	return o, err
 }
 
 // order: {Id:489126754641 Text:apiv4 AmendText:- CreateTime:1705482626 UpdateTime:1705482626 CreateTimeMs:1705482626977 UpdateTimeMs:1705482626977 Status:cancelled CurrencyPair:XCH_USDT Type:limit Account:spot Side:buy Amount:0.1 Price:20 TimeInForce:ioc Iceberg:0 AutoBorrow:false AutoRepay:false Left:0.1 FillPrice:0 FilledTotal:0 AvgDealPrice: Fee:0 FeeCurrency:XCH PointFee:0 GtFee:0 GtMakerFee:0 GtTakerFee:0 GtDiscount:false RebatedFee:0 RebatedFeeCurrency:USDT StpId:0 StpAct: FinishAs:ioc}
-func OrderTest(c *config.Config) {
-	o, err := Buy(decimal.NewFromInt(20), decimal.NewFromInt(1).Div(decimal.NewFromInt(10)), c)
+func (e *Exchange) OrderTest() {
+	id, err := e.Buy(model.XCHUSDT, decimal.NewFromInt(20), decimal.NewFromInt(1).Div(decimal.NewFromInt(10)))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 
+	o, err := e.Order(model.XCHUSDT, id)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -174,20 +233,47 @@ func OrderTest(c *config.Config) {
 
 // {14541031 0.002 0.002 false 0 0 0.18 1 0.0005 0.00015 0.00016 -0.00015}
 // ^ 0.2% maker taker
-func QueryFee(c *config.Config) {
-	ctx := context.WithValue(context.Background(),
-		gateapi.ContextGateAPIV4,
-		gateapi.GateAPIV4{
-			Key:    c.GKey,
-			Secret: c.GSec,
-		},
-	)
+// QueryRules returns the order rules of p.
+func (e *Exchange) QueryRules(p model.Pair) (r model.Rules, err error) {
+	cp, _, err := e.client.SpotApi.GetCurrencyPair(context.Background(), Symbol(p))
+	if err != nil {
+		return r, err
+	}
+
+	// unset minimums are omitted
+	if cp.MinBaseAmount != "" {
+		r.MinBase, err = decimal.NewFromString(cp.MinBaseAmount)
+		if err != nil {
+			return r, fmt.Errorf("failed to parse %v into decimal: %w", cp.MinBaseAmount, err)
+		}
+	}
+	if cp.MinQuoteAmount != "" {
+		r.MinQuote, err = decimal.NewFromString(cp.MinQuoteAmount)
+		if err != nil {
+			return r, fmt.Errorf("failed to parse %v into decimal: %w", cp.MinQuoteAmount, err)
+		}
+	}
+	r.BaseIncrement = model.Increment(cp.AmountPrecision)
+	r.PriceIncrement = model.Increment(cp.Precision)
+	return r, nil
+}
 
-	fee, _, err := client.WalletApi.GetTradeFee(ctx, nil)
+// QueryFee returns the fee ratios charged to the account on p.
+func (e *Exchange) QueryFee(p model.Pair) (maker, taker decimal.Decimal, err error) {
+	f, _, err := e.client.WalletApi.GetTradeFee(e.authContext(), &gateapi.GetTradeFeeOpts{
+		CurrencyPair: optional.NewString(Symbol(p)),
+	})
 	if err != nil {
-		fmt.Println(err)
-		return
+		return maker, taker, err
 	}
 
-	fmt.Printf("%+v\n", fee)
+	maker, err = decimal.NewFromString(f.MakerFee)
+	if err != nil {
+		return maker, taker, fmt.Errorf("failed to parse %v into decimal: %w", f.MakerFee, err)
+	}
+	taker, err = decimal.NewFromString(f.TakerFee)
+	if err != nil {
+		return maker, taker, fmt.Errorf("failed to parse %v into decimal: %w", f.TakerFee, err)
+	}
+	return maker, taker, nil
 }
@@ -0,0 +1,37 @@
+package g
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/L3Sota/arbo/arb/ratelimit"
+)
+
+// Limits are Gate's documented spot limits: 200 requests per 10s for public
+// and for private endpoints, and 10 order placements or cancellations per
+// second.
+var Limits = [ratelimit.ClassMax]ratelimit.Limit{
+	ratelimit.Public:  {N: 200, Per: 10 * time.Second},
+	ratelimit.Private: {N: 200, Per: 10 * time.Second},
+	ratelimit.Order:   {N: 10, Per: time.Second},
+}
+
+// Headers report the requests left on the endpoint called.
+var Headers = ratelimit.Headers{
+	Remaining: "X-Gate-RateLimit-Requests-Remain",
+	Reset:     "X-Gate-RateLimit-Reset-Timestamp",
+	Weight:    [ratelimit.ClassMax]float64{1, 1, 1},
+}
+
+// classify tells signed requests apart by the KEY header the client adds.
+func classify(r *http.Request) ratelimit.Class {
+	if r.Header.Get("KEY") == "" {
+		return ratelimit.Public
+	}
+	if strings.HasSuffix(r.URL.Path, "/spot/orders") && r.Method != http.MethodGet ||
+		strings.Contains(r.URL.Path, "/spot/orders/") && r.Method == http.MethodDelete {
+		return ratelimit.Order
+	}
+	return ratelimit.Private
+}
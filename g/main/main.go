@@ -4,29 +4,36 @@ import (
 	"fmt"
 
 	"github.com/L3Sota/arbo/arb/config"
+	"github.com/L3Sota/arbo/arb/model"
 	"github.com/L3Sota/arbo/g"
 )
 
 func main() {
-	g.LoadClient()
-	// balances()
-	// g.OrderTest(config.Load())
-	g.QueryFee(config.Load())
-	// book()
+	e := g.New("Ga", config.Load().Account("Ga"))
+	// balances(e)
+	// e.OrderTest()
+	fee(e)
+	// book(e)
 }
 
-func book() {
-	a, b, err := g.Book()
+func book(e *g.Exchange) {
+	a, b, err := e.Book(model.XCHUSDT)
 
 	fmt.Println(a)
 	fmt.Println(b)
 	fmt.Println(err)
 }
 
-func balances() {
-	b, err := g.Balances(config.Load())
+func fee(e *g.Exchange) {
+	maker, taker, err := e.QueryFee(model.XCHUSDT)
 
-	fmt.Println(b.USDT.String())
-	fmt.Println(b.XCH.String())
+	fmt.Println(maker, taker, err)
+}
+
+func balances(e *g.Exchange) {
+	b, err := e.Balances()
+
+	fmt.Println(b["USDT"].String())
+	fmt.Println(b["XCH"].String())
 	fmt.Println(err)
 }
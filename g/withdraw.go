@@ -0,0 +1,36 @@
+package g
+
+import (
+	"fmt"
+
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/gateio/gateapi-go/v6"
+	"github.com/shopspring/decimal"
+)
+
+// Chains are Gate's chain names by network.
+var Chains = map[string]string{
+	"CHIA":  "XCH",
+	"TRC20": "TRX",
+	"SOL":   "SOL",
+	"ARB":   "ARBEVM",
+}
+
+// Withdraw sends amount of currency to address over w's network.
+func (e *Exchange) Withdraw(w model.Withdrawal, currency, address string, amount decimal.Decimal) (string, error) {
+	chain, ok := Chains[w.Network]
+	if !ok {
+		return "", fmt.Errorf("no chain for network %v", w.Network)
+	}
+
+	r, _, err := e.client.WithdrawalApi.Withdraw(e.authContext(), gateapi.LedgerRecord{
+		Currency: currency,
+		Address:  address,
+		Amount:   amount.String(),
+		Chain:    chain,
+	})
+	if err != nil {
+		return "", err
+	}
+	return r.Id, nil
+}
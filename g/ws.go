@@ -0,0 +1,267 @@
+package g
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/L3Sota/arbo/arb/depth"
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/antihax/optional"
+	"github.com/gateio/gateapi-go/v6"
+	"github.com/gorilla/websocket"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	wsURL = "wss://api.gateio.ws/ws/v4/"
+
+	// restartAfter is how long a stream may stay quiet before it is reopened.
+	restartAfter = time.Minute
+	pingEvery    = 5 * time.Second // pongs confirm a quiet book
+)
+
+var errQuiet = errors.New("stream quiet")
+
+type wsRequest struct {
+	Time    int64    `json:"time"`
+	Channel string   `json:"channel"`
+	Event   string   `json:"event,omitempty"`
+	Payload []string `json:"payload,omitempty"`
+}
+
+type wsMessage struct {
+	Channel string `json:"channel"`
+	Event   string `json:"event"`
+	Error   *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+	Result json.RawMessage `json:"result"`
+}
+
+// bookUpdate is the result of a spot.order_book_update message, covering
+// order book IDs First through Last.
+type bookUpdate struct {
+	Symbol string     `json:"s"`
+	First  int64      `json:"U"`
+	Last   int64      `json:"u"`
+	Bids   [][]string `json:"b"`
+	Asks   [][]string `json:"a"`
+}
+
+// stream returns the local book for p, starting its stream on first use.
+func (e *Exchange) stream(p model.Pair) *depth.Book {
+	e.smu.Lock()
+	defer e.smu.Unlock()
+
+	if b, ok := e.books[p]; ok {
+		return b
+	}
+	b := depth.New()
+	e.books[p] = b
+	go e.watch(p, b)
+	return b
+}
+
+// watch keeps b in sync with the order book updates of p, reconnecting
+// whenever the stream fails.
+func (e *Exchange) watch(p model.Pair, b *depth.Book) {
+	for {
+		err := e.follow(context.Background(), p, b)
+		b.Reset()
+		fmt.Printf("%v %v order book stream: %v\n", e.id, p, err)
+		time.Sleep(time.Second)
+	}
+}
+
+// follow subscribes to spot.order_book_update for p, takes a REST snapshot
+// with its order book ID and applies updates on top of it until the
+// connection fails or ctx is done. An update that skips IDs triggers a new
+// snapshot.
+func (e *Exchange) follow(ctx context.Context, p model.Pair, b *depth.Book) error {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, e.wsURL, nil)
+	if err != nil {
+		return fmt.Errorf("dial: %w", err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteJSON(wsRequest{
+		Time:    time.Now().Unix(),
+		Channel: "spot.order_book_update",
+		Event:   "subscribe",
+		Payload: []string{Symbol(p), "100ms"},
+	}); err != nil {
+		return fmt.Errorf("subscribe: %w", err)
+	}
+
+	// read into a buffer so that updates sent while the snapshot is fetched
+	// are kept; a nil update is a pong
+	updates := make(chan *bookUpdate, 1024)
+	errc := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			var m wsMessage
+			if err := conn.ReadJSON(&m); err != nil {
+				errc <- err
+				return
+			}
+			if m.Error != nil {
+				errc <- fmt.Errorf("%v %v: [%d] %v", m.Channel, m.Event, m.Error.Code, m.Error.Message)
+				return
+			}
+			var u *bookUpdate
+			switch {
+			case m.Channel == "spot.order_book_update" && m.Event == "update":
+				u = &bookUpdate{}
+				if err := json.Unmarshal(m.Result, u); err != nil {
+					errc <- fmt.Errorf("read update: %w", err)
+					return
+				}
+			case m.Channel != "spot.pong":
+				continue
+			}
+			select {
+			case updates <- u:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	id, err := e.snapshot(ctx, p, b)
+	if err != nil {
+		return err
+	}
+
+	ping := time.NewTicker(pingEvery)
+	defer ping.Stop()
+	quiet := time.NewTimer(restartAfter)
+	defer quiet.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errc:
+			return err
+		case <-quiet.C:
+			return errQuiet
+		case <-ping.C:
+			if err := conn.WriteJSON(wsRequest{Time: time.Now().Unix(), Channel: "spot.ping"}); err != nil {
+				return fmt.Errorf("ping: %w", err)
+			}
+		case u := <-updates:
+			if !quiet.Stop() {
+				<-quiet.C
+			}
+			quiet.Reset(restartAfter)
+
+			if u == nil {
+				if b.Ready() {
+					b.Touch()
+				}
+				continue
+			}
+
+			next, ok, err := apply(b, id, u)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				fmt.Printf("%v %v order book gap: have %v, got %v-%v; resnapshotting\n", e.id, p, id, u.First, u.Last)
+				b.Reset()
+				if next, err = e.snapshot(ctx, p, b); err != nil {
+					return err
+				}
+			}
+			id = next
+		}
+	}
+}
+
+// snapshot replaces b with the REST book and returns its order book ID.
+func (e *Exchange) snapshot(ctx context.Context, p model.Pair, b *depth.Book) (int64, error) {
+	o, _, err := e.client.SpotApi.ListOrderBook(ctx, Symbol(p), &gateapi.ListOrderBookOpts{
+		Limit:  optional.NewInt32(100),
+		WithId: optional.NewBool(true),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("order book: %w", err)
+	}
+	asks, err := levels(o.Asks)
+	if err != nil {
+		return 0, err
+	}
+	bids, err := levels(o.Bids)
+	if err != nil {
+		return 0, err
+	}
+
+	b.Replace(asks, bids)
+	return o.Id, nil
+}
+
+// apply applies u to b if it follows on from order book ID id and returns
+// the new ID. It reports false, leaving b untouched, if IDs were skipped.
+// Updates that overlap id are applied whole, since amounts are absolute.
+func apply(b *depth.Book, id int64, u *bookUpdate) (int64, bool, error) {
+	if u.Last <= id {
+		return id, true, nil
+	}
+	if u.First > id+1 {
+		return id, false, nil
+	}
+
+	asks, err := levels(u.Asks)
+	if err != nil {
+		return id, true, err
+	}
+	bids, err := levels(u.Bids)
+	if err != nil {
+		return id, true, err
+	}
+
+	b.Apply(asks, bids)
+	return u.Last, true, nil
+}
+
+// levels parses [price, amount] rows.
+func levels(rows [][]string) ([]depth.Level, error) {
+	ls := make([]depth.Level, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 2 {
+			return nil, fmt.Errorf("short level %v", row)
+		}
+		p, err := decimal.NewFromString(row[0])
+		if err != nil {
+			return nil, fmt.Errorf("tried to parse %v, got err: %w", row[0], err)
+		}
+		amt, err := decimal.NewFromString(row[1])
+		if err != nil {
+			return nil, fmt.Errorf("tried to parse %v, got err: %w", row[1], err)
+		}
+		ls = append(ls, depth.Level{Price: p, Amount: amt})
+	}
+	return ls, nil
+}
+
+// Watch returns a channel that receives whenever the best bid or ask of p
+// changes.
+func (e *Exchange) Watch(p model.Pair) <-chan struct{} {
+	return e.stream(p).Watch()
+}
+
+// Age returns how long ago the streamed book for p was last confirmed in
+// sync.
+func (e *Exchange) Age(p model.Pair) time.Duration {
+	return e.stream(p).Age()
+}
+
+// Streaming reports whether Book serves p from the stream.
+func (e *Exchange) Streaming(p model.Pair) bool {
+	return e.stream(p).Ready()
+}
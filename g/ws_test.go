@@ -0,0 +1,150 @@
+package g
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/L3Sota/arbo/arb/config"
+	"github.com/L3Sota/arbo/arb/depth"
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/gorilla/websocket"
+	"github.com/shopspring/decimal"
+)
+
+// TestFollowReplay replays recorded order book updates from a local server.
+// The first phase is applied on top of the first snapshot; the second starts
+// with a gap, so the book must be resnapshotted before the last update.
+func TestFollowReplay(t *testing.T) {
+	t.Parallel()
+
+	recorded, err := os.ReadFile("testdata/order_book_update.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	phases := bytes.Split(recorded, []byte("\n\n"))
+
+	snapshots := []string{
+		`{"id":100,"current":1705482626000,"update":1705482625990,"asks":[["30.1","3"],["30.3","2"]],"bids":[["29.9","1"],["29.8","5"]]}`,
+		`{"id":111,"current":1705482627000,"update":1705482626990,"asks":[["30.4","7"]],"bids":[["29.7","4"],["29.6","3"]]}`,
+	}
+	var calls atomic.Int32
+	next := make(chan struct{})
+
+	upgrader := websocket.Upgrader{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/spot/order_book", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("with_id") != "true" {
+			t.Errorf("snapshot without with_id: %v", r.URL)
+		}
+		i := int(calls.Add(1)) - 1
+		if i >= len(snapshots) {
+			i = len(snapshots) - 1
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, snapshots[i])
+	})
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		var req wsRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			t.Error(err)
+			return
+		}
+		if req.Channel != "spot.order_book_update" || req.Event != "subscribe" || len(req.Payload) == 0 || req.Payload[0] != "XCH_USDT" {
+			t.Errorf("unexpected subscription: %+v", req)
+		}
+
+		for i, phase := range phases {
+			if i > 0 {
+				<-next
+			}
+			s := bufio.NewScanner(bytes.NewReader(phase))
+			for s.Scan() {
+				if len(bytes.TrimSpace(s.Bytes())) == 0 {
+					continue
+				}
+				if err := conn.WriteMessage(websocket.TextMessage, s.Bytes()); err != nil {
+					return
+				}
+			}
+		}
+
+		// hold the connection open until the client goes away
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	e := New("Ga", config.Account{})
+	e.client.ChangeBasePath(srv.URL)
+	e.wsURL = "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := depth.New()
+	errc := make(chan error, 1)
+	go func() { errc <- e.follow(ctx, model.XCHUSDT, b) }()
+
+	waitFor := func(want string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		var got string
+		for time.Now().Before(deadline) {
+			got = render(b)
+			if got == want {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("book: want %v, got %v", want, got)
+	}
+
+	waitFor("a 30.2x1.25 30.3x2 | b 29.9x2.5")
+	close(next)
+	waitFor("a 30.4x7 | b 29.95x1 29.7x4 29.6x3")
+
+	if n := calls.Load(); n != 2 {
+		t.Errorf("snapshots: want 2, got %v", n)
+	}
+
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("follow: want %v, got %v", context.Canceled, err)
+	}
+}
+
+func render(b *depth.Book) string {
+	if !b.Ready() {
+		return "not ready"
+	}
+	one := decimal.NewFromInt(1)
+	a, bs := b.Orders("Ga", one, one)
+	parts := []string{"a"}
+	for _, o := range a {
+		parts = append(parts, o.Price.String()+"x"+o.Amount.String())
+	}
+	parts = append(parts, "| b")
+	for _, o := range bs {
+		parts = append(parts, o.Price.String()+"x"+o.Amount.String())
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,86 @@
+package h
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/L3Sota/arbo/arb/ratelimit"
+	"github.com/shopspring/decimal"
+)
+
+func (e *Exchange) Venue() model.Venue {
+	return model.Venue{
+		ID:       e.id,
+		Exchange: model.ExchangeTypeHu,
+		Fees:     e.fees.Fees(),
+		PairFees: e.fees.All(),
+		Rules:    e.rules.All(),
+		Deposits: Deposits,
+	}
+}
+
+func (e *Exchange) GetOrder(p model.Pair, id string) (s model.OrderStatus, err error) {
+	o, err := e.Order(id)
+	if err != nil {
+		return s, err
+	}
+	if o.Status != "ok" || o.Data == nil {
+		return s, fmt.Errorf("response status %v, error code %v, msg %v", o.Status, o.ErrorCode, o.ErrorMessage)
+	}
+
+	filled, err := decimal.NewFromString(o.Data.FilledAmount)
+	if err != nil {
+		return s, fmt.Errorf("failed to parse %v into decimal: %w", o.Data.FilledAmount, err)
+	}
+	funds, err := decimal.NewFromString(o.Data.FilledCashAmount)
+	if err != nil {
+		return s, fmt.Errorf("failed to parse %v into decimal: %w", o.Data.FilledCashAmount, err)
+	}
+	fee, err := decimal.NewFromString(o.Data.FilledFees)
+	if err != nil {
+		return s, fmt.Errorf("failed to parse %v into decimal: %w", o.Data.FilledFees, err)
+	}
+
+	// buys are charged in the base currency, sells in the quote currency
+	feeCurrency := p.Quote
+	if o.Data.Type == "buy-limit" {
+		feeCurrency = p.Base
+	}
+
+	return model.OrderStatus{
+		ID:          strconv.FormatInt(o.Data.Id, 10),
+		Filled:      filled,
+		FilledFunds: funds,
+		Fee:         fee,
+		FeeCurrency: feeCurrency,
+		Active:      o.Data.State == "submitted" || o.Data.State == "partial-filled" || o.Data.State == "created",
+		State:       o.Data.State,
+	}, nil
+}
+
+func (e *Exchange) Cancel(p model.Pair, id string) error {
+	if err := e.wait(ratelimit.Order); err != nil {
+		return err
+	}
+	resp, err := e.oc.CancelOrderById(id)
+	if err != nil {
+		return err
+	}
+	if resp.Status != "ok" {
+		return fmt.Errorf("response status %v, error code %v, msg %v", resp.Status, resp.ErrorCode, resp.ErrorMessage)
+	}
+	return nil
+}
+
+func (e *Exchange) Limiter() *ratelimit.Limiter {
+	return e.limiter
+}
+
+func (e *Exchange) SetFees(p model.Pair, maker, taker decimal.Decimal) {
+	e.fees.Set(p, maker, taker)
+}
+
+func (e *Exchange) SetRules(p model.Pair, r model.Rules) {
+	e.rules.Set(p, r)
+}
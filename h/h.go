@@ -2,116 +2,177 @@ package h
 
 import (
 	"fmt"
-	"log/slog"
 	"strconv"
+	"strings"
+	"sync"
 
 	arboconfig "github.com/L3Sota/arbo/arb/config"
+	"github.com/L3Sota/arbo/arb/depth"
+	"github.com/L3Sota/arbo/arb/fees"
 	"github.com/L3Sota/arbo/arb/model"
+	"github.com/L3Sota/arbo/arb/ratelimit"
+	"github.com/L3Sota/arbo/arb/rules"
 	"github.com/huobirdcenter/huobi_golang/config"
 	"github.com/huobirdcenter/huobi_golang/pkg/client"
+	huobimodel "github.com/huobirdcenter/huobi_golang/pkg/model"
 	"github.com/huobirdcenter/huobi_golang/pkg/model/market"
 	"github.com/huobirdcenter/huobi_golang/pkg/model/order"
-	"github.com/linstohu/nexapi/htx/spot/marketws"
 	"github.com/shopspring/decimal"
 )
 
 var (
 	Fees = model.Fees{
-		MakerTakerRatio:    decimal.RequireFromString("0.0017"), // 0.17%
-		WithdrawalFlatXCH:  decimal.RequireFromString("0.0005"),
-		WithdrawalFlatUSDT: decimal.NewFromInt(1), // TRC20
+		Maker: decimal.RequireFromString("0.0017"), // 0.17%
+		Taker: decimal.RequireFromString("0.0017"), // 0.17%
+		Withdrawals: map[string][]model.Withdrawal{
+			"XCH":  {{Network: "CHIA", Fee: decimal.RequireFromString("0.0005")}},
+			"USDT": {{Network: "TRC20", Fee: decimal.NewFromInt(1)}},
+		},
 	}
-	AskAddition  = decimal.NewFromInt(1).Add(Fees.MakerTakerRatio)
-	BidReduction = decimal.NewFromInt(1).Sub(Fees.MakerTakerRatio)
+	Deposits = map[string][]string{
+		"XCH":  {"CHIA"},
+		"USDT": {"TRC20", "SOL"},
+	}
+	Rules = map[model.Pair]model.Rules{
+		model.XCHUSDT: {
+			MinQuote: decimal.NewFromInt(10),
+		},
+	}
+)
+
+type Exchange struct {
+	id model.VenueID
 
 	mc *client.MarketClient
+	cc *client.CommonClient
 	ac *client.AccountClient
 	oc *client.OrderClient
+	wc *client.WalletClient
 
 	accountID string
-)
+	limiter   *ratelimit.Limiter
+
+	smu   sync.Mutex
+	books map[model.Pair]*depth.Book
+
+	fees  *fees.Schedule
+	rules *rules.Table
+}
+
+func New(id model.VenueID, a arboconfig.Account) *Exchange {
+	return &Exchange{
+		id: id,
+		mc: new(client.MarketClient).Init(config.Host),
+		cc: new(client.CommonClient).Init(config.Host),
+		ac: new(client.AccountClient).Init(a.Key, a.Sec, config.Host),
+		oc: new(client.OrderClient).Init(a.Key, a.Sec, config.Host),
+		wc: new(client.WalletClient).Init(a.Key, a.Sec, config.Host),
+
+		limiter: ratelimit.New(Limits, ratelimit.Headers{}),
+
+		books: map[model.Pair]*depth.Book{},
+		fees:  fees.New(Fees),
+		rules: rules.New(Rules),
+	}
+}
+
+// Symbol returns the HTX symbol for p, e.g. xchusdt.
+func Symbol(p model.Pair) string {
+	return strings.ToLower(p.Base + p.Quote)
+}
 
-func LoadClient(conf *arboconfig.Config) {
-	mc = new(client.MarketClient).Init(config.Host)
-	ac = new(client.AccountClient).Init(conf.HKey, conf.HSec, config.Host)
-	oc = new(client.OrderClient).Init(conf.HKey, conf.HSec, config.Host)
+// Book serves the streamed book for p, falling back to REST until the stream
+// has a snapshot.
+func (e *Exchange) Book(p model.Pair) ([]model.Order, []model.Order, error) {
+	if s := e.stream(p); s.Ready() {
+		askAddition, bidReduction := e.fees.Ratios(p, false)
+		a, b := s.Orders(e.id, askAddition, bidReduction)
+		return a, b, nil
+	}
+	return e.restBook(p)
 }
 
-func Book() ([]model.Order, []model.Order, error) {
-	o, err := mc.GetDepth("xchusdt", "step0", market.GetDepthOptionalRequest{})
+func (e *Exchange) restBook(p model.Pair) ([]model.Order, []model.Order, error) {
+	if err := e.wait(ratelimit.Public); err != nil {
+		return nil, nil, err
+	}
+	o, err := e.mc.GetDepth(Symbol(p), "step0", market.GetDepthOptionalRequest{})
 	if err != nil {
 		return nil, nil, fmt.Errorf("depth: %w", err)
 	}
 
+	askAddition, bidReduction := e.fees.Ratios(p, false)
 	a := make([]model.Order, 0, len(o.Asks))
 	for _, ask := range o.Asks {
 		o := model.Order{
-			Ex:     model.ExchangeTypeHu,
+			Venue:  e.id,
 			Price:  ask[0],
 			Amount: ask[1],
 		}
-		o.EffectivePrice = o.Price.Mul(AskAddition)
+		o.EffectivePrice = o.Price.Mul(askAddition)
 		a = append(a, o)
 	}
 	b := make([]model.Order, 0, len(o.Bids))
 	for _, bid := range o.Bids {
 		o := model.Order{
-			Ex:     model.ExchangeTypeHu,
+			Venue:  e.id,
 			Price:  bid[0],
 			Amount: bid[1],
 		}
-		o.EffectivePrice = o.Price.Mul(BidReduction)
+		o.EffectivePrice = o.Price.Mul(bidReduction)
 		b = append(b, o)
 	}
 
 	return a, b, nil
 }
 
-func Balances() (b model.Balances, err error) {
-	accs, err := ac.GetAccountInfo()
+func (e *Exchange) Balances() (b model.Balances, err error) {
+	if err := e.wait(ratelimit.Private); err != nil {
+		return b, err
+	}
+	accs, err := e.ac.GetAccountInfo()
 	if err != nil {
 		return b, err
 	}
 	for _, a := range accs {
 		if a.Type == "spot" {
-			accountID = strconv.FormatInt(a.Id, 10)
+			e.accountID = strconv.FormatInt(a.Id, 10)
 		}
 	}
 
-	a, err := ac.GetAccountBalance(accountID)
+	if err := e.wait(ratelimit.Private); err != nil {
+		return b, err
+	}
+	a, err := e.ac.GetAccountBalance(e.accountID)
 	if err != nil {
 		return b, err
 	}
 
+	b = make(model.Balances)
 	for _, aa := range a.List {
 		if aa.Type == "trade" {
-			switch aa.Currency {
-			case "usdt":
-				usdt, err := decimal.NewFromString(aa.Balance)
-				if err != nil {
-					return b, fmt.Errorf("failed to parse %v into decimal: %w", aa.Balance, err)
-				}
-				b.USDT = usdt
-			case "xch":
-				xch, err := decimal.NewFromString(aa.Balance)
-				if err != nil {
-					return b, fmt.Errorf("failed to parse %v into decimal: %w", aa.Balance, err)
-				}
-				b.XCH = xch
+			bal, err := decimal.NewFromString(aa.Balance)
+			if err != nil {
+				return b, fmt.Errorf("failed to parse %v into decimal: %w", aa.Balance, err)
 			}
+			b[strings.ToUpper(aa.Currency)] = bal
 		}
 	}
 
 	return b, nil
 }
 
-func Buy(price, size decimal.Decimal) (string, error) {
-	resp, err := oc.PlaceOrder(&order.PlaceOrderRequest{
-		AccountId: accountID,
-		Symbol:    "xchusdt",
+func (e *Exchange) Buy(p model.Pair, price, size decimal.Decimal) (string, error) {
+	r := e.rules.Get(p)
+	if err := e.wait(ratelimit.Order); err != nil {
+		return "", err
+	}
+	resp, err := e.oc.PlaceOrder(&order.PlaceOrderRequest{
+		AccountId: e.accountID,
+		Symbol:    Symbol(p),
 		Type:      "buy-limit",
-		Amount:    size.RoundDown(4).String(),
-		Price:     price.String(),
+		Amount:    r.RoundSize(size).String(),
+		Price:     r.RoundPrice(price, true).String(),
 		Source:    "spot-api",
 	})
 	if err != nil {
@@ -123,13 +184,17 @@ func Buy(price, size decimal.Decimal) (string, error) {
 	return resp.Data, nil
 }
 
-func Sell(price, size decimal.Decimal) (string, error) {
-	resp, err := oc.PlaceOrder(&order.PlaceOrderRequest{
-		AccountId: accountID,
-		Symbol:    "xchusdt",
+func (e *Exchange) Sell(p model.Pair, price, size decimal.Decimal) (string, error) {
+	r := e.rules.Get(p)
+	if err := e.wait(ratelimit.Order); err != nil {
+		return "", err
+	}
+	resp, err := e.oc.PlaceOrder(&order.PlaceOrderRequest{
+		AccountId: e.accountID,
+		Symbol:    Symbol(p),
 		Type:      "sell-limit",
-		Amount:    size.RoundDown(4).String(),
-		Price:     price.String(),
+		Amount:    r.RoundSize(size).String(),
+		Price:     r.RoundPrice(price, false).String(),
 		Source:    "spot-api",
 	})
 	if err != nil {
@@ -141,75 +206,80 @@ func Sell(price, size decimal.Decimal) (string, error) {
 	return resp.Data, nil
 }
 
-func GetOrder(id string) (*order.GetOrderResponse, error) {
-	resp, err := oc.GetOrderById(id)
+func (e *Exchange) Order(id string) (*order.GetOrderResponse, error) {
+	if err := e.wait(ratelimit.Private); err != nil {
+		return nil, err
+	}
+	resp, err := e.oc.GetOrderById(id)
 	if err != nil {
 		return nil, err
 	}
 	return resp, nil
 }
 
-func OrderTest() {
-	id, err := Buy(decimal.NewFromInt(20), decimal.RequireFromString("0.1"))
-	if err != nil {
-		fmt.Println(err)
-		return
+// QueryRules returns the order rules of p.
+func (e *Exchange) QueryRules(p model.Pair) (r model.Rules, err error) {
+	if err := e.wait(ratelimit.Public); err != nil {
+		return r, err
 	}
-	resp, err := oc.GetOrderById(id)
+	ss, err := e.cc.GetSymbols()
 	if err != nil {
-		fmt.Println(err)
-		return
+		return r, err
 	}
-	fmt.Printf("%+v\n%+v\n", resp, *(resp.Data))
-}
 
-func WSTest() {
-	// c := &client.MarketClient{}
-	// c.Init(config.Host)
-
-	// d, err := c.GetDepth("xchusdt", "step0", market.GetDepthOptionalRequest{})
-	// if err != nil {
-	// 	fmt.Print(err)
-	// 	return
-	// }
-
-	// fmt.Printf("d: %v\n", d)
-
-	nex, err := marketws.NewMarketWsClient(&marketws.MarketWsClientCfg{
-		BaseURL:       "wss://api.huobi.pro/ws",
-		AutoReconnect: true,
-		Logger:        slog.Default(),
-	})
+	for _, s := range ss {
+		if s.Symbol != Symbol(p) {
+			continue
+		}
+		return model.Rules{
+			MinBase:        s.LimitOrderMinOrderAmt,
+			MinQuote:       s.MinOrderValue,
+			BaseIncrement:  model.Increment(int32(s.AmountPrecision)),
+			PriceIncrement: model.Increment(int32(s.PricePrecision)),
+		}, nil
+	}
+	return r, fmt.Errorf("no symbol %v", Symbol(p))
+}
 
+// QueryFee returns the fee ratios charged to the account on p.
+func (e *Exchange) QueryFee(p model.Pair) (maker, taker decimal.Decimal, err error) {
+	if err := e.wait(ratelimit.Private); err != nil {
+		return maker, taker, err
+	}
+	resp, err := e.oc.GetTransactFeeRate(new(huobimodel.GetRequest).Init().AddParam("symbols", Symbol(p)))
 	if err != nil {
-		fmt.Print(err)
-		return
+		return maker, taker, err
+	}
+	if resp.Code != 200 {
+		return maker, taker, fmt.Errorf("error code %v, msg %v", resp.Code, resp.Message)
+	}
+	if len(resp.Data) == 0 {
+		return maker, taker, fmt.Errorf("no fee for %v", Symbol(p))
 	}
-	s, err := nex.GetDepthTopic(&marketws.DepthTopicParam{
-		Symbol: "xchusdt",
-		Type:   "step0",
-	})
 
+	// the actual rates include any deductions
+	f := resp.Data[0]
+	maker, err = decimal.NewFromString(f.ActualMakerRate)
 	if err != nil {
-		fmt.Print(err)
-		return
+		return maker, taker, fmt.Errorf("failed to parse %v into decimal: %w", f.ActualMakerRate, err)
 	}
-
-	fmt.Print(s)
-
-	if err := nex.Open(); err != nil {
-		fmt.Print(err)
-		return
+	taker, err = decimal.NewFromString(f.ActualTakerRate)
+	if err != nil {
+		return maker, taker, fmt.Errorf("failed to parse %v into decimal: %w", f.ActualTakerRate, err)
 	}
-	if err := nex.Subscribe(s); err != nil {
-		fmt.Print(err)
+	return maker, taker, nil
+}
+
+func (e *Exchange) OrderTest() {
+	id, err := e.Buy(model.XCHUSDT, decimal.NewFromInt(20), decimal.RequireFromString("0.1"))
+	if err != nil {
+		fmt.Println(err)
 		return
 	}
-
-	if err := nex.Close(); err != nil {
-		fmt.Print(err)
+	resp, err := e.oc.GetOrderById(id)
+	if err != nil {
+		fmt.Println(err)
 		return
 	}
-
-	fmt.Println("done")
+	fmt.Printf("%+v\n%+v\n", resp, *(resp.Data))
 }
@@ -0,0 +1,24 @@
+package h
+
+import (
+	"context"
+	"time"
+
+	"github.com/L3Sota/arbo/arb/ratelimit"
+)
+
+// Limits are HTX's documented limits: 100 requests per 10s per IP for market
+// data, 100 per 2s per UID for account queries and 50 per 2s for placing and
+// cancelling orders.
+var Limits = [ratelimit.ClassMax]ratelimit.Limit{
+	ratelimit.Public:  {N: 100, Per: 10 * time.Second},
+	ratelimit.Private: {N: 100, Per: 2 * time.Second},
+	ratelimit.Order:   {N: 50, Per: 2 * time.Second},
+}
+
+// wait takes a token of class c. The SDK makes its requests with the default
+// HTTP client, so calls are limited here rather than in a transport and the
+// budget headers are not observed.
+func (e *Exchange) wait(c ratelimit.Class) error {
+	return e.limiter.Wait(context.Background(), c)
+}
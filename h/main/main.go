@@ -4,28 +4,29 @@ import (
 	"fmt"
 
 	"github.com/L3Sota/arbo/arb/config"
+	"github.com/L3Sota/arbo/arb/model"
 	"github.com/L3Sota/arbo/h"
 )
 
 func main() {
-	h.LoadClient(config.Load())
-	book()
-	balances()
-	h.OrderTest()
+	e := h.New("Hu", config.Load().Account("Hu"))
+	book(e)
+	balances(e)
+	e.OrderTest()
 }
 
-func book() {
-	a, b, err := h.Book()
+func book(e *h.Exchange) {
+	a, b, err := e.Book(model.XCHUSDT)
 
 	fmt.Println(a)
 	fmt.Println(b)
 	fmt.Println(err)
 }
 
-func balances() {
-	b, err := h.Balances()
+func balances(e *h.Exchange) {
+	b, err := e.Balances()
 
-	fmt.Println(b.USDT.String())
-	fmt.Println(b.XCH.String())
+	fmt.Println(b["USDT"].String())
+	fmt.Println(b["XCH"].String())
 	fmt.Println(err)
 }
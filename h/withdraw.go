@@ -0,0 +1,43 @@
+package h
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/L3Sota/arbo/arb/ratelimit"
+	"github.com/huobirdcenter/huobi_golang/pkg/model/wallet"
+	"github.com/shopspring/decimal"
+)
+
+// Chains are HTX's chain names by network, for USDT and XCH.
+var Chains = map[string]string{
+	"CHIA":  "xch",
+	"TRC20": "trc20usdt",
+	"SOL":   "solusdt",
+}
+
+// Withdraw sends amount of currency to address over w's network. HTX wants
+// the fee stated with the request.
+func (e *Exchange) Withdraw(w model.Withdrawal, currency, address string, amount decimal.Decimal) (string, error) {
+	chain, ok := Chains[w.Network]
+	if !ok {
+		return "", fmt.Errorf("no chain for network %v", w.Network)
+	}
+
+	if err := e.wait(ratelimit.Private); err != nil {
+		return "", err
+	}
+	id, err := e.wc.CreateWithdraw(wallet.CreateWithdrawRequest{
+		Address:  address,
+		Amount:   amount.String(),
+		Currency: strings.ToLower(currency),
+		Fee:      w.Fee.String(),
+		Chain:    chain,
+	})
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(id, 10), nil
+}
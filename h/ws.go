@@ -0,0 +1,140 @@
+package h
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/L3Sota/arbo/arb/depth"
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/linstohu/nexapi/htx/spot/marketws"
+	"github.com/linstohu/nexapi/htx/spot/marketws/types"
+	"github.com/shopspring/decimal"
+)
+
+// restartAfter is how long a depth stream may stay quiet before it is
+// reopened. The client's own reconnect does not notice a dead read loop.
+const restartAfter = time.Minute
+
+// stream returns the local book for p, starting its stream on first use.
+func (e *Exchange) stream(p model.Pair) *depth.Book {
+	e.smu.Lock()
+	defer e.smu.Unlock()
+
+	if b, ok := e.books[p]; ok {
+		return b
+	}
+	b := depth.New()
+	e.books[p] = b
+	go e.watch(p, b)
+	return b
+}
+
+// watch keeps b in sync with the depth topic of p, reopening the connection
+// whenever it goes quiet.
+func (e *Exchange) watch(p model.Pair, b *depth.Book) {
+	for {
+		c, err := subscribe(p, b)
+		if err != nil {
+			fmt.Printf("%v %v depth stream: %v\n", e.id, p, err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		opened := time.Now()
+		for {
+			time.Sleep(restartAfter / 4)
+			last := b.Updated()
+			if last.Before(opened) {
+				last = opened
+			}
+			if time.Since(last) > restartAfter {
+				break
+			}
+		}
+
+		fmt.Printf("%v %v depth stream quiet since %v, reopening\n", e.id, p, b.Updated())
+		b.Reset()
+		if err := c.Close(); err != nil {
+			fmt.Printf("%v %v depth stream close: %v\n", e.id, p, err)
+		}
+	}
+}
+
+// subscribe opens a market client streaming the step0 depth of p into b. HTX
+// pushes the full book every second, so each message replaces b, or confirms
+// it when the version has not moved. The client answers pings internally, so
+// these snapshots are the stream's heartbeat.
+func subscribe(p model.Pair, b *depth.Book) (*marketws.MarketWsClient, error) {
+	c, err := marketws.NewMarketWsClient(&marketws.MarketWsClientCfg{
+		BaseURL:       marketws.GlobalWsBaseURL,
+		AutoReconnect: true,
+		Logger:        slog.Default(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	topic, err := c.GetDepthTopic(&marketws.DepthTopicParam{
+		Symbol: Symbol(p),
+		Type:   "step0",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var version int64
+	c.AddListener(topic, func(v any) {
+		d, ok := v.(*types.Depth)
+		if !ok {
+			return
+		}
+		if d.Version == version && b.Ready() {
+			b.Touch()
+			return
+		}
+		version = d.Version
+		b.Replace(levels(d.Asks), levels(d.Bids))
+	})
+
+	if err := c.Open(); err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+	if err := c.Subscribe(topic); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("subscribe %v: %w", topic, err)
+	}
+
+	return c, nil
+}
+
+func levels(ls [][]float64) []depth.Level {
+	ds := make([]depth.Level, 0, len(ls))
+	for _, l := range ls {
+		if len(l) < 2 {
+			continue
+		}
+		ds = append(ds, depth.Level{
+			Price:  decimal.NewFromFloat(l[0]),
+			Amount: decimal.NewFromFloat(l[1]),
+		})
+	}
+	return ds
+}
+
+// Watch returns a channel that receives whenever the best bid or ask of p
+// changes.
+func (e *Exchange) Watch(p model.Pair) <-chan struct{} {
+	return e.stream(p).Watch()
+}
+
+// Age returns how long ago the streamed book for p was last confirmed in
+// sync.
+func (e *Exchange) Age(p model.Pair) time.Duration {
+	return e.stream(p).Age()
+}
+
+// Streaming reports whether Book serves p from the stream.
+func (e *Exchange) Streaming(p model.Pair) bool {
+	return e.stream(p).Ready()
+}
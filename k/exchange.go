@@ -0,0 +1,78 @@
+package k
+
+import (
+	"fmt"
+
+	"github.com/Kucoin/kucoin-go-sdk"
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/L3Sota/arbo/arb/ratelimit"
+	"github.com/shopspring/decimal"
+)
+
+func (e *Exchange) Venue() model.Venue {
+	return model.Venue{
+		ID:       e.id,
+		Exchange: model.ExchangeTypeKu,
+		Fees:     e.fees.Fees(),
+		PairFees: e.fees.All(),
+		Rules:    e.rules.All(),
+		Deposits: Deposits,
+	}
+}
+
+func (e *Exchange) GetOrder(p model.Pair, id string) (s model.OrderStatus, err error) {
+	o, err := e.Order(id)
+	if err != nil {
+		return s, err
+	}
+
+	filled, err := decimal.NewFromString(o.DealSize)
+	if err != nil {
+		return s, fmt.Errorf("failed to parse %v into decimal: %w", o.DealSize, err)
+	}
+	funds, err := decimal.NewFromString(o.DealFunds)
+	if err != nil {
+		return s, fmt.Errorf("failed to parse %v into decimal: %w", o.DealFunds, err)
+	}
+	fee, err := decimal.NewFromString(o.Fee)
+	if err != nil {
+		return s, fmt.Errorf("failed to parse %v into decimal: %w", o.Fee, err)
+	}
+
+	state := "done"
+	if o.IsActive {
+		state = "active"
+	}
+
+	return model.OrderStatus{
+		ID:          o.Id,
+		Filled:      filled,
+		FilledFunds: funds,
+		Fee:         fee,
+		FeeCurrency: o.FeeCurrency,
+		Active:      o.IsActive,
+		State:       state,
+	}, nil
+}
+
+func (e *Exchange) Cancel(p model.Pair, id string) error {
+	resp, err := e.apiService.CancelOrder(id)
+	if err != nil {
+		return err
+	}
+
+	var r kucoin.CancelOrderResultModel
+	return resp.ReadData(&r)
+}
+
+func (e *Exchange) Limiter() *ratelimit.Limiter {
+	return e.limiter
+}
+
+func (e *Exchange) SetFees(p model.Pair, maker, taker decimal.Decimal) {
+	e.fees.Set(p, maker, taker)
+}
+
+func (e *Exchange) SetRules(p model.Pair, r model.Rules) {
+	e.rules.Set(p, r)
+}
@@ -2,91 +2,197 @@ package k
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/Kucoin/kucoin-go-sdk"
 	"github.com/L3Sota/arbo/arb/config"
+	"github.com/L3Sota/arbo/arb/depth"
+	"github.com/L3Sota/arbo/arb/fees"
 	"github.com/L3Sota/arbo/arb/model"
+	"github.com/L3Sota/arbo/arb/ratelimit"
+	"github.com/L3Sota/arbo/arb/rules"
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 )
 
 var (
 	Fees = model.Fees{
-		MakerTakerRatio:    decimal.RequireFromString("0.001"), // 0.1%
-		WithdrawalFlatXCH:  decimal.RequireFromString("0.132"),
-		WithdrawalFlatUSDT: decimal.RequireFromString("0.8"), // SOL
+		Maker: decimal.RequireFromString("0.001"), // 0.1%
+		Taker: decimal.RequireFromString("0.001"), // 0.1%
+		Withdrawals: map[string][]model.Withdrawal{
+			"XCH":  {{Network: "CHIA", Fee: decimal.RequireFromString("0.132"), OnTop: true}},
+			"USDT": {{Network: "SOL", Fee: decimal.RequireFromString("0.8"), OnTop: true}},
+		},
 	}
-	AskAddition  = decimal.NewFromInt(1).Add(Fees.MakerTakerRatio)
-	BidReduction = decimal.NewFromInt(1).Sub(Fees.MakerTakerRatio)
+	Deposits = map[string][]string{
+		"XCH":  {"CHIA"},
+		"USDT": {"TRC20", "SOL", "ARB"},
+	}
+	Rules = map[model.Pair]model.Rules{
+		model.XCHUSDT: {
+			MinBase:        decimal.RequireFromString("0.001"),
+			MinQuote:       decimal.RequireFromString("0.1"),
+			BaseIncrement:  decimal.RequireFromString("0.0001"),
+			PriceIncrement: decimal.RequireFromString("0.001"),
+		},
+	}
+)
 
+type Exchange struct {
+	id         model.VenueID
 	apiService *kucoin.ApiService
 	public     *kucoin.ApiService
-)
+	authed     bool // has credentials for private endpoints
+	limiter    *ratelimit.Limiter
+
+	smu   sync.Mutex
+	books map[model.Pair]*depth.Book
 
-func LoadClient(c *config.Config) {
-	apiService = kucoin.NewApiService(
-		kucoin.ApiKeyOption(c.KKey),
-		kucoin.ApiKeyVersionOption(kucoin.ApiKeyVersionV2),
-		kucoin.ApiPassPhraseOption(c.KPass),
-		kucoin.ApiSecretOption(c.KSec),
-	)
+	fees  *fees.Schedule
+	rules *rules.Table
+}
 
-	public = kucoin.NewApiService()
+func New(id model.VenueID, a config.Account) *Exchange {
+	l := ratelimit.New(Limits, Headers)
+	return &Exchange{
+		id: id,
+		apiService: kucoin.NewApiService(
+			kucoin.ApiKeyOption(a.Key),
+			kucoin.ApiKeyVersionOption(kucoin.ApiKeyVersionV2),
+			kucoin.ApiPassPhraseOption(a.Pass),
+			kucoin.ApiSecretOption(a.Sec),
+			kucoin.ApiRequesterOption(&requester{limiter: l, private: true}),
+		),
+		public:  kucoin.NewApiService(kucoin.ApiRequesterOption(&requester{limiter: l})),
+		authed:  a.Key != "",
+		limiter: l,
+
+		books: map[model.Pair]*depth.Book{},
+		fees:  fees.New(Fees),
+		rules: rules.New(Rules),
+	}
 }
 
-func Book() ([]model.Order, []model.Order, error) {
-	resp, err := public.AggregatedPartOrderBook("XCH-USDT", 100)
+// Symbol returns the KuCoin symbol for p, e.g. XCH-USDT.
+func Symbol(p model.Pair) string {
+	return p.Base + "-" + p.Quote
+}
+
+// partDepth is the number of levels of each side the public snapshot holds.
+const partDepth = 100
+
+// Book serves the streamed level-2 book for p, falling back to REST until
+// the stream is in sync. Without credentials only the top partDepth levels
+// of the stream are served; see snapshot.
+func (e *Exchange) Book(p model.Pair) ([]model.Order, []model.Order, error) {
+	if s := e.stream(p); s.Ready() {
+		askAddition, bidReduction := e.fees.Ratios(p, false)
+		a, b := s.Orders(e.id, askAddition, bidReduction)
+		if !e.authed {
+			a, b = top(a), top(b)
+		}
+		return a, b, nil
+	}
+	return e.restBook(p)
+}
+
+// top returns the first partDepth orders of os.
+func top(os []model.Order) []model.Order {
+	if len(os) > partDepth {
+		return os[:partDepth]
+	}
+	return os
+}
+
+func (e *Exchange) partBook(p model.Pair) (o kucoin.PartOrderBookModel, err error) {
+	resp, err := e.public.AggregatedPartOrderBook(Symbol(p), partDepth)
 	if err != nil {
-		return nil, nil, fmt.Errorf("order book: %w", err)
+		return o, fmt.Errorf("order book: %w", err)
 	}
 
-	var o kucoin.PartOrderBookModel
 	if err := resp.ReadData(&o); err != nil {
-		return nil, nil, fmt.Errorf("read data: %w; resp: %+v", err, resp)
+		return o, fmt.Errorf("read data: %w; resp: %+v", err, resp)
 	}
 
-	a := make([]model.Order, 0, len(o.Asks))
-	for _, ask := range o.Asks {
-		p, err := decimal.NewFromString(ask[0])
-		if err != nil {
-			return nil, nil, fmt.Errorf("tried to parse %v, got err: %w", ask[0], err)
-		}
-		amt, err := decimal.NewFromString(ask[1])
-		if err != nil {
-			return nil, nil, fmt.Errorf("tried to parse %v, got err: %w", ask[1], err)
-		}
+	return o, nil
+}
+
+// fullBook returns every level of the book of p. The endpoint is private.
+func (e *Exchange) fullBook(p model.Pair) (o kucoin.FullOrderBookModel, err error) {
+	resp, err := e.apiService.AggregatedFullOrderBookV3(Symbol(p))
+	if err != nil {
+		return o, fmt.Errorf("full order book: %w", err)
+	}
+
+	if err := resp.ReadData(&o); err != nil {
+		return o, fmt.Errorf("read data: %w; resp: %+v", err, resp)
+	}
+
+	return o, nil
+}
+
+func (e *Exchange) restBook(p model.Pair) ([]model.Order, []model.Order, error) {
+	o, err := e.partBook(p)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	asks, err := levels(o.Asks)
+	if err != nil {
+		return nil, nil, err
+	}
+	bids, err := levels(o.Bids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	askAddition, bidReduction := e.fees.Ratios(p, false)
+	a := make([]model.Order, 0, len(asks))
+	for _, l := range asks {
 		o := model.Order{
-			Ex:     model.ExchangeTypeKu,
-			Price:  p,
-			Amount: amt,
+			Venue:  e.id,
+			Price:  l.Price,
+			Amount: l.Amount,
 		}
-		o.EffectivePrice = o.Price.Mul(AskAddition)
+		o.EffectivePrice = o.Price.Mul(askAddition)
 		a = append(a, o)
 	}
-	b := make([]model.Order, 0, len(o.Bids))
-	for _, bid := range o.Bids {
-		p, err := decimal.NewFromString(bid[0])
-		if err != nil {
-			return nil, nil, fmt.Errorf("tried to parse %v, got err: %w", bid[0], err)
-		}
-		amt, err := decimal.NewFromString(bid[1])
-		if err != nil {
-			return nil, nil, fmt.Errorf("tried to parse %v, got err: %w", bid[1], err)
-		}
+	b := make([]model.Order, 0, len(bids))
+	for _, l := range bids {
 		o := model.Order{
-			Ex:     model.ExchangeTypeKu,
-			Price:  p,
-			Amount: amt,
+			Venue:  e.id,
+			Price:  l.Price,
+			Amount: l.Amount,
 		}
-		o.EffectivePrice = o.Price.Mul(BidReduction)
+		o.EffectivePrice = o.Price.Mul(bidReduction)
 		b = append(b, o)
 	}
 
 	return a, b, nil
 }
 
-func Balances() (b model.Balances, err error) {
-	resp, err := apiService.Accounts("", "")
+// levels parses [price, size, ...] rows.
+func levels(rows [][]string) ([]depth.Level, error) {
+	ls := make([]depth.Level, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 2 {
+			return nil, fmt.Errorf("short level %v", row)
+		}
+		p, err := decimal.NewFromString(row[0])
+		if err != nil {
+			return nil, fmt.Errorf("tried to parse %v, got err: %w", row[0], err)
+		}
+		amt, err := decimal.NewFromString(row[1])
+		if err != nil {
+			return nil, fmt.Errorf("tried to parse %v, got err: %w", row[1], err)
+		}
+		ls = append(ls, depth.Level{Price: p, Amount: amt})
+	}
+	return ls, nil
+}
+
+func (e *Exchange) Balances() (b model.Balances, err error) {
+	resp, err := e.apiService.Accounts("", "")
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -97,40 +203,33 @@ func Balances() (b model.Balances, err error) {
 		return
 	}
 
+	b = make(model.Balances)
 	for _, aa := range a {
 		if aa.Type == "trade" {
-			switch aa.Currency {
-			case "USDT":
-				usdt, err := decimal.NewFromString(aa.Available)
-				if err != nil {
-					return b, fmt.Errorf("failed to parse %v into decimal: %w", aa.Available, err)
-				}
-				b.USDT = usdt
-			case "XCH":
-				xch, err := decimal.NewFromString(aa.Available)
-				if err != nil {
-					return b, fmt.Errorf("failed to parse %v into decimal: %w", aa.Available, err)
-				}
-				b.XCH = xch
+			avail, err := decimal.NewFromString(aa.Available)
+			if err != nil {
+				return b, fmt.Errorf("failed to parse %v into decimal: %w", aa.Available, err)
 			}
+			b[aa.Currency] = avail
 		}
 	}
 
 	return b, nil
 }
 
-func Buy(price, size decimal.Decimal) (string, error) {
-	resp, err := apiService.CreateOrder(&kucoin.CreateOrderModel{
+func (e *Exchange) Buy(p model.Pair, price, size decimal.Decimal) (string, error) {
+	r := e.rules.Get(p)
+	resp, err := e.apiService.CreateOrder(&kucoin.CreateOrderModel{
 		// BASE PARAMETERS
 		ClientOid: uuid.New().String(),
 		Side:      "buy",
-		Symbol:    "XCH-USDT",
+		Symbol:    Symbol(p),
 		Type:      "limit",
 		STP:       "DC",
 
 		// LIMIT ORDER PARAMETERS
-		Price:       price.String(),
-		Size:        size.RoundDown(4).String(),
+		Price:       r.RoundPrice(price, true).String(),
+		Size:        r.RoundSize(size).String(),
 		TimeInForce: "GTC",
 	})
 	if err != nil {
@@ -145,18 +244,19 @@ func Buy(price, size decimal.Decimal) (string, error) {
 	return o.OrderId, nil
 }
 
-func Sell(price, size decimal.Decimal) (string, error) {
-	resp, err := apiService.CreateOrder(&kucoin.CreateOrderModel{
+func (e *Exchange) Sell(p model.Pair, price, size decimal.Decimal) (string, error) {
+	r := e.rules.Get(p)
+	resp, err := e.apiService.CreateOrder(&kucoin.CreateOrderModel{
 		// BASE PARAMETERS
 		ClientOid: uuid.New().String(),
 		Side:      "sell",
-		Symbol:    "XCH-USDT",
+		Symbol:    Symbol(p),
 		Type:      "limit",
 		STP:       "DC",
 
 		// LIMIT ORDER PARAMETERS
-		Price:       price.String(),
-		Size:        size.RoundDown(4).String(),
+		Price:       r.RoundPrice(price, false).String(),
+		Size:        r.RoundSize(size).String(),
 		TimeInForce: "GTC",
 	})
 	if err != nil {
@@ -171,8 +271,8 @@ func Sell(price, size decimal.Decimal) (string, error) {
 	return o.OrderId, nil
 }
 
-func GetOrder(id string) (*kucoin.OrderModel, error) {
-	resp, err := apiService.Order(id)
+func (e *Exchange) Order(id string) (*kucoin.OrderModel, error) {
+	resp, err := e.apiService.Order(id)
 	if err != nil {
 		return nil, err
 	}
@@ -187,14 +287,14 @@ func GetOrder(id string) (*kucoin.OrderModel, error) {
 
 // {65a8928fcf1c7f00074b0ea7}
 // {Id:65a8928fcf1c7f00074b0ea7 Symbol:XCH-USDT OpType:DEAL Type:limit Side:buy Price:20 Size:0.1 Funds:0 DealFunds:0 DealSize:0 Fee:0 FeeCurrency:USDT Stp: Stop: StopTriggered:false StopPrice:0 TimeInForce:IOC PostOnly:false Hidden:false IceBerg:false VisibleSize:0 CancelAfter:0 Channel:API ClientOid:d6c51d3e-2f72-4e38-a9a6-2afc14041e2e Remark: Tags: IsActive:false CancelExist:true CreatedAt:1705546383349 TradeType:TRADE}
-func OrderTest() {
-	oid, err := Buy(decimal.NewFromInt(20), decimal.NewFromInt(1).Div(decimal.NewFromInt(10)))
+func (e *Exchange) OrderTest() {
+	oid, err := e.Buy(model.XCHUSDT, decimal.NewFromInt(20), decimal.NewFromInt(1).Div(decimal.NewFromInt(10)))
 	fmt.Println(oid, err)
 	if err != nil {
 		return
 	}
 
-	resp, err := apiService.Order(oid)
+	resp, err := e.apiService.Order(oid)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -210,20 +310,67 @@ func OrderTest() {
 }
 
 // [{XCH-USDT 0.001 0.001}]
-func QueryFee() {
-	resp, err := apiService.ActualFee("XCH-USDT")
+// QueryRules returns the order rules of p.
+func (e *Exchange) QueryRules(p model.Pair) (r model.Rules, err error) {
+	resp, err := e.public.Symbols("")
 	if err != nil {
-		fmt.Println(err)
-		return
+		return r, err
+	}
+
+	var ss kucoin.SymbolsModel
+	if err := resp.ReadData(&ss); err != nil {
+		return r, err
+	}
+
+	for _, s := range ss {
+		if s.Symbol != Symbol(p) {
+			continue
+		}
+		r.MinBase, err = decimal.NewFromString(s.BaseMinSize)
+		if err != nil {
+			return r, fmt.Errorf("failed to parse %v into decimal: %w", s.BaseMinSize, err)
+		}
+		r.MinQuote, err = decimal.NewFromString(s.QuoteMinSize)
+		if err != nil {
+			return r, fmt.Errorf("failed to parse %v into decimal: %w", s.QuoteMinSize, err)
+		}
+		r.BaseIncrement, err = decimal.NewFromString(s.BaseIncrement)
+		if err != nil {
+			return r, fmt.Errorf("failed to parse %v into decimal: %w", s.BaseIncrement, err)
+		}
+		r.PriceIncrement, err = decimal.NewFromString(s.PriceIncrement)
+		if err != nil {
+			return r, fmt.Errorf("failed to parse %v into decimal: %w", s.PriceIncrement, err)
+		}
+		return r, nil
+	}
+	return r, fmt.Errorf("no symbol %v", Symbol(p))
+}
+
+// QueryFee returns the fee ratios charged to the account on p.
+func (e *Exchange) QueryFee(p model.Pair) (maker, taker decimal.Decimal, err error) {
+	resp, err := e.apiService.ActualFee(Symbol(p))
+	if err != nil {
+		return maker, taker, err
 	}
 
 	var f kucoin.TradeFeesResultModel
 	if err := resp.ReadData(&f); err != nil {
-		fmt.Println(err)
-		return
+		return maker, taker, err
+	}
+	if len(f) == 0 {
+		return maker, taker, fmt.Errorf("no fee for %v", Symbol(p))
 	}
 
-	fmt.Printf("%+v\n", f)
+	maker, err = decimal.NewFromString(f[0].MakerFeeRate)
+	if err != nil {
+		return maker, taker, fmt.Errorf("failed to parse %v into decimal: %w", f[0].MakerFeeRate, err)
+	}
+	taker, err = decimal.NewFromString(f[0].TakerFeeRate)
+	if err != nil {
+		return maker, taker, fmt.Errorf("failed to parse %v into decimal: %w", f[0].TakerFeeRate, err)
+	}
+	return maker, taker, nil
 }
 
 /*
@@ -0,0 +1,57 @@
+package k
+
+import (
+	"testing"
+
+	"github.com/L3Sota/arbo/arb/depth"
+	"github.com/L3Sota/arbo/arb/fees"
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/shopspring/decimal"
+)
+
+// TestBookDepth serves a streamed book deeper than the public snapshot.
+func TestBookDepth(t *testing.T) {
+	t.Parallel()
+
+	p := model.XCHUSDT
+	var asks, bids []depth.Level
+	for i := int64(1); i <= partDepth+50; i++ {
+		one := decimal.NewFromInt(1)
+		asks = append(asks, depth.Level{Price: decimal.NewFromInt(1000 + i), Amount: one})
+		bids = append(bids, depth.Level{Price: decimal.NewFromInt(1000 - i), Amount: one})
+	}
+
+	for _, tc := range []struct {
+		name   string
+		authed bool
+		want   int
+	}{
+		{name: "unauthenticated", want: partDepth},
+		{name: "authenticated", authed: true, want: partDepth + 50},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b := depth.New()
+			b.Replace(asks, bids)
+			e := &Exchange{
+				id:     "Ku",
+				authed: tc.authed,
+				books:  map[model.Pair]*depth.Book{p: b},
+				fees:   fees.New(Fees),
+			}
+
+			a, bs, err := e.Book(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(a) != tc.want || len(bs) != tc.want {
+				t.Fatalf("got %v asks and %v bids, want %v each", len(a), len(bs), tc.want)
+			}
+			if !a[0].Price.Equal(decimal.NewFromInt(1001)) || !bs[0].Price.Equal(decimal.NewFromInt(999)) {
+				t.Errorf("best ask %v, bid %v; want 1001, 999", a[0].Price, bs[0].Price)
+			}
+			if last := a[tc.want-1].Price; !last.Equal(decimal.NewFromInt(1000 + int64(tc.want))) {
+				t.Errorf("deepest ask %v, want %v", last, 1000+tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package k
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Kucoin/kucoin-go-sdk"
+	"github.com/L3Sota/arbo/arb/ratelimit"
+)
+
+// Limits are KuCoin's documented VIP0 limits in requests. The public and spot
+// pools allow 2000 and 4000 weight per 30s, and the calls we make weigh 2-4.
+var Limits = [ratelimit.ClassMax]ratelimit.Limit{
+	ratelimit.Public:  {N: 500, Per: 30 * time.Second},
+	ratelimit.Private: {N: 1000, Per: 30 * time.Second},
+	ratelimit.Order:   {N: 45, Per: 3 * time.Second},
+}
+
+// Headers report the remaining weight of the pool and the milliseconds until
+// it resets. Requests are counted at the heaviest weight, as in Limits. Order
+// calls draw on the spot pool the private bucket follows, so the pool is not
+// applied to the order bucket as well.
+var Headers = ratelimit.Headers{
+	Remaining: "gw-ratelimit-remaining",
+	Reset:     "gw-ratelimit-reset",
+	ResetUnit: time.Millisecond,
+	Weight: [ratelimit.ClassMax]float64{
+		ratelimit.Public:  4,
+		ratelimit.Private: 4,
+	},
+}
+
+// requester sends SDK requests through the venue's limiter.
+type requester struct {
+	limiter *ratelimit.Limiter
+	private bool
+}
+
+func (r *requester) Request(req *kucoin.Request, timeout time.Duration) (*kucoin.Response, error) {
+	c := ratelimit.Public
+	if r.private {
+		c = ratelimit.Private
+		if strings.HasPrefix(req.Path, "/api/v1/orders") && req.Method != http.MethodGet {
+			c = ratelimit.Order
+		}
+	}
+
+	if err := r.limiter.Wait(context.Background(), c); err != nil {
+		return nil, err
+	}
+	rsp, err := (&kucoin.BasicRequester{}).Request(req, timeout)
+	if err == nil {
+		r.limiter.Observe(c, rsp.Header)
+	}
+	return rsp, err
+}
@@ -4,29 +4,36 @@ import (
 	"fmt"
 
 	"github.com/L3Sota/arbo/arb/config"
+	"github.com/L3Sota/arbo/arb/model"
 	"github.com/L3Sota/arbo/k"
 )
 
 func main() {
-	k.LoadClient(config.Load())
-	k.OrderTest()
-	// k.QueryFee()
-	// book()
-	// balances()
+	e := k.New("Ku", config.Load().Account("Ku"))
+	e.OrderTest()
+	// fee(e)
+	// book(e)
+	// balances(e)
 }
 
-func book() {
-	a, b, err := k.Book()
+func book(e *k.Exchange) {
+	a, b, err := e.Book(model.XCHUSDT)
 
 	fmt.Print(a)
 	fmt.Print(b)
 	fmt.Print(err)
 }
 
-func balances() {
-	b, err := k.Balances()
+func fee(e *k.Exchange) {
+	maker, taker, err := e.QueryFee(model.XCHUSDT)
 
-	fmt.Println(b.USDT.String())
-	fmt.Println(b.XCH.String())
+	fmt.Println(maker, taker, err)
+}
+
+func balances(e *k.Exchange) {
+	b, err := e.Balances()
+
+	fmt.Println(b["USDT"].String())
+	fmt.Println(b["XCH"].String())
 	fmt.Println(err)
 }
@@ -0,0 +1,39 @@
+package k
+
+import (
+	"fmt"
+
+	"github.com/Kucoin/kucoin-go-sdk"
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/shopspring/decimal"
+)
+
+// Chains are KuCoin's chain ids by network.
+var Chains = map[string]string{
+	"CHIA":  "xch",
+	"TRC20": "trx",
+	"SOL":   "sol",
+	"ARB":   "arbitrum",
+}
+
+// Withdraw sends amount of currency to address over w's network. The fee is
+// charged on top of amount.
+func (e *Exchange) Withdraw(w model.Withdrawal, currency, address string, amount decimal.Decimal) (string, error) {
+	chain, ok := Chains[w.Network]
+	if !ok {
+		return "", fmt.Errorf("no chain for network %v", w.Network)
+	}
+
+	resp, err := e.apiService.ApplyWithdrawal(currency, address, amount.String(), map[string]string{
+		"chain": chain,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var r kucoin.ApplyWithdrawalResultModel
+	if err := resp.ReadData(&r); err != nil {
+		return "", err
+	}
+	return r.WithdrawalId, nil
+}
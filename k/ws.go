@@ -0,0 +1,237 @@
+package k
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Kucoin/kucoin-go-sdk"
+	"github.com/L3Sota/arbo/arb/depth"
+	"github.com/L3Sota/arbo/arb/model"
+)
+
+const (
+	// restartAfter is how long a level-2 stream may stay quiet before it is
+	// reopened.
+	restartAfter = time.Minute
+	// touchEvery is how often a healthy stream confirms the book. The client
+	// fails the connection when a heartbeat goes unanswered, so while it is
+	// up the book is current even if the market is quiet.
+	touchEvery = 5 * time.Second
+)
+
+var (
+	errQuiet  = errors.New("stream quiet")
+	errClosed = errors.New("stream closed")
+)
+
+// l2Update is the data of a trade.l2update message. Each change is
+// [price, size, sequence]; a zero size removes the level.
+type l2Update struct {
+	Changes struct {
+		Asks [][]string `json:"asks"`
+		Bids [][]string `json:"bids"`
+	} `json:"changes"`
+	SequenceStart int64 `json:"sequenceStart"`
+	SequenceEnd   int64 `json:"sequenceEnd"`
+}
+
+// stream returns the local book for p, starting its stream on first use.
+func (e *Exchange) stream(p model.Pair) *depth.Book {
+	e.smu.Lock()
+	defer e.smu.Unlock()
+
+	if b, ok := e.books[p]; ok {
+		return b
+	}
+	b := depth.New()
+	e.books[p] = b
+	go e.watch(p, b)
+	return b
+}
+
+// watch keeps b in sync with the level-2 topic of p, reconnecting whenever
+// the stream fails.
+func (e *Exchange) watch(p model.Pair, b *depth.Book) {
+	for {
+		err := e.follow(p, b)
+		b.Reset()
+		fmt.Printf("%v %v level2 stream: %v\n", e.id, p, err)
+		time.Sleep(time.Second)
+	}
+}
+
+// follow subscribes to the level-2 topic of p, takes a REST snapshot and
+// applies updates on top of it until the connection fails. Updates are
+// buffered by the client while the snapshot is fetched; a gap in sequence
+// numbers triggers a new snapshot.
+func (e *Exchange) follow(p model.Pair, b *depth.Book) error {
+	resp, err := e.public.WebSocketPublicToken()
+	if err != nil {
+		return fmt.Errorf("token: %w", err)
+	}
+	var tk kucoin.WebSocketTokenModel
+	if err := resp.ReadData(&tk); err != nil {
+		return fmt.Errorf("read token: %w", err)
+	}
+
+	c := e.public.NewWebSocketClient(&tk)
+	mc, ec, err := c.Connect()
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	defer c.Stop()
+
+	topic := "/market/level2:" + Symbol(p)
+	if err := c.Subscribe(kucoin.NewSubscribeMessage(topic, false)); err != nil {
+		return fmt.Errorf("subscribe %v: %w", topic, err)
+	}
+
+	seq, err := e.snapshot(p, b)
+	if err != nil {
+		return err
+	}
+
+	alive := time.NewTicker(touchEvery)
+	defer alive.Stop()
+	quiet := time.NewTimer(restartAfter)
+	defer quiet.Stop()
+	for {
+		select {
+		case err := <-ec:
+			return err
+		case <-alive.C:
+			if b.Ready() {
+				b.Touch()
+			}
+		case <-quiet.C:
+			return errQuiet
+		case m, ok := <-mc:
+			if !ok {
+				return errClosed
+			}
+			if !quiet.Stop() {
+				<-quiet.C
+			}
+			quiet.Reset(restartAfter)
+
+			if m.Subject != "trade.l2update" {
+				continue
+			}
+			var u l2Update
+			if err := m.ReadData(&u); err != nil {
+				return fmt.Errorf("read update: %w", err)
+			}
+
+			next, ok, err := apply(b, seq, u)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				fmt.Printf("%v %v level2 gap: have %v, got %v-%v; resnapshotting\n", e.id, p, seq, u.SequenceStart, u.SequenceEnd)
+				b.Reset()
+				if next, err = e.snapshot(p, b); err != nil {
+					return err
+				}
+			}
+			seq = next
+		}
+	}
+}
+
+// snapshot replaces b with the REST book and returns its sequence number.
+// Without credentials only the top partDepth levels of each side can be
+// fetched; updates further out then add levels to a book that lacks the rest
+// of them, so Book serves only the top partDepth levels.
+func (e *Exchange) snapshot(p model.Pair, b *depth.Book) (int64, error) {
+	var o kucoin.FullOrderBookModel
+	if e.authed {
+		var err error
+		if o, err = e.fullBook(p); err != nil {
+			return 0, err
+		}
+	} else {
+		part, err := e.partBook(p)
+		if err != nil {
+			return 0, err
+		}
+		o = kucoin.FullOrderBookModel(part)
+	}
+	seq, err := strconv.ParseInt(o.Sequence, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("tried to parse sequence %v, got err: %w", o.Sequence, err)
+	}
+	asks, err := levels(o.Asks)
+	if err != nil {
+		return 0, err
+	}
+	bids, err := levels(o.Bids)
+	if err != nil {
+		return 0, err
+	}
+
+	b.Replace(asks, bids)
+	return seq, nil
+}
+
+// apply applies the changes of u newer than seq to b and returns the new
+// sequence number. It reports false, leaving b untouched, if u does not
+// follow on from seq.
+func apply(b *depth.Book, seq int64, u l2Update) (int64, bool, error) {
+	if u.SequenceEnd <= seq {
+		return seq, true, nil
+	}
+	if u.SequenceStart > seq+1 {
+		return seq, false, nil
+	}
+
+	asks, err := changes(seq, u.Changes.Asks)
+	if err != nil {
+		return seq, true, err
+	}
+	bids, err := changes(seq, u.Changes.Bids)
+	if err != nil {
+		return seq, true, err
+	}
+
+	b.Apply(asks, bids)
+	return u.SequenceEnd, true, nil
+}
+
+// changes parses the rows newer than seq. A zero price marks a change that
+// does not affect the book.
+func changes(seq int64, rows [][]string) ([]depth.Level, error) {
+	fresh := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 3 {
+			return nil, fmt.Errorf("short change %v", row)
+		}
+		s, err := strconv.ParseInt(row[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("tried to parse sequence %v, got err: %w", row[2], err)
+		}
+		if s <= seq || row[0] == "0" {
+			continue
+		}
+		fresh = append(fresh, row)
+	}
+	return levels(fresh)
+}
+
+// Watch returns a channel that receives whenever the best bid or ask of p
+// changes.
+func (e *Exchange) Watch(p model.Pair) <-chan struct{} {
+	return e.stream(p).Watch()
+}
+
+// Age returns how long ago the streamed book for p was last confirmed in
+// sync.
+func (e *Exchange) Age(p model.Pair) time.Duration {
+	return e.stream(p).Age()
+}
+
+// Streaming reports whether Book serves p from the stream.
+func (e *Exchange) Streaming(p model.Pair) bool {
+	return e.stream(p).Ready()
+}
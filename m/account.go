@@ -0,0 +1,165 @@
+package m
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const apiHost = "https://api.mexc.com"
+
+type apiError struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+type account struct {
+	Balances []struct {
+		Asset  string `json:"asset"`
+		Free   string `json:"free"`
+		Locked string `json:"locked"`
+	} `json:"balances"`
+}
+
+type newOrder struct {
+	Symbol  string `json:"symbol"`
+	OrderID string `json:"orderId"`
+}
+
+type order struct {
+	Symbol              string `json:"symbol"`
+	OrderID             string `json:"orderId"`
+	Price               string `json:"price"`
+	OrigQty             string `json:"origQty"`
+	ExecutedQty         string `json:"executedQty"`
+	CummulativeQuoteQty string `json:"cummulativeQuoteQty"`
+	Status              string `json:"status"`
+	Side                string `json:"side"`
+}
+
+type trade struct {
+	OrderID         string `json:"orderId"`
+	Commission      string `json:"commission"`
+	CommissionAsset string `json:"commissionAsset"`
+}
+
+// signed makes a request to a SIGNED endpoint of the spot v3 API and decodes
+// the response into out. The parameters and signature are sent in the query
+// string for every method. Without an API key the book is served but signed
+// endpoints are unsupported, so the venue holds no balances to trade with.
+func (e *Exchange) signed(method, path string, params url.Values, out any) error {
+	if e.key == "" {
+		return fmt.Errorf("%v %v: no API key: %w", method, path, errors.ErrUnsupported)
+	}
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("recvWindow", "5000")
+	params.Set("timestamp", strconv.FormatInt(time.Now().UnixMilli(), 10))
+	query := params.Encode()
+
+	mac := hmac.New(sha256.New, []byte(e.secret))
+	mac.Write([]byte(query))
+	query += "&signature=" + hex.EncodeToString(mac.Sum(nil))
+
+	req, err := http.NewRequest(method, apiHost+path+"?"+query, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-MEXC-APIKEY", e.key)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var ae apiError
+		if err := json.Unmarshal(body, &ae); err != nil || ae.Msg == "" {
+			return fmt.Errorf("%v %v: http %v: %s", method, path, resp.StatusCode, body)
+		}
+		return fmt.Errorf("[Error %d] %v", ae.Code, ae.Msg)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("tried to parse %s, got err: %w", body, err)
+	}
+	return nil
+}
+
+func (e *Exchange) account() (a account, err error) {
+	err = e.signed(http.MethodGet, "/api/v3/account", nil, &a)
+	return a, err
+}
+
+func (e *Exchange) placeOrder(symbol, side, quantity, price string) (o newOrder, err error) {
+	err = e.signed(http.MethodPost, "/api/v3/order", url.Values{
+		"symbol":   {symbol},
+		"side":     {side},
+		"type":     {"LIMIT"},
+		"quantity": {quantity},
+		"price":    {price},
+	}, &o)
+	return o, err
+}
+
+func (e *Exchange) queryOrder(symbol, id string) (o order, err error) {
+	err = e.signed(http.MethodGet, "/api/v3/order", url.Values{
+		"symbol":  {symbol},
+		"orderId": {id},
+	}, &o)
+	return o, err
+}
+
+func (e *Exchange) cancelOrder(symbol, id string) error {
+	return e.signed(http.MethodDelete, "/api/v3/order", url.Values{
+		"symbol":  {symbol},
+		"orderId": {id},
+	}, nil)
+}
+
+type tradeFee struct {
+	Data struct {
+		MakerCommission decimal.Decimal `json:"makerCommission"`
+		TakerCommission decimal.Decimal `json:"takerCommission"`
+	} `json:"data"`
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+func (e *Exchange) tradeFee(symbol string) (f tradeFee, err error) {
+	err = e.signed(http.MethodGet, "/api/v3/tradeFee", url.Values{
+		"symbol": {symbol},
+	}, &f)
+	if err == nil && f.Code != 0 {
+		err = fmt.Errorf("[Error %d] %v", f.Code, f.Msg)
+	}
+	return f, err
+}
+
+// orderTrades returns the fills of an order, which carry its fees.
+func (e *Exchange) orderTrades(symbol, id string) (ts []trade, err error) {
+	err = e.signed(http.MethodGet, "/api/v3/myTrades", url.Values{
+		"symbol":  {symbol},
+		"orderId": {id},
+	}, &ts)
+	return ts, err
+}
@@ -0,0 +1,128 @@
+package m
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/L3Sota/arbo/arb/ratelimit"
+	"github.com/shopspring/decimal"
+)
+
+func (e *Exchange) Venue() model.Venue {
+	return model.Venue{
+		ID:       e.id,
+		Exchange: model.ExchangeTypeMe,
+		Fees:     e.fees.Fees(),
+		PairFees: e.fees.All(),
+		Rules:    e.rules.All(),
+		Deposits: Deposits,
+	}
+}
+
+func (e *Exchange) Balances() (b model.Balances, err error) {
+	a, err := e.account()
+	if err != nil {
+		return b, err
+	}
+
+	b = make(model.Balances, len(a.Balances))
+	for _, aa := range a.Balances {
+		free, err := decimal.NewFromString(aa.Free)
+		if err != nil {
+			return b, fmt.Errorf("failed to parse %v into decimal: %w", aa.Free, err)
+		}
+		b[strings.ToUpper(aa.Asset)] = free
+	}
+
+	return b, nil
+}
+
+func (e *Exchange) Buy(p model.Pair, price, size decimal.Decimal) (string, error) {
+	r := e.rules.Get(p)
+	o, err := e.placeOrder(Symbol(p), "BUY", r.RoundSize(size).String(), r.RoundPrice(price, true).String())
+	if err != nil {
+		return "", err
+	}
+	return o.OrderID, nil
+}
+
+func (e *Exchange) Sell(p model.Pair, price, size decimal.Decimal) (string, error) {
+	r := e.rules.Get(p)
+	o, err := e.placeOrder(Symbol(p), "SELL", r.RoundSize(size).String(), r.RoundPrice(price, false).String())
+	if err != nil {
+		return "", err
+	}
+	return o.OrderID, nil
+}
+
+func (e *Exchange) GetOrder(p model.Pair, id string) (s model.OrderStatus, err error) {
+	o, err := e.queryOrder(Symbol(p), id)
+	if err != nil {
+		return s, err
+	}
+
+	filled, err := decimal.NewFromString(o.ExecutedQty)
+	if err != nil {
+		return s, fmt.Errorf("failed to parse %v into decimal: %w", o.ExecutedQty, err)
+	}
+	funds, err := decimal.NewFromString(o.CummulativeQuoteQty)
+	if err != nil {
+		return s, fmt.Errorf("failed to parse %v into decimal: %w", o.CummulativeQuoteQty, err)
+	}
+
+	// the order itself does not report fees, so sum them over its fills
+	var (
+		fee         decimal.Decimal
+		feeCurrency string
+	)
+	if filled.IsPositive() {
+		ts, err := e.orderTrades(Symbol(p), id)
+		if err != nil {
+			return s, err
+		}
+		for _, t := range ts {
+			c, err := decimal.NewFromString(t.Commission)
+			if err != nil {
+				return s, fmt.Errorf("failed to parse %v into decimal: %w", t.Commission, err)
+			}
+			fee = fee.Add(c)
+			feeCurrency = t.CommissionAsset
+		}
+	}
+
+	return model.OrderStatus{
+		ID:          id,
+		Filled:      filled,
+		FilledFunds: funds,
+		Fee:         fee,
+		FeeCurrency: feeCurrency,
+		Active:      o.Status == "NEW" || o.Status == "PARTIALLY_FILLED",
+		State:       o.Status,
+	}, nil
+}
+
+// QueryFee returns the fee ratios charged to the account on p.
+func (e *Exchange) QueryFee(p model.Pair) (maker, taker decimal.Decimal, err error) {
+	f, err := e.tradeFee(Symbol(p))
+	if err != nil {
+		return maker, taker, err
+	}
+	return f.Data.MakerCommission, f.Data.TakerCommission, nil
+}
+
+func (e *Exchange) Cancel(p model.Pair, id string) error {
+	return e.cancelOrder(Symbol(p), id)
+}
+
+func (e *Exchange) Limiter() *ratelimit.Limiter {
+	return e.limiter
+}
+
+func (e *Exchange) SetFees(p model.Pair, maker, taker decimal.Decimal) {
+	e.fees.Set(p, maker, taker)
+}
+
+func (e *Exchange) SetRules(p model.Pair, r model.Rules) {
+	e.rules.Set(p, r)
+}
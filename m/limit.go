@@ -0,0 +1,26 @@
+package m
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/L3Sota/arbo/arb/ratelimit"
+)
+
+// Limits follow MEXC's documented budget of 500 weight per 10s per endpoint,
+// where a full depth snapshot weighs up to 25, and its limit of 5 orders per
+// second.
+var Limits = [ratelimit.ClassMax]ratelimit.Limit{
+	ratelimit.Public:  {N: 20, Per: time.Second},
+	ratelimit.Private: {N: 20, Per: time.Second},
+	ratelimit.Order:   {N: 5, Per: time.Second},
+}
+
+// classify classes the signed requests: placing and cancelling orders, and
+// everything else as private.
+func classify(r *http.Request) ratelimit.Class {
+	if r.URL.Path == "/api/v3/order" && r.Method != http.MethodGet {
+		return ratelimit.Order
+	}
+	return ratelimit.Private
+}
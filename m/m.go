@@ -4,8 +4,15 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"sync"
 
+	"github.com/L3Sota/arbo/arb/config"
+	"github.com/L3Sota/arbo/arb/depth"
+	"github.com/L3Sota/arbo/arb/fees"
 	"github.com/L3Sota/arbo/arb/model"
+	"github.com/L3Sota/arbo/arb/ratelimit"
+	"github.com/L3Sota/arbo/arb/rules"
 	"github.com/linstohu/nexapi/mexc/spot/marketdata"
 	"github.com/linstohu/nexapi/mexc/spot/marketdata/types"
 	spotutils "github.com/linstohu/nexapi/mexc/spot/utils"
@@ -14,32 +21,135 @@ import (
 
 var (
 	Fees = model.Fees{
-		MakerTakerRatio:    decimal.RequireFromString("0.001"), // 0.1%
-		WithdrawalFlatXCH:  decimal.RequireFromString("0.0005"),
-		WithdrawalFlatUSDT: decimal.NewFromInt(1), // TRC20 ARB OP
+		Maker: decimal.RequireFromString("0.001"), // 0.1%
+		Taker: decimal.RequireFromString("0.001"), // 0.1%
+		Withdrawals: map[string][]model.Withdrawal{
+			"XCH": {{Network: "CHIA", Fee: decimal.RequireFromString("0.0005")}},
+			"USDT": {
+				{Network: "TRC20", Fee: decimal.NewFromInt(1)},
+				{Network: "ARB", Fee: decimal.NewFromInt(1)},
+				{Network: "OP", Fee: decimal.NewFromInt(1)},
+			},
+		},
+	}
+	Deposits = map[string][]string{
+		"XCH":  {"CHIA"},
+		"USDT": {"TRC20", "SOL", "ARB", "OP"},
+	}
+	Rules = map[model.Pair]model.Rules{
+		model.XCHUSDT: {
+			MinQuote: decimal.NewFromInt(5),
+		},
 	}
-	AskAddition  = decimal.NewFromInt(1).Add(Fees.MakerTakerRatio)
-	BidReduction = decimal.NewFromInt(1).Sub(Fees.MakerTakerRatio)
 )
 
-func Book() ([]model.Order, []model.Order, error) {
+type Exchange struct {
+	id      model.VenueID
+	key     string
+	secret  string
+	nex     *marketdata.SpotMarketDataClient
+	client  *http.Client
+	wsURL   string
+	limiter *ratelimit.Limiter
+
+	smu   sync.Mutex
+	books map[model.Pair]*depth.Book
+
+	fees  *fees.Schedule
+	rules *rules.Table
+}
+
+func New(id model.VenueID, a config.Account) (*Exchange, error) {
 	nex, err := marketdata.NewSpotMarketDataClient(&spotutils.SpotClientCfg{
 		BaseURL: "https://api.mexc.com/",
 		Logger:  slog.Default(),
 	})
 	if err != nil {
+		return nil, err
+	}
+
+	l := ratelimit.New(Limits, ratelimit.Headers{})
+	return &Exchange{
+		id:     id,
+		key:    a.Key,
+		secret: a.Sec,
+		nex:    nex,
+		client: &http.Client{
+			Transport: &ratelimit.Transport{Limiter: l, Classify: classify},
+		},
+		wsURL:   wsURL,
+		limiter: l,
+		books:   map[model.Pair]*depth.Book{},
+		fees:    fees.New(Fees),
+		rules:   rules.New(Rules),
+	}, nil
+}
+
+// Symbol returns the MEXC symbol for p, e.g. XCHUSDT.
+func Symbol(p model.Pair) string {
+	return p.Base + p.Quote
+}
+
+// Book serves the streamed book for p, falling back to REST until the stream
+// is in sync or when it is older than maxAge.
+func (e *Exchange) Book(p model.Pair) ([]model.Order, []model.Order, error) {
+	if e.Streaming(p) {
+		askAddition, bidReduction := e.fees.Ratios(p, false)
+		a, b := e.stream(p).Orders(e.id, askAddition, bidReduction)
+		return a, b, nil
+	}
+	return e.restBook(p)
+}
+
+// QueryRules returns the order rules of p.
+func (e *Exchange) QueryRules(p model.Pair) (r model.Rules, err error) {
+	if err := e.limiter.Wait(context.TODO(), ratelimit.Public); err != nil {
+		return r, err
+	}
+	info, err := e.nex.GetExchangeInfo(context.TODO(), types.GetExchangeInfoParam{
+		Symbol: Symbol(p),
+	})
+	if err != nil {
+		return r, err
+	}
+
+	for _, s := range info.Symbols {
+		if s.Symbol != Symbol(p) {
+			continue
+		}
+		// baseSizePrecision is the smallest order size and quoteAmountPrecision
+		// the smallest order value
+		r.MinBase, err = decimal.NewFromString(s.BaseSizePrecision)
+		if err != nil {
+			return r, fmt.Errorf("failed to parse %v into decimal: %w", s.BaseSizePrecision, err)
+		}
+		r.MinQuote, err = decimal.NewFromString(s.QuoteAmountPrecision)
+		if err != nil {
+			return r, fmt.Errorf("failed to parse %v into decimal: %w", s.QuoteAmountPrecision, err)
+		}
+		r.BaseIncrement = model.Increment(int32(s.BaseAssetPrecision))
+		r.PriceIncrement = model.Increment(int32(s.QuotePrecision))
+		return r, nil
+	}
+	return r, fmt.Errorf("no symbol %v", Symbol(p))
+}
+
+func (e *Exchange) restBook(p model.Pair) ([]model.Order, []model.Order, error) {
+	// the client makes its own HTTP requests, so limit them here
+	if err := e.limiter.Wait(context.TODO(), ratelimit.Public); err != nil {
 		return nil, nil, err
 	}
-	o, err := nex.GetOrderbook(context.TODO(), types.GetOrderbookParams{
-		Symbol: "XCHUSDT",
+	o, err := e.nex.GetOrderbook(context.TODO(), types.GetOrderbookParams{
+		Symbol: Symbol(p),
 	})
 	if err != nil {
 		return nil, nil, err
 	}
 
+	askAddition, bidReduction := e.fees.Ratios(p, false)
 	a := make([]model.Order, 0, len(o.Asks))
 	for _, ask := range o.Asks {
-		p, err := decimal.NewFromString(ask[0])
+		price, err := decimal.NewFromString(ask[0])
 		if err != nil {
 			return nil, nil, fmt.Errorf("tried to parse %v, got err: %w", ask[0], err)
 		}
@@ -48,16 +158,16 @@ func Book() ([]model.Order, []model.Order, error) {
 			return nil, nil, fmt.Errorf("tried to parse %v, got err: %w", ask[1], err)
 		}
 		o := model.Order{
-			Ex:     model.ExchangeTypeMe,
-			Price:  p,
+			Venue:  e.id,
+			Price:  price,
 			Amount: amt,
 		}
-		o.EffectivePrice = o.Price.Mul(AskAddition)
+		o.EffectivePrice = o.Price.Mul(askAddition)
 		a = append(a, o)
 	}
 	b := make([]model.Order, 0, len(o.Bids))
 	for _, bid := range o.Bids {
-		p, err := decimal.NewFromString(bid[0])
+		price, err := decimal.NewFromString(bid[0])
 		if err != nil {
 			return nil, nil, fmt.Errorf("tried to parse %v, got err: %w", bid[0], err)
 		}
@@ -66,11 +176,11 @@ func Book() ([]model.Order, []model.Order, error) {
 			return nil, nil, fmt.Errorf("tried to parse %v, got err: %w", bid[1], err)
 		}
 		o := model.Order{
-			Ex:     model.ExchangeTypeMe,
-			Price:  p,
+			Venue:  e.id,
+			Price:  price,
 			Amount: amt,
 		}
-		o.EffectivePrice = o.Price.Mul(BidReduction)
+		o.EffectivePrice = o.Price.Mul(bidReduction)
 		b = append(b, o)
 	}
 
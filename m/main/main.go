@@ -3,11 +3,19 @@ package main
 import (
 	"fmt"
 
+	"github.com/L3Sota/arbo/arb/config"
+	"github.com/L3Sota/arbo/arb/model"
 	"github.com/L3Sota/arbo/m"
 )
 
 func main() {
-	a, b, err := m.Book()
+	e, err := m.New("Me", config.Load().Account("Me"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	a, b, err := e.Book(model.XCHUSDT)
 
 	fmt.Println(a)
 	fmt.Println(b)
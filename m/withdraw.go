@@ -0,0 +1,43 @@
+package m
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/shopspring/decimal"
+)
+
+// Chains are MEXC's network names by network.
+var Chains = map[string]string{
+	"CHIA":  "XCH",
+	"TRC20": "TRX",
+	"SOL":   "SOL",
+	"ARB":   "ARB",
+	"OP":    "OP",
+}
+
+type withdrawal struct {
+	ID string `json:"id"`
+}
+
+// Withdraw sends amount of currency to address over w's network.
+func (e *Exchange) Withdraw(w model.Withdrawal, currency, address string, amount decimal.Decimal) (string, error) {
+	chain, ok := Chains[w.Network]
+	if !ok {
+		return "", fmt.Errorf("no chain for network %v", w.Network)
+	}
+
+	var r withdrawal
+	err := e.signed(http.MethodPost, "/api/v3/capital/withdraw", url.Values{
+		"coin":    {currency},
+		"netWork": {chain},
+		"address": {address},
+		"amount":  {amount.String()},
+	}, &r)
+	if err != nil {
+		return "", err
+	}
+	return r.ID, nil
+}
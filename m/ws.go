@@ -0,0 +1,280 @@
+package m
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/L3Sota/arbo/arb/depth"
+	"github.com/L3Sota/arbo/arb/model"
+	"github.com/L3Sota/arbo/arb/ratelimit"
+	"github.com/gorilla/websocket"
+	"github.com/linstohu/nexapi/mexc/spot/marketdata/types"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	wsURL = "wss://wbs.mexc.com/ws"
+
+	// pingEvery keeps the connection alive; each pong also confirms the book.
+	pingEvery = 5 * time.Second
+	// maxAge is how long a book may go unconfirmed before Book falls back to
+	// REST, and restartAfter how long before the stream is reopened.
+	maxAge       = 6 * pingEvery
+	restartAfter = time.Minute
+)
+
+var errQuiet = errors.New("stream quiet")
+
+type wsRequest struct {
+	Method string   `json:"method"`
+	Params []string `json:"params,omitempty"`
+}
+
+// wsMessage is either a reply to a request (Code, Msg) or pushed data (C, D).
+type wsMessage struct {
+	Code int             `json:"code"`
+	Msg  string          `json:"msg"`
+	C    string          `json:"c"`
+	D    json.RawMessage `json:"d"`
+}
+
+type wsLevel struct {
+	P string `json:"p"`
+	V string `json:"v"`
+}
+
+// depthUpdate is the data of an increase.depth message; R is its version.
+type depthUpdate struct {
+	Asks []wsLevel `json:"asks"`
+	Bids []wsLevel `json:"bids"`
+	R    string    `json:"r"`
+}
+
+// stream returns the local book for p, starting its stream on first use.
+func (e *Exchange) stream(p model.Pair) *depth.Book {
+	e.smu.Lock()
+	defer e.smu.Unlock()
+
+	if b, ok := e.books[p]; ok {
+		return b
+	}
+	b := depth.New()
+	e.books[p] = b
+	go e.watch(p, b)
+	return b
+}
+
+// watch keeps b in sync with the incremental depth of p, reconnecting
+// whenever the stream fails.
+func (e *Exchange) watch(p model.Pair, b *depth.Book) {
+	for {
+		err := e.follow(context.Background(), p, b)
+		b.Reset()
+		fmt.Printf("%v %v depth stream: %v\n", e.id, p, err)
+		time.Sleep(time.Second)
+	}
+}
+
+// follow subscribes to the incremental depth of p, takes a REST snapshot and
+// applies versioned updates on top of it until the connection fails or ctx is
+// done. A skipped version triggers a new snapshot.
+func (e *Exchange) follow(ctx context.Context, p model.Pair, b *depth.Book) error {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, e.wsURL, nil)
+	if err != nil {
+		return fmt.Errorf("dial: %w", err)
+	}
+	defer conn.Close()
+
+	topic := "spot@public.increase.depth.v3.api@" + Symbol(p)
+	if err := conn.WriteJSON(wsRequest{Method: "SUBSCRIPTION", Params: []string{topic}}); err != nil {
+		return fmt.Errorf("subscribe: %w", err)
+	}
+
+	// read into a buffer so that updates sent while the snapshot is fetched
+	// are kept; a nil update is a pong
+	updates := make(chan *depthUpdate, 1024)
+	errc := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			var m wsMessage
+			if err := conn.ReadJSON(&m); err != nil {
+				errc <- err
+				return
+			}
+
+			var u *depthUpdate
+			switch {
+			case m.C == topic:
+				u = &depthUpdate{}
+				if err := json.Unmarshal(m.D, u); err != nil {
+					errc <- fmt.Errorf("read update: %w", err)
+					return
+				}
+			case m.Code != 0:
+				errc <- fmt.Errorf("[%d] %v", m.Code, m.Msg)
+				return
+			case m.Msg != "PONG":
+				continue
+			}
+
+			select {
+			case updates <- u:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	version, err := e.snapshot(ctx, p, b)
+	if err != nil {
+		return err
+	}
+
+	ping := time.NewTicker(pingEvery)
+	defer ping.Stop()
+	quiet := time.NewTimer(restartAfter)
+	defer quiet.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errc:
+			return err
+		case <-quiet.C:
+			return errQuiet
+		case <-ping.C:
+			if err := conn.WriteJSON(wsRequest{Method: "PING"}); err != nil {
+				return fmt.Errorf("ping: %w", err)
+			}
+		case u := <-updates:
+			if !quiet.Stop() {
+				<-quiet.C
+			}
+			quiet.Reset(restartAfter)
+
+			if u == nil {
+				if b.Ready() {
+					b.Touch()
+				}
+				continue
+			}
+
+			next, ok, err := apply(b, version, u)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				fmt.Printf("%v %v depth gap: have %v, got %v; resnapshotting\n", e.id, p, version, u.R)
+				b.Reset()
+				if next, err = e.snapshot(ctx, p, b); err != nil {
+					return err
+				}
+			}
+			version = next
+		}
+	}
+}
+
+// snapshot replaces b with the REST book and returns its version.
+func (e *Exchange) snapshot(ctx context.Context, p model.Pair, b *depth.Book) (int64, error) {
+	if err := e.limiter.Wait(ctx, ratelimit.Public); err != nil {
+		return 0, err
+	}
+	o, err := e.nex.GetOrderbook(ctx, types.GetOrderbookParams{
+		Symbol: Symbol(p),
+		Limit:  1000,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("order book: %w", err)
+	}
+
+	asks, err := levels(o.Asks)
+	if err != nil {
+		return 0, err
+	}
+	bids, err := levels(o.Bids)
+	if err != nil {
+		return 0, err
+	}
+
+	b.Replace(asks, bids)
+	return o.LastUpdateID, nil
+}
+
+// apply applies u to b if it follows on from version and returns the new
+// version. It reports false, leaving b untouched, if versions were skipped.
+func apply(b *depth.Book, version int64, u *depthUpdate) (int64, bool, error) {
+	r, err := strconv.ParseInt(u.R, 10, 64)
+	if err != nil {
+		return version, true, fmt.Errorf("tried to parse version %v, got err: %w", u.R, err)
+	}
+	if r <= version {
+		return version, true, nil
+	}
+	if r > version+1 {
+		return version, false, nil
+	}
+
+	asks := make([][]string, 0, len(u.Asks))
+	for _, l := range u.Asks {
+		asks = append(asks, []string{l.P, l.V})
+	}
+	bids := make([][]string, 0, len(u.Bids))
+	for _, l := range u.Bids {
+		bids = append(bids, []string{l.P, l.V})
+	}
+	as, err := levels(asks)
+	if err != nil {
+		return version, true, err
+	}
+	bs, err := levels(bids)
+	if err != nil {
+		return version, true, err
+	}
+
+	b.Apply(as, bs)
+	return r, true, nil
+}
+
+// levels parses [price, quantity] rows.
+func levels(rows [][]string) ([]depth.Level, error) {
+	ls := make([]depth.Level, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 2 {
+			return nil, fmt.Errorf("short level %v", row)
+		}
+		p, err := decimal.NewFromString(row[0])
+		if err != nil {
+			return nil, fmt.Errorf("tried to parse %v, got err: %w", row[0], err)
+		}
+		amt, err := decimal.NewFromString(row[1])
+		if err != nil {
+			return nil, fmt.Errorf("tried to parse %v, got err: %w", row[1], err)
+		}
+		ls = append(ls, depth.Level{Price: p, Amount: amt})
+	}
+	return ls, nil
+}
+
+// Watch returns a channel that receives whenever the best bid or ask of p
+// changes.
+func (e *Exchange) Watch(p model.Pair) <-chan struct{} {
+	return e.stream(p).Watch()
+}
+
+// Age returns how long ago the streamed book for p was last confirmed in
+// sync.
+func (e *Exchange) Age(p model.Pair) time.Duration {
+	return e.stream(p).Age()
+}
+
+// Streaming reports whether Book serves p from the stream.
+func (e *Exchange) Streaming(p model.Pair) bool {
+	return e.Age(p) <= maxAge
+}